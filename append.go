@@ -0,0 +1,121 @@
+package minioproto
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var sequence uint64
+
+// sortableID returns an id that sorts by creation time within and across processes
+func sortableID() string {
+	return fmt.Sprintf("%020d-%06d", time.Now().UnixNano(), atomic.AddUint64(&sequence, 1))
+}
+
+// compactedSuffix marks a segment holding the compaction of every segment up to the id it is named after
+const compactedSuffix = ".compacted"
+
+// appendPrefix is the prefix holding the segments appended to path
+func appendPrefix(path string) string {
+	return path + ".segments/"
+}
+
+// Append adds data to the end of the log at path by writing a new segment object,
+// so producers never have to read-modify-write the whole object
+func (cache *Cache) Append(path string, data []byte, opts minio.PutObjectOptions) (*WriteResult, error) {
+	segment := appendPrefix(path) + sortableID()
+	cache.logger.Info(fmt.Sprintf("Appending %v bytes to path=%v", len(data), path))
+	return cache.WriteData(segment, data, opts)
+}
+
+// ReadAppended reads every segment appended to path and concatenates them in append order
+func (cache *Cache) ReadAppended(path string, opts minio.GetObjectOptions) ([]byte, error) {
+	segments, err := cache.appendSegments(path)
+	if nil != err {
+		return nil, err
+	}
+	segments = liveSegments(segments)
+	data, err := cache.readSegments(segments, opts)
+	if nil != err {
+		return nil, err
+	}
+	cache.logger.Info(fmt.Sprintf("Success reading %v segments for path=%v", len(segments), path))
+	return data, nil
+}
+
+// CompactAppended rewrites the segments appended to path as a single segment, preserving their order.
+// The compacted segment is written under a new name that hides the segments it replaces before they
+// are deleted, so readers never see a segment twice even if the compaction is interrupted.
+func (cache *Cache) CompactAppended(path string, opts minio.PutObjectOptions) error {
+	segments, err := cache.appendSegments(path)
+	if nil != err {
+		return err
+	}
+	live := liveSegments(segments)
+	if len(live) < 2 {
+		return nil
+	}
+
+	data, err := cache.readSegments(live, minio.GetObjectOptions{})
+	if nil != err {
+		return err
+	}
+
+	// The compacted segment sorts right after the last segment it replaces and before any later append
+	last := strings.TrimSuffix(live[len(live)-1].Key, compactedSuffix)
+	compacted := last + compactedSuffix
+	if _, err := cache.WriteData(compacted, data, opts); nil != err {
+		return err
+	}
+	for _, segment := range segments {
+		if segment.Key >= compacted {
+			break
+		}
+		if err := cache.DeleteData(segment.Key, minio.RemoveObjectOptions{}); nil != err {
+			return err
+		}
+	}
+
+	cache.logger.Info(fmt.Sprintf("Compacted %v segments for path=%v", len(live), path))
+	return nil
+}
+
+// readSegments reads and concatenates the given segments
+func (cache *Cache) readSegments(segments []minio.ObjectInfo, opts minio.GetObjectOptions) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for _, segment := range segments {
+		data, err := cache.ReadData(segment.Key, opts)
+		if nil != err {
+			err = errors.Wrap(err, fmt.Sprintf("Failed to read segment %v", segment.Key))
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+func (cache *Cache) appendSegments(path string) ([]minio.ObjectInfo, error) {
+	segments, err := cache.List(appendPrefix(path), minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return nil, err
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].Key < segments[j].Key })
+	return segments, nil
+}
+
+// liveSegments drops the sorted segments replaced by the latest compacted segment
+func liveSegments(segments []minio.ObjectInfo) []minio.ObjectInfo {
+	for i := len(segments) - 1; i >= 0; i-- {
+		if strings.HasSuffix(segments[i].Key, compactedSuffix) {
+			return segments[i:]
+		}
+	}
+	return segments
+}
@@ -0,0 +1,242 @@
+package minioproto
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// ArchiveFormat is the container format used by ArchivePrefix and ExtractArchive
+type ArchiveFormat string
+
+const (
+	// ArchiveTarGz bundles objects into a gzip compressed tarball
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	// ArchiveZip bundles objects into a zip file
+	ArchiveZip ArchiveFormat = "zip"
+)
+
+const tarGzContentType = "application/gzip"
+const zipContentType = "application/zip"
+
+// ArchivePrefix streams every object under prefix into a single archive object at dstKey,
+// entries are named relative to the prefix
+func (cache *Cache) ArchivePrefix(prefix, dstKey string, format ArchiveFormat) error {
+	cache.logger.Info(fmt.Sprintf("Archiving prefix=%v to path=%v as %v", prefix, dstKey, format))
+	var contentType string
+	switch format {
+	case ArchiveTarGz:
+		contentType = tarGzContentType
+	case ArchiveZip:
+		contentType = zipContentType
+	default:
+		err := errors.New(fmt.Sprintf("Unsupported archive format %v", format))
+		cache.logger.Error(err.Error())
+		return err
+	}
+
+	dstKey = cache.objectKey(dstKey)
+	if err := cache.authorize(AuthPut, dstKey, nil); nil != err {
+		return err
+	}
+	objects, err := cache.List(prefix, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return err
+	}
+
+	// Stream the archive through a pipe so it never has to be held in memory
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(cache.writeArchive(writer, prefix, objects, format))
+	}()
+
+	opts := minio.PutObjectOptions{ContentType: contentType}
+	uploadInfo, err := cache.backend.Put(cache.ctx, dstKey, reader, -1, opts)
+	reader.CloseWithError(err)
+	if nil != err {
+		err = errors.Wrap(err, "Failed to upload archive")
+		cache.logger.Error(err.Error())
+		return err
+	}
+
+	cache.invalidateTiers(dstKey)
+	cache.firePut(dstKey, newWriteResult(uploadInfo))
+	cache.logger.Info(fmt.Sprintf("Successfully archived %v objects with bytes: %v", len(objects), uploadInfo.Size))
+	return nil
+}
+
+// writeArchive copies each object into the archive written to output
+func (cache *Cache) writeArchive(output io.Writer, prefix string, objects []minio.ObjectInfo, format ArchiveFormat) error {
+	var gzipWriter *gzip.Writer
+	var tarWriter *tar.Writer
+	var zipWriter *zip.Writer
+	if ArchiveTarGz == format {
+		gzipWriter = gzip.NewWriter(output)
+		tarWriter = tar.NewWriter(gzipWriter)
+	} else {
+		zipWriter = zip.NewWriter(output)
+	}
+
+	for _, info := range objects {
+		name := strings.TrimPrefix(strings.TrimPrefix(info.Key, prefix), "/")
+		if "" == name || strings.HasSuffix(name, "/") {
+			continue
+		}
+
+		var entry io.Writer
+		var err error
+		if nil != tarWriter {
+			err = tarWriter.WriteHeader(&tar.Header{
+				Name:    name,
+				Mode:    0644,
+				Size:    info.Size,
+				ModTime: info.LastModified,
+			})
+			entry = tarWriter
+		} else {
+			entry, err = zipWriter.CreateHeader(&zip.FileHeader{
+				Name:     name,
+				Method:   zip.Deflate,
+				Modified: info.LastModified,
+			})
+		}
+		if nil != err {
+			return errors.Wrap(err, fmt.Sprintf("Failed to add %v to archive", name))
+		}
+
+		key := cache.objectKey(info.Key)
+		if err := cache.authorize(AuthGet, key, nil); nil != err {
+			return err
+		}
+		obj, err := cache.backend.Get(cache.ctx, key, minio.GetObjectOptions{})
+		if nil != err {
+			return errors.Wrap(err, fmt.Sprintf("Failed to get file %v", info.Key))
+		}
+		_, err = io.Copy(entry, obj)
+		obj.Close()
+		if nil != err {
+			return errors.Wrap(err, fmt.Sprintf("Failed to copy %v to archive", info.Key))
+		}
+	}
+
+	if nil != tarWriter {
+		if err := tarWriter.Close(); nil != err {
+			return err
+		}
+		return gzipWriter.Close()
+	}
+	return zipWriter.Close()
+}
+
+// ExtractArchive unpacks a tar.gz or zip archive object into individual objects under dstPrefix,
+// the format is chosen from the extension of srcKey
+func (cache *Cache) ExtractArchive(srcKey, dstPrefix string) error {
+	cache.logger.Info(fmt.Sprintf("Extracting path=%v to prefix=%v", srcKey, dstPrefix))
+	srcKey = cache.objectKey(srcKey)
+	if err := cache.authorize(AuthGet, srcKey, nil); nil != err {
+		return err
+	}
+	obj, err := cache.backend.Get(cache.ctx, srcKey, minio.GetObjectOptions{})
+	if nil != err {
+		err = errors.Wrap(err, "Failed to get archive")
+		cache.logger.Error(err.Error())
+		return err
+	}
+	defer obj.Close()
+
+	switch {
+	case strings.HasSuffix(srcKey, ".zip"):
+		err = cache.extractZip(obj, dstPrefix)
+	case strings.HasSuffix(srcKey, ".tar.gz"), strings.HasSuffix(srcKey, ".tgz"):
+		err = cache.extractTarGz(obj, dstPrefix)
+	default:
+		err = errors.New(fmt.Sprintf("Unable to detect archive format of %v", srcKey))
+	}
+	if nil != err {
+		err = errors.Wrap(err, "Failed to extract archive")
+		cache.logger.Error(err.Error())
+		return err
+	}
+
+	cache.logger.Info(fmt.Sprintf("Successfully extracted path=%v", srcKey))
+	return nil
+}
+
+func (cache *Cache) extractTarGz(obj BackendObject, dstPrefix string) error {
+	gzipReader, err := gzip.NewReader(obj)
+	if nil != err {
+		return err
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if io.EOF == err {
+			return nil
+		}
+		if nil != err {
+			return err
+		}
+		if tar.TypeReg != header.Typeflag {
+			continue
+		}
+		if err := cache.extractEntry(dstPrefix, header.Name, tarReader, header.Size); nil != err {
+			return err
+		}
+	}
+}
+
+func (cache *Cache) extractZip(obj BackendObject, dstPrefix string) error {
+	info, err := obj.Stat()
+	if nil != err {
+		return err
+	}
+
+	zipReader, err := zip.NewReader(obj, info.Size)
+	if nil != err {
+		return err
+	}
+	for _, file := range zipReader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		entry, err := file.Open()
+		if nil != err {
+			return err
+		}
+		err = cache.extractEntry(dstPrefix, file.Name, entry, int64(file.UncompressedSize64))
+		entry.Close()
+		if nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractEntry uploads a single archive entry, rejecting names that escape the destination prefix
+func (cache *Cache) extractEntry(dstPrefix, name string, reader io.Reader, size int64) error {
+	name = path.Clean("/" + name)[1:]
+	if "" == name {
+		return nil
+	}
+	key := cache.objectKey(path.Join(dstPrefix, name))
+
+	cache.logger.Info(fmt.Sprintf("Writing path=%v with %v bytes", key, size))
+	if err := cache.authorize(AuthPut, key, nil); nil != err {
+		return err
+	}
+	uploadInfo, err := cache.backend.Put(cache.ctx, key, reader, size, minio.PutObjectOptions{})
+	if nil != err {
+		return errors.Wrap(err, fmt.Sprintf("Failed to upload %v", key))
+	}
+	cache.invalidateTiers(key)
+	cache.firePut(key, newWriteResult(uploadInfo))
+	return nil
+}
@@ -0,0 +1,120 @@
+package minioproto
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// AuthOp is the kind of operation an Authorizer is asked about
+type AuthOp string
+
+const (
+	// AuthGet reads an object
+	AuthGet AuthOp = "get"
+	// AuthPut writes an object
+	AuthPut AuthOp = "put"
+	// AuthDelete removes an object
+	AuthDelete AuthOp = "delete"
+	// AuthStat reads the metadata of an object
+	AuthStat AuthOp = "stat"
+	// AuthList lists the objects under a prefix, Key holds the prefix
+	AuthList AuthOp = "list"
+)
+
+// ErrUnauthorized should be returned, or wrapped, by an Authorizer denying an operation
+var ErrUnauthorized = errors.New("Unauthorized")
+
+// AuthRequest describes an operation about to be performed
+type AuthRequest struct {
+	Op  AuthOp
+	Key string
+	// Metadata is the user metadata of writes, nil for other operations
+	Metadata map[string]string
+	// Identity is the caller identity stored with ContextWithIdentity, nil when there is none
+	Identity interface{}
+}
+
+// Authorizer is consulted before every operation of a Cache, including the bookkeeping objects
+// written by features such as indexes and idempotency ledgers. Returning an error denies the operation.
+type Authorizer interface {
+	Authorize(ctx context.Context, request AuthRequest) error
+}
+
+// AuthorizerFunc adapts a function to the Authorizer interface
+type AuthorizerFunc func(ctx context.Context, request AuthRequest) error
+
+// Authorize calls fn
+func (fn AuthorizerFunc) Authorize(ctx context.Context, request AuthRequest) error {
+	return fn(ctx, request)
+}
+
+// PrefixAuthorizer isolates tenants by key prefix, the tenant prefix is looked up from the identity
+type PrefixAuthorizer struct {
+	// Prefix returns the prefix an identity may access, false denies every operation.
+	// The prefix is a whole path segment, "a" allows "a" and "a/x" but not "ab/x", and "" allows every key.
+	Prefix func(identity interface{}) (string, bool)
+}
+
+// Authorize allows keys under the prefix of the caller identity
+func (authorizer PrefixAuthorizer) Authorize(ctx context.Context, request AuthRequest) error {
+	prefix, ok := authorizer.Prefix(request.Identity)
+	if !ok || !underPrefix(request.Key, prefix) {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// underPrefix reports whether key is prefix itself or lies under it on a path segment boundary
+func underPrefix(key, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return "" == prefix || key == prefix || strings.HasPrefix(key, prefix+"/")
+}
+
+// identityKey is the context key of the caller identity
+type identityKey struct{}
+
+// ContextWithIdentity returns a context carrying the caller identity passed to the Authorizer,
+// scope it to a request with cache.With(CallContext(ContextWithIdentity(ctx, identity)))
+func ContextWithIdentity(ctx context.Context, identity interface{}) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the caller identity stored with ContextWithIdentity
+func IdentityFromContext(ctx context.Context) interface{} {
+	return ctx.Value(identityKey{})
+}
+
+// WithAuthorizer consults authorizer before every operation, including the stats, lists and copies made
+// internally by caches, migrations, spool replays and index rebuilds. The identity is read from the context
+// of the Cache or view, i.e. the one given to New or CallContext, and the same context is passed to authorizer.
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(cache *Cache) {
+		cache.authorizer = authorizer
+	}
+}
+
+// authorize asks the Authorizer, if any, whether the operation is allowed
+func (cache *Cache) authorize(op AuthOp, path string, metadata map[string]string) error {
+	if nil != cache.snapshot && (AuthPut == op || AuthDelete == op) {
+		err := errors.Wrap(ErrReadOnly, fmt.Sprintf("Failed to %v path=%v", op, path))
+		cache.logger.Error(err.Error())
+		return err
+	}
+	if nil == cache.authorizer {
+		return nil
+	}
+	request := AuthRequest{
+		Op:       op,
+		Key:      path,
+		Metadata: metadata,
+		Identity: IdentityFromContext(cache.ctx),
+	}
+	if err := cache.authorizer.Authorize(cache.ctx, request); nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to authorize %v path=%v", op, path))
+		cache.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
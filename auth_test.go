@@ -0,0 +1,72 @@
+package minioproto
+
+import (
+	"context"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestUnderPrefix(t *testing.T) {
+	tests := []struct {
+		key, prefix string
+		expected    bool
+	}{
+		{"a", "a", true},
+		{"a/x", "a", true},
+		{"a/x", "a/", true},
+		{"ab/x", "a", false},
+		{"b/x", "a", false},
+		{"anything", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.key+"@"+test.prefix, func(t *testing.T) {
+			if output := underPrefix(test.key, test.prefix); output != test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestPrefixAuthorizer(t *testing.T) {
+	authorizer := PrefixAuthorizer{Prefix: func(identity interface{}) (string, bool) {
+		tenant, ok := identity.(string)
+		return tenant, ok
+	}}
+	cache, _ := newTestCache(t, WithAuthorizer(authorizer))
+	acme := cache.With(CallContext(ContextWithIdentity(context.Background(), "acme")))
+	if _, err := acme.WriteData("acme/a", []byte("a"), minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := acme.WriteData("acmecorp/a", []byte("a"), minio.PutObjectOptions{}); ErrUnauthorized != errors.Cause(err) {
+		t.Fatalf("expected a sibling prefix to be denied, got %v", err)
+	}
+	if _, err := cache.ReadData("acme/a", minio.GetObjectOptions{}); ErrUnauthorized != errors.Cause(err) {
+		t.Fatalf("expected a caller without identity to be denied, got %v", err)
+	}
+}
+
+func TestAuthorizeQuota(t *testing.T) {
+	var mutex sync.Mutex
+	requests := []AuthRequest{}
+	authorizer := AuthorizerFunc(func(ctx context.Context, request AuthRequest) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests = append(requests, AuthRequest{Op: request.Op, Key: request.Key})
+		return nil
+	})
+	cache, _ := newTestCache(t, WithAuthorizer(authorizer), WithProfiles(Profile{Prefix: "limited/", Quota: 100}))
+	if _, err := cache.WriteData("limited/a", []byte("a"), minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	expected := []AuthRequest{
+		{Op: AuthPut, Key: "limited/a"},
+		{Op: AuthStat, Key: "limited/a"},
+		{Op: AuthList, Key: "limited/"},
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, requests)
+	}
+}
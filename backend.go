@@ -0,0 +1,420 @@
+package minioproto
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/s3utils"
+	"github.com/minio/minio-go/v7/pkg/signer"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupportedBackend is returned by minio specific operations, such as multipart upload cleanup,
+// on a Cache created with a Backend other than minio
+var ErrUnsupportedBackend = errors.New("Operation is not supported by the storage backend")
+
+// ErrPreconditionFailed is returned by conditional writes when the object changed or already exists
+var ErrPreconditionFailed = errors.New("Precondition failed")
+
+// BackendObject is an object body returned by Backend.Get, *minio.Object implements it
+type BackendObject interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+	// Stat returns the description of the object
+	Stat() (minio.ObjectInfo, error)
+}
+
+// Backend stores the objects of a Cache. Options and results use the minio types so the format
+// helpers behave the same on every backend, missing objects should be reported with a
+// minio.ErrorResponse with the NoSuchKey code.
+type Backend interface {
+	// Get returns the body of key, honouring the Range and If-Match headers of opts
+	Get(ctx context.Context, key string, opts minio.GetObjectOptions) (BackendObject, error)
+	// Put stores size bytes from reader at key, a size of -1 reads until EOF
+	Put(ctx context.Context, key string, reader io.Reader, size int64, opts minio.PutObjectOptions) (minio.UploadInfo, error)
+	// Stat describes key
+	Stat(ctx context.Context, key string, opts minio.StatObjectOptions) (minio.ObjectInfo, error)
+	// List sends the objects matching opts, errors are sent as an ObjectInfo with Err set
+	List(ctx context.Context, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo
+	// Delete removes key
+	Delete(ctx context.Context, key string, opts minio.RemoveObjectOptions) error
+	// Copy concatenates srcs into dst
+	Copy(ctx context.Context, dst minio.CopyDestOptions, srcs ...minio.CopySrcOptions) (minio.UploadInfo, error)
+}
+
+// PutCondition makes a write conditional on the current state of the key
+type PutCondition struct {
+	// IfMatch only writes when the ETag of the current object matches
+	IfMatch string
+	// IfNoneMatch only writes when the key doesn't exist yet
+	IfNoneMatch bool
+}
+
+// ConditionalBackend is implemented by backends supporting atomic conditional writes, which leases,
+// idempotency records and re-encryption rely on. Failed conditions are reported with ErrPreconditionFailed.
+type ConditionalBackend interface {
+	Backend
+	// PutIf stores size bytes from reader at key when cond holds
+	PutIf(ctx context.Context, key string, reader io.Reader, size int64, opts minio.PutObjectOptions, cond PutCondition) (minio.UploadInfo, error)
+}
+
+// minioBackend is the default Backend, storing objects in a minio bucket
+type minioBackend struct {
+	client     *minio.Client
+	bucketName string
+	options    minio.Options
+	httpClient *http.Client
+
+	// conditional caches whether the server honours conditional headers once it was probed
+	mutex       sync.Mutex
+	probed      bool
+	conditional bool
+}
+
+// NewMinioBackend returns a Backend storing objects in bucketName. Conditional writes, which minio-go
+// doesn't expose, are signed with the credentials, region and bucket lookup of options and sent
+// through its Transport, which should be the one the client was created with.
+func NewMinioBackend(client *minio.Client, bucketName string, options minio.Options) (Backend, error) {
+	if nil == options.Transport {
+		transport, err := minio.DefaultTransport(options.Secure)
+		if nil != err {
+			return nil, err
+		}
+		options.Transport = transport
+	}
+	return &minioBackend{
+		client:     client,
+		bucketName: bucketName,
+		options:    options,
+		httpClient: &http.Client{Transport: options.Transport},
+	}, nil
+}
+
+// Get returns the body of key
+func (backend *minioBackend) Get(ctx context.Context, key string, opts minio.GetObjectOptions) (BackendObject, error) {
+	obj, err := backend.client.GetObject(ctx, backend.bucketName, key, opts)
+	if nil != err {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Put stores the reader at key
+func (backend *minioBackend) Put(ctx context.Context, key string, reader io.Reader, size int64, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	return backend.client.PutObject(ctx, backend.bucketName, key, reader, size, opts)
+}
+
+// Stat describes key
+func (backend *minioBackend) Stat(ctx context.Context, key string, opts minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	return backend.client.StatObject(ctx, backend.bucketName, key, opts)
+}
+
+// List sends the objects matching opts
+func (backend *minioBackend) List(ctx context.Context, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+	return backend.client.ListObjects(ctx, backend.bucketName, opts)
+}
+
+// Delete removes key
+func (backend *minioBackend) Delete(ctx context.Context, key string, opts minio.RemoveObjectOptions) error {
+	return backend.client.RemoveObject(ctx, backend.bucketName, key, opts)
+}
+
+// Copy composes srcs into dst server-side
+func (backend *minioBackend) Copy(ctx context.Context, dst minio.CopyDestOptions, srcs ...minio.CopySrcOptions) (minio.UploadInfo, error) {
+	return backend.client.ComposeObject(ctx, dst, srcs...)
+}
+
+// PutIf sends a signed single part PUT with If-Match or If-None-Match, which minio-go can't set.
+// The server must support conditional writes (MinIO since 2024, AWS S3), which is probed before the
+// first conditional write; servers ignoring the headers fail with ErrUnsupportedBackend.
+func (backend *minioBackend) PutIf(ctx context.Context, key string, reader io.Reader, size int64, opts minio.PutObjectOptions, cond PutCondition) (minio.UploadInfo, error) {
+	if size >= 0 {
+		reader = io.LimitReader(reader, size)
+	}
+	data, err := ioutil.ReadAll(reader)
+	if nil != err {
+		return minio.UploadInfo{}, err
+	}
+	if err := backend.probeConditional(ctx); nil != err {
+		return minio.UploadInfo{}, err
+	}
+	return backend.putIf(ctx, key, data, opts, cond)
+}
+
+// probeConditional checks once that the server honours conditional headers, by writing a random key
+// with an If-Match that can't hold. A server ignoring the header creates the object instead of failing.
+func (backend *minioBackend) probeConditional(ctx context.Context) error {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	if !backend.probed {
+		token := make([]byte, 16)
+		if _, err := rand.Read(token); nil != err {
+			return err
+		}
+		probe := fmt.Sprintf(".minio-proto/conditional-probe-%v", hex.EncodeToString(token))
+		_, err := backend.putIf(ctx, probe, []byte{}, minio.PutObjectOptions{}, PutCondition{IfMatch: hex.EncodeToString(token)})
+		switch {
+		case nil == err:
+			// The precondition was ignored, remove the probe that was written anyway
+			backend.client.RemoveObject(ctx, backend.bucketName, probe, minio.RemoveObjectOptions{})
+		case ErrPreconditionFailed == errors.Cause(err), "NoSuchKey" == minio.ToErrorResponse(err).Code:
+			backend.conditional = true
+		case "NotImplemented" == minio.ToErrorResponse(err).Code:
+		default:
+			return err
+		}
+		backend.probed = true
+	}
+	if !backend.conditional {
+		return errors.Wrap(ErrUnsupportedBackend, "server ignores conditional writes")
+	}
+	return nil
+}
+
+// putIf sends the conditional PUT through the transport of the client, addressing the bucket like minio-go does
+func (backend *minioBackend) putIf(ctx context.Context, key string, data []byte, opts minio.PutObjectOptions, cond PutCondition) (minio.UploadInfo, error) {
+	if nil == backend.options.Creds {
+		return minio.UploadInfo{}, errors.Wrap(ErrUnsupportedBackend, "conditional writes without credentials")
+	}
+	value, err := backend.options.Creds.Get()
+	if nil != err {
+		return minio.UploadInfo{}, err
+	}
+	if value.SignerType.IsAnonymous() {
+		return minio.UploadInfo{}, errors.Wrap(ErrUnsupportedBackend, "conditional writes with anonymous access")
+	}
+
+	endpoint := backend.objectURL(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint.String(), bytes.NewReader(data))
+	if nil != err {
+		return minio.UploadInfo{}, err
+	}
+	for k, v := range opts.Header() {
+		req.Header[k] = v
+	}
+	if "" != cond.IfMatch {
+		req.Header.Set("If-Match", fmt.Sprintf("\"%v\"", strings.Trim(cond.IfMatch, "\"")))
+	}
+	if cond.IfNoneMatch {
+		req.Header.Set("If-None-Match", "*")
+	}
+	sum := sha256.Sum256(data)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	if opts.SendContentMd5 {
+		// Servers supporting conditional writes also verify flexible checksums
+		md5Sum := md5.Sum(data)
+		req.Header.Set("Content-Md5", base64.StdEncoding.EncodeToString(md5Sum[:]))
+		req.Header.Set("X-Amz-Checksum-Sha256", base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	req.ContentLength = int64(len(data))
+	region := backend.options.Region
+	if "" == region {
+		region = "us-east-1"
+	}
+	req = signer.SignV4(*req, value.AccessKeyID, value.SecretAccessKey, value.SessionToken, region)
+
+	resp, err := backend.httpClient.Do(req)
+	if nil != err {
+		return minio.UploadInfo{}, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return minio.UploadInfo{
+			Bucket:    backend.bucketName,
+			Key:       key,
+			ETag:      strings.Trim(resp.Header.Get("ETag"), "\""),
+			Size:      int64(len(data)),
+			VersionID: resp.Header.Get("X-Amz-Version-Id"),
+		}, nil
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		return minio.UploadInfo{}, errors.Wrap(ErrPreconditionFailed, key)
+	}
+	errResp := minio.ErrorResponse{}
+	if err := xml.NewDecoder(resp.Body).Decode(&errResp); nil != err {
+		errResp.Code = resp.Status
+	}
+	errResp.StatusCode = resp.StatusCode
+	return minio.UploadInfo{}, errResp
+}
+
+// objectURL returns the url of key, using virtual host style addressing when the bucket lookup asks for it
+func (backend *minioBackend) objectURL(key string) url.URL {
+	endpoint := *backend.client.EndpointURL()
+	virtualHost := false
+	switch backend.options.BucketLookup {
+	case minio.BucketLookupDNS:
+		virtualHost = true
+	case minio.BucketLookupAuto:
+		virtualHost = s3utils.IsVirtualHostSupported(endpoint, backend.bucketName)
+	}
+	if virtualHost {
+		endpoint.Host = backend.bucketName + "." + endpoint.Host
+		endpoint.Path = "/" + key
+	} else {
+		endpoint.Path = fmt.Sprintf("/%v/%v", backend.bucketName, key)
+	}
+	endpoint.RawPath = s3utils.EncodePath(endpoint.Path)
+	return endpoint
+}
+
+// putIf writes data to the object key when cond holds, backends without conditional writes fail with ErrUnsupportedBackend
+func (cache *Cache) putIf(key string, data []byte, opts minio.PutObjectOptions, cond PutCondition) (minio.UploadInfo, error) {
+	backend, ok := cache.backend.(ConditionalBackend)
+	if !ok {
+		return minio.UploadInfo{}, errors.Wrap(ErrUnsupportedBackend, "conditional writes")
+	}
+	if cache.verifyContent {
+		opts.SendContentMd5 = true
+	}
+	return backend.PutIf(cache.ctx, key, bytes.NewReader(data), int64(len(data)), opts, cond)
+}
+
+// minioClient returns the minio client of the Cache, or ErrUnsupportedBackend for other backends
+func (cache *Cache) minioClient() (*minio.Client, error) {
+	if nil == cache.client {
+		return nil, ErrUnsupportedBackend
+	}
+	return cache.client, nil
+}
+
+// getRecord reads a small control object such as a lease straight from the backend, returning nil data when
+// it doesn't exist. Control objects bypass the payload pipeline so conditional writes can rely on their ETag.
+func (cache *Cache) getRecord(path string) ([]byte, string, error) {
+	key := cache.objectKey(path)
+	if err := cache.authorize(AuthGet, key, nil); nil != err {
+		return nil, "", err
+	}
+	obj, err := cache.backend.Get(cache.ctx, key, minio.GetObjectOptions{})
+	if nil == err {
+		defer obj.Close()
+		var info minio.ObjectInfo
+		if info, err = obj.Stat(); nil == err {
+			data, err := ioutil.ReadAll(obj)
+			return data, info.ETag, err
+		}
+	}
+	if "NoSuchKey" == minio.ToErrorResponse(errors.Cause(err)).Code {
+		return nil, "", nil
+	}
+	err = errors.Wrap(err, fmt.Sprintf("Failed to get %v", key))
+	cache.logger.Error(err.Error())
+	return nil, "", err
+}
+
+// putRecord writes a control object when cond holds, reporting false when another writer got there first
+func (cache *Cache) putRecord(path string, data []byte, cond PutCondition) (bool, error) {
+	key := cache.objectKey(path)
+	if err := cache.authorize(AuthPut, key, nil); nil != err {
+		return false, err
+	}
+	_, err := cache.putIf(key, data, minio.PutObjectOptions{ContentType: jsonContentType}, cond)
+	if ErrPreconditionFailed == errors.Cause(err) {
+		return false, nil
+	}
+	if nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to write %v", key))
+		cache.logger.Error(err.Error())
+		return false, err
+	}
+	cache.invalidateTiers(key)
+	return true, nil
+}
+
+// objectTags returns the tags of the object key, minio only returns them from GetObjectTagging
+func (cache *Cache) objectTags(key string, info minio.ObjectInfo) (map[string]string, error) {
+	if err := cache.authorize(AuthStat, key, nil); nil != err {
+		return nil, err
+	}
+	if nil == cache.client {
+		return info.UserTags, nil
+	}
+	tags, err := cache.client.GetObjectTagging(cache.ctx, cache.bucketName, key, minio.GetObjectTaggingOptions{})
+	if nil != err {
+		return nil, errors.Wrap(err, fmt.Sprintf("Failed to get tags of %v", key))
+	}
+	return tags.ToMap(), nil
+}
+
+// storageClassOf returns the storage class of a stat result, minio-go only reports it in the headers
+func storageClassOf(info minio.ObjectInfo) string {
+	if "" != info.StorageClass {
+		return info.StorageClass
+	}
+	return info.Metadata.Get("X-Amz-Storage-Class")
+}
+
+// getFile downloads key to file, resuming partial downloads on minio
+func (cache *Cache) getFile(key, file string) error {
+	if nil != cache.client {
+		return cache.client.FGetObject(cache.ctx, cache.bucketName, key, file, minio.GetObjectOptions{})
+	}
+	obj, err := cache.backend.Get(cache.ctx, key, minio.GetObjectOptions{})
+	if nil != err {
+		return err
+	}
+	defer obj.Close()
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); nil != err {
+		return err
+	}
+	partial, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".part")
+	if nil != err {
+		return err
+	}
+	_, err = io.Copy(partial, obj)
+	if closeErr := partial.Close(); nil == err {
+		err = closeErr
+	}
+	if nil == err {
+		err = os.Rename(partial.Name(), file)
+	}
+	if nil != err {
+		os.Remove(partial.Name())
+	}
+	return err
+}
+
+// putFile uploads file to key, using parallel multipart uploads on minio
+func (cache *Cache) putFile(key, file string, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	if nil != cache.client {
+		return cache.client.FPutObject(cache.ctx, cache.bucketName, key, file, opts)
+	}
+	reader, err := os.Open(file)
+	if nil != err {
+		return minio.UploadInfo{}, err
+	}
+	defer reader.Close()
+	info, err := reader.Stat()
+	if nil != err {
+		return minio.UploadInfo{}, err
+	}
+	return cache.backend.Put(cache.ctx, key, reader, info.Size(), opts)
+}
+
+// noSuchKey is the error returned by backends for missing objects
+func noSuchKey(key string) error {
+	return minio.ErrorResponse{
+		StatusCode: 404,
+		Code:       "NoSuchKey",
+		Message:    fmt.Sprintf("The specified key does not exist: %v", key),
+		Key:        key,
+	}
+}
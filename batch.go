@@ -0,0 +1,206 @@
+package minioproto
+
+import (
+	"context"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchStatus is the outcome of one key of a batch operation
+type BatchStatus string
+
+const (
+	// BatchOK means the key was processed successfully
+	BatchOK BatchStatus = "ok"
+	// BatchFailed means every attempt for the key failed
+	BatchFailed BatchStatus = "failed"
+	// BatchPending means the key was not processed before the context was done
+	BatchPending BatchStatus = "pending"
+)
+
+// BatchItem reports the outcome of one key of a batch operation
+type BatchItem struct {
+	Key      string        `json:"key"`
+	Status   BatchStatus   `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Err      error         `json:"-"`
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration"`
+	Retries  int           `json:"retries"`
+}
+
+// BatchResult reports the per-key outcome of PutMany, GetMany, DeletePrefix, Migrate and Sync, in input order
+type BatchResult struct {
+	Items []BatchItem `json:"items"`
+}
+
+// BatchOptions configures PutMany, DeletePrefix, Migrate and Sync
+type BatchOptions struct {
+	// Concurrency is the number of keys processed in parallel, defaults to 4
+	Concurrency int
+	// Retries is the number of extra attempts for a failed key
+	Retries int
+}
+
+// ErrBatchFailed is returned when some keys of a batch operation failed, see BatchResult.Failed
+type ErrBatchFailed struct {
+	Failed []string
+}
+
+// Error lists the failed keys
+func (err *ErrBatchFailed) Error() string {
+	return fmt.Sprintf("Batch failed for %v keys: %v", len(err.Failed), strings.Join(err.Failed, ","))
+}
+
+// Failed returns the keys that failed or were not processed, ready to be retried
+func (result *BatchResult) Failed() []string {
+	output := []string{}
+	for _, item := range result.Items {
+		if BatchOK != item.Status {
+			output = append(output, item.Key)
+		}
+	}
+	return output
+}
+
+// Succeeded returns the keys that were processed successfully
+func (result *BatchResult) Succeeded() []string {
+	output := []string{}
+	for _, item := range result.Items {
+		if BatchOK == item.Status {
+			output = append(output, item.Key)
+		}
+	}
+	return output
+}
+
+// Bytes returns the total bytes transferred by successful keys
+func (result *BatchResult) Bytes() int64 {
+	var output int64
+	for _, item := range result.Items {
+		if BatchOK == item.Status {
+			output += item.Bytes
+		}
+	}
+	return output
+}
+
+// Err returns an *ErrBatchFailed when any key failed, nil otherwise
+func (result *BatchResult) Err() error {
+	if failed := result.Failed(); 0 != len(failed) {
+		return &ErrBatchFailed{Failed: failed}
+	}
+	return nil
+}
+
+// runBatch calls fn for every key with retries until they are all processed or ctx is done.
+// Items of keys still in flight when ctx is done are reported as pending.
+func (cache *Cache) runBatch(ctx context.Context, keys []string, batchOpts BatchOptions, fn func(key string) (int64, error)) *BatchResult {
+	mutex := sync.Mutex{}
+	items := make([]BatchItem, len(keys))
+	for i, key := range keys {
+		items[i] = BatchItem{Key: key, Status: BatchPending}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		parallel(batchOpts.Concurrency, len(keys), func(index int) {
+			start := time.Now()
+			item := BatchItem{Key: keys[index], Status: BatchFailed}
+			for attempt := 0; attempt <= batchOpts.Retries && nil == ctx.Err(); attempt++ {
+				item.Retries = attempt
+				bytes, err := fn(keys[index])
+				if nil == err {
+					item.Status, item.Bytes, item.Err = BatchOK, bytes, nil
+					break
+				}
+				item.Err = err
+			}
+			// Keys whose attempts were cut short by the context are pending, not failed
+			if BatchOK != item.Status && nil != ctx.Err() {
+				item.Status, item.Err = BatchPending, ctx.Err()
+			}
+			if nil != item.Err {
+				item.Error = item.Err.Error()
+			}
+			item.Duration = time.Since(start)
+
+			mutex.Lock()
+			items[index] = item
+			mutex.Unlock()
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	output := &BatchResult{Items: make([]BatchItem, len(items))}
+	copy(output.Items, items)
+	for i := range output.Items {
+		if BatchPending == output.Items[i].Status && nil == output.Items[i].Err && nil != ctx.Err() {
+			output.Items[i].Err = ctx.Err()
+			output.Items[i].Error = ctx.Err().Error()
+		}
+	}
+	return output
+}
+
+// PutMany writes every payload with WriteData concurrently, the error is an *ErrBatchFailed when any key failed
+func (cache *Cache) PutMany(payloads map[string][]byte, batchOpts BatchOptions, opts minio.PutObjectOptions) (*BatchResult, error) {
+	cache.logger.Info(fmt.Sprintf("Writing %v objects", len(payloads)))
+	keys := make([]string, 0, len(payloads))
+	for key := range payloads {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := cache.runBatch(cache.ctx, keys, batchOpts, func(key string) (int64, error) {
+		if _, err := cache.WriteData(key, payloads[key], opts); nil != err {
+			return 0, err
+		}
+		return int64(len(payloads[key])), nil
+	})
+	return result, cache.batchErr(result)
+}
+
+// DeletePrefix removes every object under prefix, the error is an *ErrBatchFailed when any key failed
+func (cache *Cache) DeletePrefix(prefix string, batchOpts BatchOptions) (*BatchResult, error) {
+	objects, err := cache.List(prefix, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return nil, err
+	}
+	keys := make([]string, len(objects))
+	sizes := make(map[string]int64, len(objects))
+	for i, info := range objects {
+		keys[i] = info.Key
+		sizes[info.Key] = info.Size
+	}
+
+	result := cache.runBatch(cache.ctx, keys, batchOpts, func(key string) (int64, error) {
+		if err := cache.DeleteData(key, minio.RemoveObjectOptions{}); nil != err {
+			return 0, err
+		}
+		return sizes[key], nil
+	})
+	return result, cache.batchErr(result)
+}
+
+// batchErr logs and returns the error of a batch result
+func (cache *Cache) batchErr(result *BatchResult) error {
+	err := result.Err()
+	if nil != err {
+		cache.logger.Error(err.Error())
+		return err
+	}
+	cache.logger.Info(fmt.Sprintf("Successfully processed %v keys with bytes: %v", len(result.Items), result.Bytes()))
+	return nil
+}
@@ -0,0 +1,85 @@
+package minioproto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/minio/minio-go/v7"
+	"reflect"
+	"testing"
+)
+
+func TestSync(t *testing.T) {
+	src, srcBackend := newTestCache(t)
+	dst, dstBackend := newTestCache(t)
+	putString(t, srcBackend, "data/a", "a", minio.PutObjectOptions{})
+	putString(t, srcBackend, "data/b", "b", minio.PutObjectOptions{})
+	putString(t, srcBackend, "data/c", "c", minio.PutObjectOptions{})
+	putString(t, dstBackend, "data/b", "stale", minio.PutObjectOptions{})
+	putString(t, dstBackend, "data/c", "c", minio.PutObjectOptions{})
+	putString(t, dstBackend, "data/d", "dd", minio.PutObjectOptions{})
+	putString(t, dstBackend, "other/e", "e", minio.PutObjectOptions{})
+
+	result, err := src.Sync(dst, "data/", SyncOptions{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if succeeded := result.Succeeded(); !reflect.DeepEqual([]string{"data/a", "data/b"}, succeeded) {
+		t.Fatalf("expected the missing and changed keys to be copied, got %v", succeeded)
+	}
+	if _, err := dstBackend.Stat(context.Background(), "data/d", minio.StatObjectOptions{}); nil != err {
+		t.Fatal("expected extra keys to be kept without Delete")
+	}
+
+	result, err = src.Sync(dst, "data/", SyncOptions{Delete: true})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if succeeded := result.Succeeded(); !reflect.DeepEqual([]string{"data/d"}, succeeded) || 2 != result.Bytes() {
+		t.Fatalf("expected the extra key to be deleted, got %v", result.Items)
+	}
+	divergence, err := src.CheckCutover(dst, "data/")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !divergence.Converged() {
+		t.Fatalf("expected the prefixes to have converged, got %v", divergence)
+	}
+	if _, err := dstBackend.Stat(context.Background(), "other/e", minio.StatObjectOptions{}); nil != err {
+		t.Fatal("expected keys outside the prefix to be kept")
+	}
+}
+
+func TestSyncFailures(t *testing.T) {
+	src, srcBackend := newTestCache(t)
+	dst, _ := newTestCache(t, WithAuthorizer(AuthorizerFunc(func(ctx context.Context, request AuthRequest) error {
+		if AuthPut == request.Op && "data/b" == request.Key {
+			return ErrUnauthorized
+		}
+		return nil
+	})))
+	putString(t, srcBackend, "data/a", "a", minio.PutObjectOptions{})
+	putString(t, srcBackend, "data/b", "b", minio.PutObjectOptions{})
+
+	result, err := src.Sync(dst, "data/", SyncOptions{Batch: BatchOptions{Retries: 1}})
+	batchErr := &ErrBatchFailed{}
+	if !errors.As(err, &batchErr) || !reflect.DeepEqual([]string{"data/b"}, batchErr.Failed) {
+		t.Fatalf("expected data/b to fail, got %v", err)
+	}
+	if !reflect.DeepEqual([]string{"data/b"}, result.Failed()) {
+		t.Fatalf("expected data/b to fail, got %v", result.Failed())
+	}
+
+	payload, err := json.Marshal(result)
+	if nil != err {
+		t.Fatal(err)
+	}
+	decoded := &BatchResult{}
+	if err := json.Unmarshal(payload, decoded); nil != err {
+		t.Fatal(err)
+	}
+	item := decoded.Items[1]
+	if "data/b" != item.Key || BatchFailed != item.Status || 1 != item.Retries || "" == item.Error {
+		t.Fatalf("expected the failure to be serialized, got %+v", item)
+	}
+}
@@ -0,0 +1,103 @@
+package minioproto
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+	"github.com/pkg/errors"
+)
+
+// BucketOptions configures the bucket created by New, the settings are applied idempotently
+// on every start so a fresh environment comes up fully configured. Use WithRegion for the region.
+type BucketOptions struct {
+	// ObjectLocking creates the bucket with object locking, which can't be enabled on existing buckets
+	ObjectLocking bool
+	// Versioning enables versioning, it is never suspended once enabled
+	Versioning bool
+	// Lifecycle replaces the lifecycle configuration when it differs
+	Lifecycle *lifecycle.Configuration
+	// Policy replaces the bucket policy, a JSON document, when it differs
+	Policy string
+}
+
+// WithBucketOptions configures object locking, versioning, lifecycle and policy of the bucket in New.
+// Anonymous clients skip bucket setup.
+func WithBucketOptions(bucketOpts BucketOptions) Option {
+	return func(cache *Cache) {
+		cache.bucketOpts = bucketOpts
+	}
+}
+
+// setupBucket applies the BucketOptions to an existing bucket, changing only what differs
+func (cache *Cache) setupBucket() error {
+	bucketOpts := cache.bucketOpts
+	if bucketOpts.ObjectLocking {
+		if enabled, _, _, _, err := cache.client.GetObjectLockConfig(cache.ctx, cache.bucketName); nil != err || "Enabled" != enabled {
+			cache.logger.Warn(fmt.Sprintf("Object locking is not enabled on bucket=%v, it can only be enabled when the bucket is created", cache.bucketName))
+		}
+	}
+
+	if bucketOpts.Versioning {
+		config, err := cache.client.GetBucketVersioning(cache.ctx, cache.bucketName)
+		if nil != err {
+			return errors.Wrap(err, "Failed to get bucket versioning")
+		}
+		if "Enabled" != config.Status {
+			cache.logger.Info(fmt.Sprintf("Enabling versioning on bucket=%v", cache.bucketName))
+			if err := cache.client.EnableVersioning(cache.ctx, cache.bucketName); nil != err {
+				return errors.Wrap(err, "Failed to enable bucket versioning")
+			}
+		}
+	}
+
+	if nil != bucketOpts.Lifecycle {
+		current, err := cache.client.GetBucketLifecycle(cache.ctx, cache.bucketName)
+		if nil != err && "NoSuchLifecycleConfiguration" != minio.ToErrorResponse(err).Code {
+			return errors.Wrap(err, "Failed to get bucket lifecycle")
+		}
+		if !sameLifecycle(current, bucketOpts.Lifecycle) {
+			cache.logger.Info(fmt.Sprintf("Setting lifecycle on bucket=%v", cache.bucketName))
+			if err := cache.client.SetBucketLifecycle(cache.ctx, cache.bucketName, bucketOpts.Lifecycle); nil != err {
+				return errors.Wrap(err, "Failed to set bucket lifecycle")
+			}
+		}
+	}
+
+	if "" != bucketOpts.Policy {
+		current, err := cache.client.GetBucketPolicy(cache.ctx, cache.bucketName)
+		if nil != err {
+			return errors.Wrap(err, "Failed to get bucket policy")
+		}
+		if !sameJSON(current, bucketOpts.Policy) {
+			cache.logger.Info(fmt.Sprintf("Setting policy on bucket=%v", cache.bucketName))
+			if err := cache.client.SetBucketPolicy(cache.ctx, cache.bucketName, bucketOpts.Policy); nil != err {
+				return errors.Wrap(err, "Failed to set bucket policy")
+			}
+		}
+	}
+	return nil
+}
+
+// sameLifecycle compares lifecycle configurations by their XML encoding
+func sameLifecycle(a, b *lifecycle.Configuration) bool {
+	if a.Empty() || b.Empty() {
+		return a.Empty() == b.Empty()
+	}
+	encodedA, errA := xml.Marshal(a)
+	encodedB, errB := xml.Marshal(b)
+	return nil == errA && nil == errB && bytes.Equal(encodedA, encodedB)
+}
+
+// sameJSON compares JSON documents ignoring formatting and key order
+func sameJSON(a, b string) bool {
+	var documentA, documentB interface{}
+	if nil != json.Unmarshal([]byte(a), &documentA) || nil != json.Unmarshal([]byte(b), &documentB) {
+		return a == b
+	}
+	encodedA, errA := json.Marshal(documentA)
+	encodedB, errB := json.Marshal(documentB)
+	return nil == errA && nil == errB && bytes.Equal(encodedA, encodedB)
+}
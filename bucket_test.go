@@ -0,0 +1,36 @@
+package minioproto
+
+import (
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+	"testing"
+)
+
+func TestSameLifecycle(t *testing.T) {
+	expire := func(id string, days int) *lifecycle.Configuration {
+		return &lifecycle.Configuration{Rules: []lifecycle.Rule{{
+			ID:         id,
+			Status:     "Enabled",
+			Expiration: lifecycle.Expiration{Days: lifecycle.ExpirationDays(days)},
+		}}}
+	}
+	tests := []struct {
+		name     string
+		a, b     *lifecycle.Configuration
+		expected bool
+	}{
+		{"both nil", nil, nil, true},
+		{"nil and empty", nil, lifecycle.NewConfiguration(), true},
+		{"nil and rules", nil, expire("logs", 30), false},
+		{"rules and empty", expire("logs", 30), lifecycle.NewConfiguration(), false},
+		{"same rules", expire("logs", 30), expire("logs", 30), true},
+		{"different days", expire("logs", 30), expire("logs", 7), false},
+		{"different id", expire("logs", 30), expire("tmp", 30), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if output := sameLifecycle(test.a, test.b); output != test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, output)
+			}
+		})
+	}
+}
@@ -3,14 +3,13 @@ package minioproto
 import (
 	"bytes"
 	"context"
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"io/ioutil"
 	"log"
 	"net/url"
@@ -22,42 +21,132 @@ import (
 type Cache struct {
 	ctx        context.Context
 	client     *minio.Client
+	backend    Backend
 	bucketName string
 	logger     *zap.Logger
+
+	verifyContent  bool
+	maxPutSize     int64
+	maxGetSize     int64
+	warnSize       int64
+	spillThreshold int64
+	spillDir       string
+	emptyMode      EmptyPayloadMode
+	canonicalJSON  bool
+	jsonIndent     string
+	jsonCodec      JSONCodec
+
+	strictDecoding bool
+
+	tiers      []Tier
+	tierPolicy TierPolicy
+	diskCache  *DiskCache
+
+	idempotencyPrefix string
+
+	events *eventHooks
+	index  *secondaryIndex
+	keys   KeyProvider
+	signer Signer
+
+	transforms []Transform
+
+	thumbnailSizes []int
+
+	headerRules []headerRule
+
+	cleanup *cleanupConfig
+
+	authorizer Authorizer
+
+	schemas map[protoreflect.FullName]*protoSchema
+
+	spool *spoolState
+
+	packs       *packRegistry
+	descriptors *descriptorRegistry
+
+	snapshot *snapshot
+
+	region       string
+	bucketLookup minio.BucketLookupType
+	bucketOpts   BucketOptions
+
+	dualWrite *dualWriteState
+
+	faults *faultState
+
+	versionID string
+	readInfo  *ReadInfo
+
+	deltaRules []deltaRule
+	deltas     *deltaRegistry
+
+	profiles []Profile
+	quotas   *quotaUsage
+
+	cancel       context.CancelFunc
+	callTags     map[string]string
+	tenantPrefix string
+	attributes   map[string]string
 }
 
 // NewFromURL creates a new instance using a connection url:
-// > http(s)://<user>:<password>@<host>/<bucket>?token=<token>
-func NewFromURL(ctx context.Context, logger *zap.Logger, connectionURL string) (*Cache, error) {
+// > http(s)://[<user>:<password>@]<host>/<bucket>?token=<token>&region=<region>&lookup=<path|virtual-host>&secure=<bool>
+// URLs without credentials use anonymous access, e.g. for public read-only buckets.
+// file:///<dir> urls store objects on the local filesystem with NewFileBackend.
+func NewFromURL(ctx context.Context, logger *zap.Logger, connectionURL string, opts ...Option) (*Cache, error) {
 	config, err := url.Parse(connectionURL)
 	if nil != err {
 		err := errors.New("Failed to parse connection url")
 		logger.Error(err.Error())
 		return nil, err
 	}
+	if "file" == config.Scheme {
+		return NewWithBackend(ctx, logger, NewFileBackend(config.Path), opts...)
+	}
 
-	useSSL := config.Scheme == "https"
+	query := config.Query()
+	useSSL, err := urlSecure(config.Scheme, query)
+	if nil != err {
+		logger.Error(err.Error())
+		return nil, err
+	}
 	address := config.Host
 	accessKey := config.User.Username()
 	accessSecret, _ := config.User.Password()
-	bucketName := config.Path
-	if strings.HasSuffix(bucketName, "/") {
-		bucketName = bucketName[1:]
+	bucketName := strings.Trim(config.Path, "/")
+	token := query.Get("token")
+
+	// Options from the url come first so explicit options take precedence
+	urlOpts, err := urlOptions(query)
+	if nil != err {
+		logger.Error(err.Error())
+		return nil, err
 	}
-	token := config.Query().Get("token")
+	opts = append(urlOpts, opts...)
 
-	return New(ctx, logger, bucketName, address, accessKey, accessSecret, token, useSSL)
+	return New(ctx, logger, bucketName, address, accessKey, accessSecret, token, useSSL, opts...)
 }
 
-// New creates a Cache instance using the given configuration
-func New(ctx context.Context, logger *zap.Logger, bucketName, address, accessKey, accessSecret, token string, useSSL bool) (*Cache, error) {
+// New creates a Cache instance using the given configuration, an empty accessKey uses anonymous access
+func New(ctx context.Context, logger *zap.Logger, bucketName, address, accessKey, accessSecret, token string, useSSL bool, opts ...Option) (*Cache, error) {
 	logger.Info(fmt.Sprintf("Connecting to minio server address=%v with bucket=%v", address, bucketName))
+	output := newCache(ctx, logger, bucketName, opts)
 
-	// Configure the client connection
-	creds := credentials.NewStaticV4(accessKey, accessSecret, token)
+	// Configure the client connection, conditional writes share its transport
+	transport, err := minio.DefaultTransport(useSSL)
+	if nil != err {
+		err = errors.Wrap(err, "Failed to create transport")
+		logger.Error(err.Error())
+		return nil, err
+	}
 	options := minio.Options{
-		Creds:  creds,
-		Secure: useSSL,
+		Creds:        credentials.NewStaticV4(accessKey, accessSecret, token),
+		Secure:       useSSL,
+		Transport:    transport,
+		Region:       output.region,
+		BucketLookup: output.bucketLookup,
 	}
 	client, err := minio.New(address, &options)
 	if err != nil {
@@ -65,32 +154,86 @@ func New(ctx context.Context, logger *zap.Logger, bucketName, address, accessKey
 		logger.Error(err.Error())
 		return nil, err
 	}
+	output.client = client
+	if output.backend, err = NewMinioBackend(client, bucketName, options); nil != err {
+		logger.Error(err.Error())
+		return nil, err
+	}
 
-	// Initialize the bucket
-	logger.Info(fmt.Sprintf("Initalizing bucket=%v", bucketName))
-	err = client.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
-	if err != nil {
-		// Check to see if we already own this bucket (which happens if you run this twice)
-		exists, errBucketExists := client.BucketExists(ctx, bucketName)
-		if errBucketExists == nil && exists {
-			log.Printf("We already own %s\n", bucketName)
-			logger.Info(fmt.Sprintf("Bucket already exists, bucket=%v", bucketName))
+	// Initialize the bucket, anonymous clients cannot create buckets
+	if "" == accessKey {
+		logger.Info(fmt.Sprintf("Using anonymous access, skipping initialization of bucket=%v", bucketName))
+	} else {
+		logger.Info(fmt.Sprintf("Initalizing bucket=%v", bucketName))
+		makeOpts := minio.MakeBucketOptions{Region: output.region, ObjectLocking: output.bucketOpts.ObjectLocking}
+		err = client.MakeBucket(ctx, bucketName, makeOpts)
+		if err != nil {
+			// Check to see if we already own this bucket (which happens if you run this twice)
+			exists, errBucketExists := client.BucketExists(ctx, bucketName)
+			if errBucketExists == nil && exists {
+				log.Printf("We already own %s\n", bucketName)
+				logger.Info(fmt.Sprintf("Bucket already exists, bucket=%v", bucketName))
+			} else {
+				err = errors.Wrap(err, fmt.Sprintf("Failed to create bucket %v", bucketName))
+				logger.Error(err.Error())
+				return nil, err
+			}
 		} else {
-			err = errors.Wrap(err, fmt.Sprintf("Failed to create bucket %v", bucketName))
+			logger.Info(fmt.Sprintf("Bucket created=%v", bucketName))
+		}
+
+		if err := output.setupBucket(); nil != err {
+			err = errors.Wrap(err, fmt.Sprintf("Failed to configure bucket %v", bucketName))
 			logger.Error(err.Error())
 			return nil, err
 		}
-	} else {
-		logger.Info(fmt.Sprintf("Bucket created=%v", bucketName))
 	}
 
+	output.start()
+	return output, nil
+}
+
+// NewWithBackend creates a Cache storing objects in backend, e.g. NewFileBackend. Minio specific
+// operations such as bucket setup and multipart upload cleanup aren't available.
+func NewWithBackend(ctx context.Context, logger *zap.Logger, backend Backend, opts ...Option) (*Cache, error) {
+	logger.Info("Using custom storage backend")
+	output := newCache(ctx, logger, "", opts)
+	output.backend = backend
+	output.start()
+	return output, nil
+}
+
+// newCache creates a Cache without a backend and applies the options
+func newCache(ctx context.Context, logger *zap.Logger, bucketName string, opts []Option) *Cache {
 	output := &Cache{
 		ctx:        ctx,
-		client:     client,
 		logger:     logger,
 		bucketName: bucketName,
+		jsonCodec:  StdJSONCodec{},
+
+		events:      &eventHooks{},
+		packs:       &packRegistry{packs: map[string]*packState{}},
+		descriptors: &descriptorRegistry{},
+		dualWrite:   &dualWriteState{},
+		deltas:      newDeltaRegistry(),
+		quotas:      &quotaUsage{prefixes: map[string]*quotaCounter{}},
+	}
+	for _, opt := range opts {
+		opt(output)
+	}
+	return output
+}
+
+// start runs the background work of a connected Cache
+func (cache *Cache) start() {
+	if nil != cache.spool {
+		go cache.runSpool()
+	}
+	if nil != cache.cleanup {
+		if _, err := cache.CleanupIncompleteUploads(cache.cleanup.prefix, cache.cleanup.olderThan); nil != err {
+			cache.logger.Warn(fmt.Sprintf("Failed to clean up incomplete uploads: %v", err.Error()))
+		}
 	}
-	return output, nil
 }
 
 //
@@ -115,32 +258,61 @@ func (cache *Cache) CSVExists(path string, opts minio.StatObjectOptions) (*minio
 	return cache.DataExists(path, opts)
 }
 
+// TSVExists checks if TSV file exists in minio
+func (cache *Cache) TSVExists(path string, opts minio.StatObjectOptions) (*minio.ObjectInfo, error) {
+	path = pathFix(path, tsvContentType)
+	return cache.DataExists(path, opts)
+}
+
 //
 // Readers
 //
 
 // GetPROTO reads a PROTO file from minio
 func (cache *Cache) GetPROTO(path string, data proto.Message, unmarshalOpts *proto.UnmarshalOptions, opts minio.GetObjectOptions) error {
+	if err := cache.checkMessage(data); nil != err {
+		return err
+	}
 	path = pathFix(path, protobufContentType)
 	cache.logger.Info(fmt.Sprintf("Reading PROTO file, path=%v", path))
-	payload, err := cache.ReadData(path, opts)
+	payload, metadata, err := cache.readPayload(path, opts)
 	if nil != err {
 		err = errors.Wrap(err, "Failed to fetch Proto file")
 		cache.logger.Error(err.Error())
 		return err
 	}
 
+	if payload, err = cache.resolveDelta(path, payload, metadata); nil != err {
+		return err
+	}
+	if empty, err := cache.checkEmpty(path, payload); empty {
+		proto.Reset(data)
+		return err
+	}
+	if payload, err = cache.migratePROTO(path, data, payload, metadata); nil != err {
+		return err
+	}
+
 	// Deserialize to Proto
+	if cache.strictDecoding {
+		unmarshalOpts = strictUnmarshalOptions(unmarshalOpts)
+	}
 	if nil != unmarshalOpts {
 		err = unmarshalOpts.Unmarshal(payload, data)
 	} else {
 		err = proto.Unmarshal(payload, data)
 	}
+	if nil == err && cache.strictDecoding {
+		err = checkStrictPROTO(data)
+	}
 	if nil != err {
 		err = errors.Wrap(err, "Failed deserialize data to protobuf")
 		cache.logger.Error(err.Error())
 		return err
 	}
+	if _, err = cache.transformPROTO(path, TransformOnGet, data); nil != err {
+		return err
+	}
 
 	cache.logger.Info(fmt.Sprintf("Success reading path=%v", path))
 	return nil
@@ -150,15 +322,39 @@ func (cache *Cache) GetPROTO(path string, data proto.Message, unmarshalOpts *pro
 func (cache *Cache) GetJSON(path string, output interface{}, opts minio.GetObjectOptions) error {
 	path = pathFix(path, jsonContentType)
 	cache.logger.Info(fmt.Sprintf("Reading Json file, path=%v", path))
-	data, err := cache.ReadData(path, opts)
+	reader, data, err := cache.openData(path, opts)
 	if nil != err {
 		err = errors.Wrap(err, "Failed to fetch JSON file")
 		cache.logger.Error(err.Error())
 		return err
 	}
+	defer reader.Close()
+
+	// Spilled payloads are decoded from disk unless a transform or codec needs the bytes
+	codec, streaming := cache.jsonDecoder().(JSONStreamCodec)
+	if nil == data && (!streaming || 0 != len(cache.matchingTransforms(path, TransformOnGet))) {
+		if data, err = ioutil.ReadAll(reader); nil != err {
+			err = errors.Wrap(err, "Failed to read JSON file")
+			cache.logger.Error(err.Error())
+			return err
+		}
+	}
 
 	// Deserialize to JSON
-	err = json.Unmarshal(data, &output)
+	if nil == data {
+		err = codec.Decode(reader, &output)
+	} else {
+		if empty, err := cache.checkEmpty(path, data); empty {
+			return err
+		}
+		if data, err = cache.transformJSON(path, TransformOnGet, data); nil != err {
+			return err
+		}
+		err = cache.jsonDecoder().Unmarshal(data, &output)
+	}
+	if nil != err && cache.strictDecoding {
+		err = strictJSONError(err)
+	}
 	if nil != err {
 		err = errors.Wrap(err, "Failed deserialize data from json")
 		cache.logger.Error(err.Error())
@@ -170,27 +366,15 @@ func (cache *Cache) GetJSON(path string, output interface{}, opts minio.GetObjec
 }
 
 // GetCSV reads a CSV file from minio
-func (cache *Cache) GetCSV(path string, opts minio.GetObjectOptions) ([][]string, error) {
+func (cache *Cache) GetCSV(path string, csvOpts *CSVOptions, opts minio.GetObjectOptions) ([][]string, error) {
 	path = pathFix(path, csvContentType)
-	cache.logger.Info(fmt.Sprintf("Reading CSV file, path=%v", path))
-	data, err := cache.ReadData(path, opts)
-	if nil != err {
-		err = errors.Wrap(err, "Failed to fetch CSV")
-		cache.logger.Error(err.Error())
-		return nil, err
-	}
-
-	buf := bytes.NewReader(data)
-	reader := csv.NewReader(buf)
-	output, err := reader.ReadAll()
-	if nil != err {
-		err = errors.Wrap(err, "Failed deserialize data from CSV")
-		cache.logger.Error(err.Error())
-		return nil, err
-	}
+	return cache.readDelimited(path, csvOpts, opts)
+}
 
-	cache.logger.Info(fmt.Sprintf("Success reading path=%v", path))
-	return output, err
+// GetTSV reads a TSV file from minio
+func (cache *Cache) GetTSV(path string, csvOpts *CSVOptions, opts minio.GetObjectOptions) ([][]string, error) {
+	path = pathFix(path, tsvContentType)
+	return cache.readDelimited(path, csvOpts.withDelimiter('\t'), opts)
 }
 
 //
@@ -198,9 +382,16 @@ func (cache *Cache) GetCSV(path string, opts minio.GetObjectOptions) ([][]string
 //
 
 // PutPROTO writes a PROTO file to minio
-func (cache *Cache) PutPROTO(path string, data proto.Message, marshalOpts *proto.MarshalOptions, opts minio.PutObjectOptions) error {
+func (cache *Cache) PutPROTO(path string, data proto.Message, marshalOpts *proto.MarshalOptions, opts minio.PutObjectOptions) (*WriteResult, error) {
+	if err := cache.checkMessage(data); nil != err {
+		return nil, err
+	}
+	path = pathFix(path, protobufContentType)
+	data, err := cache.transformPROTO(path, TransformOnPut, data)
+	if nil != err {
+		return nil, err
+	}
 	var payload []byte
-	var err error
 	// Serialize to Proto
 	if nil != marshalOpts {
 		payload, err = marshalOpts.Marshal(data)
@@ -211,50 +402,44 @@ func (cache *Cache) PutPROTO(path string, data proto.Message, marshalOpts *proto
 	if nil != err {
 		err = errors.Wrap(err, "Failed serialize data to protobuf")
 		cache.logger.Error(err.Error())
-		return err
+		return nil, err
 	}
 	// Write the data
+	opts = cache.withSchemaVersion(data, opts)
 	opts.ContentType = protobufContentType
 	path = pathFix(path, opts.ContentType)
+	if deltaOpts := cache.deltaFor(path); nil != deltaOpts {
+		return cache.putDelta(path, payload, deltaOpts, opts)
+	}
 	return cache.WriteData(path, payload, opts)
 }
 
 // PutJSON writes a JSON file to minio
-func (cache *Cache) PutJSON(path string, data interface{}, opts minio.PutObjectOptions) error {
+func (cache *Cache) PutJSON(path string, data interface{}, opts minio.PutObjectOptions) (*WriteResult, error) {
 	// Serialize to JSON
-	payload, err := json.Marshal(data)
+	payload, err := cache.marshalJSON(data)
 	if nil != err {
 		err = errors.Wrap(err, "Failed serialize data as json")
 		cache.logger.Error(err.Error())
-		return err
+		return nil, err
 	}
 	// Write the data
 	opts.ContentType = jsonContentType
 	path = pathFix(path, opts.ContentType)
+	if payload, err = cache.transformJSON(path, TransformOnPut, payload); nil != err {
+		return nil, err
+	}
 	return cache.WriteData(path, payload, opts)
 }
 
 // PutCSV writes a CSV file to minio
-func (cache *Cache) PutCSV(path string, records [][]string, opts minio.PutObjectOptions) error {
-	// Serialize the CSV to bytes
-	buf := &bytes.Buffer{}
-	writer := csv.NewWriter(buf)
-	if err := writer.WriteAll(records); nil != err {
-		err = errors.Wrap(err, "Failed serialize data as CSV")
-		cache.logger.Error(err.Error())
-		return err
-	}
+func (cache *Cache) PutCSV(path string, records [][]string, csvOpts *CSVOptions, opts minio.PutObjectOptions) (*WriteResult, error) {
+	return cache.writeDelimited(path, records, csvOpts, csvContentType, opts)
+}
 
-	payload, err := ioutil.ReadAll(buf)
-	if nil != err {
-		err = errors.Wrap(err, "Failed read bytes from buffer")
-		cache.logger.Error(err.Error())
-		return err
-	}
-	// Write the data
-	opts.ContentType = csvContentType
-	path = pathFix(path, opts.ContentType)
-	return cache.WriteData(path, payload, opts)
+// PutTSV writes a TSV file to minio
+func (cache *Cache) PutTSV(path string, records [][]string, opts minio.PutObjectOptions) (*WriteResult, error) {
+	return cache.writeDelimited(path, records, (*CSVOptions)(nil).withDelimiter('\t'), tsvContentType, opts)
 }
 
 //
@@ -263,7 +448,18 @@ func (cache *Cache) PutCSV(path string, records [][]string, opts minio.PutObject
 
 // DataExists checks to see if the given path exists
 func (cache *Cache) DataExists(path string, opts minio.StatObjectOptions) (*minio.ObjectInfo, error) {
-	data, err := cache.client.StatObject(cache.ctx, cache.bucketName, path, opts)
+	path = cache.objectKey(path)
+	if err := cache.authorize(AuthStat, path, nil); nil != err {
+		return nil, err
+	}
+	opts, err := cache.resolveVersion(path, opts)
+	if nil != err {
+		return nil, err
+	}
+	data := minio.ObjectInfo{}
+	if err = cache.injectFault(AuthStat, path); nil == err {
+		data, err = cache.backend.Stat(cache.ctx, path, opts)
+	}
 	if nil != err {
 		cache.logger.Info(fmt.Sprintf("Object doesnt exist in cache at path=%v", path))
 		return nil, nil
@@ -273,42 +469,255 @@ func (cache *Cache) DataExists(path string, opts minio.StatObjectOptions) (*mini
 
 // ReadData reads the raw bytes from the minio Cache
 func (cache *Cache) ReadData(path string, opts minio.GetObjectOptions) ([]byte, error) {
+	data, _, err := cache.readPayload(path, opts)
+	return data, err
+}
+
+// readPayload reads the raw bytes like ReadData along with the user metadata of the object they were read from
+func (cache *Cache) readPayload(path string, opts minio.GetObjectOptions) ([]byte, map[string]string, error) {
+	data, metadata, err := cache.readData(path, opts, true)
+	if nil != err {
+		return nil, nil, err
+	}
+	cache.fire(Event{Op: EventGet, Path: cache.objectKey(path), Size: int64(len(data)), VersionID: opts.VersionID})
+	return data, metadata, nil
+}
+
+// readData reads the raw bytes and the user metadata of the object, consulting the local tiers and disk
+// cache only when allowLocal is set
+func (cache *Cache) readData(path string, opts minio.GetObjectOptions, allowLocal bool) ([]byte, map[string]string, error) {
+	path = cache.objectKey(path)
 	cache.logger.Info(fmt.Sprintf("Reading path=%v", path))
+	if err := cache.authorize(AuthGet, path, nil); nil != err {
+		return nil, nil, err
+	}
+	opts, err := cache.resolveVersion(path, opts)
+	if nil != err {
+		return nil, nil, err
+	}
+	profile := cache.profileFor(path)
+	expires := nil != profile && profile.TTL > 0
+	useLocal := allowLocal && cache.hasLocal() && tierable(opts) && !expires
+	if useLocal {
+		if entry, ok := cache.readLocal(path); ok {
+			cache.recordRead(path, minio.ObjectInfo{})
+			data, err := cache.openPayload(path, entry.Data, entry.Envelope, opts)
+			return data, entry.Metadata, err
+		}
+	}
 
-	obj, err := cache.client.GetObject(cache.ctx, cache.bucketName, path, opts)
+	if err := cache.injectFault(AuthGet, path); nil != err {
+		err = errors.Wrap(err, "Failed to get file")
+		cache.logger.Error(err.Error())
+		return nil, nil, err
+	}
+	obj, err := cache.backend.Get(cache.ctx, path, opts)
 	if nil != err {
 		err = errors.Wrap(err, "Failed to get file")
 		cache.logger.Error(err.Error())
-		return nil, err
+		return nil, nil, err
+	}
+	defer obj.Close()
+
+	if expires {
+		info, err := obj.Stat()
+		if nil != err {
+			err = errors.Wrap(err, "Failed to stat file")
+			cache.logger.Error(err.Error())
+			return nil, nil, err
+		}
+		if err := cache.checkExpired(path, info.UserMetadata); nil != err {
+			return nil, nil, err
+		}
+	}
+
+	if cache.maxGetSize > 0 || cache.warnSize > 0 {
+		info, err := obj.Stat()
+		if nil != err {
+			err = errors.Wrap(err, "Failed to stat file")
+			cache.logger.Error(err.Error())
+			return nil, nil, err
+		}
+		if err := cache.checkGetSize(path, info.Size); nil != err {
+			return nil, nil, err
+		}
 	}
 
-	data, err := ioutil.ReadAll(obj)
+	data, err := cache.readLimited(path, obj)
 	if nil != err {
 		err = errors.Wrap(err, "Failed to read file")
-		return nil, err
+		return nil, nil, err
+	}
+	data = cache.truncateFault(path, data)
+	if nil != cache.readInfo {
+		if info, err := obj.Stat(); nil == err {
+			cache.recordRead(path, info)
+		}
+	}
+
+	if nil != cache.signer && "" == opts.Header().Get("Range") {
+		info, err := obj.Stat()
+		if nil != err {
+			err = errors.Wrap(err, "Failed to stat file")
+			cache.logger.Error(err.Error())
+			return nil, nil, err
+		}
+		if err := cache.verifyPayload(path, data, info); nil != err {
+			return nil, nil, err
+		}
+	}
+
+	info, err := obj.Stat()
+	if nil != err {
+		err = errors.Wrap(err, "Failed to stat file")
+		cache.logger.Error(err.Error())
+		return nil, nil, err
+	}
+	envelopes := info.UserMetadata[envelopeMetadata]
+	if useLocal {
+		cache.storeLocal(path, &TierEntry{Data: data, ETag: info.ETag, Envelope: envelopes, Metadata: info.UserMetadata})
 	}
 
 	cache.logger.Info(fmt.Sprintf("Successfully read bytes: %v", len(data)))
-	return data, nil
+	data, err = cache.openPayload(path, data, envelopes, opts)
+	return data, info.UserMetadata, err
 }
 
-// WriteData writes the raw bytes from the minio Cache
-func (cache *Cache) WriteData(path string, data []byte, opts minio.PutObjectOptions) error {
+// WriteData writes the raw bytes from the minio Cache, returning a description of the stored object
+func (cache *Cache) WriteData(path string, data []byte, opts minio.PutObjectOptions) (*WriteResult, error) {
+	path = cache.objectKey(path)
+	opts = cache.withCallTags(opts)
 	cache.logger.Info(fmt.Sprintf("Writing path=%v with %v bytes", path, len(data)))
+	if err := cache.checkPutSize(path, int64(len(data))); nil != err {
+		return nil, err
+	}
+	data, err := cache.compressForPath(path, data, opts)
+	if nil != err {
+		return nil, err
+	}
+	if err := cache.authorize(AuthPut, path, opts.UserMetadata); nil != err {
+		return nil, err
+	}
+	data, opts, err = cache.applyProfile(path, data, opts)
+	if nil != err {
+		return nil, err
+	}
+
+	idempotency := idempotencyKey(opts)
+	var payloadHash string
+	if "" != idempotency {
+		result, hash, err := cache.checkIdempotency(path, idempotency, data)
+		if nil != err || nil != result {
+			return result, err
+		}
+		payloadHash = hash
+		opts = withUserMetadata(opts, payloadHashMetadata, payloadHash)
+	}
+
+	data, opts, err = cache.sealPayload(path, data, opts)
+	if nil != err {
+		return nil, err
+	}
+	opts, err = cache.signPayload(path, data, opts)
+	if nil != err {
+		return nil, err
+	}
+	quota, err := cache.checkQuota(path, int64(len(data)))
+	if nil != err {
+		return nil, err
+	}
+
+	if cache.verifyContent {
+		opts.SendContentMd5 = true
+	}
+	opts = cache.applyHeaders(path, opts)
 
 	reader := bytes.NewReader(data)
-	uploadInfo, err := cache.client.PutObject(cache.ctx, cache.bucketName, path, reader, reader.Size(), opts)
+	uploadInfo := minio.UploadInfo{}
+	if err = cache.injectFault(AuthPut, path); nil == err {
+		uploadInfo, err = cache.backend.Put(cache.ctx, path, reader, reader.Size(), opts)
+	}
 	if nil != err {
-		return err
+		if cache.spoolable(err, opts) {
+			return cache.spoolWrite(path, data, opts, err)
+		}
+		return nil, err
+	}
+
+	if cache.verifyContent {
+		if err := cache.verifyUpload(path, data, uploadInfo); nil != err {
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
 	}
 
+	cache.recordQuota(path, quota)
+	result := newWriteResult(uploadInfo)
+	if "" != idempotency {
+		if err := cache.recordIdempotency(path, idempotency, payloadHash, result); nil != err {
+			return nil, err
+		}
+	}
+	cache.finishWrite(path, data, opts, result)
+
 	cache.logger.Info(fmt.Sprintf("Successfully uploaded bytes: %v", uploadInfo.Size))
+	return result, nil
+}
+
+// finishWrite updates the local tiers, index and dual write destination and fires the put event of a stored payload
+func (cache *Cache) finishWrite(path string, data []byte, opts minio.PutObjectOptions, result *WriteResult) {
+	if cache.hasLocal() {
+		cache.writeLocal(path, &TierEntry{Data: data, ETag: result.ETag, Envelope: opts.UserMetadata[envelopeMetadata], Metadata: opts.UserMetadata})
+	}
+	if cache.indexes(path) {
+		if err := cache.updateIndex(path, opts); nil != err {
+			cache.logger.Warn(fmt.Sprintf("Failed to update index for path=%v: %v", path, err.Error()))
+		}
+	}
+	cache.mirrorWrite(path, data, opts)
+	cache.firePut(path, result)
+}
+
+// DeleteData removes the object at path from the minio Cache
+func (cache *Cache) DeleteData(path string, opts minio.RemoveObjectOptions) error {
+	path = cache.objectKey(path)
+	cache.logger.Info(fmt.Sprintf("Deleting path=%v", path))
+	if err := cache.authorize(AuthDelete, path, nil); nil != err {
+		return err
+	}
+
+	err := cache.injectFault(AuthDelete, path)
+	if nil == err {
+		err = cache.backend.Delete(cache.ctx, path, opts)
+	}
+	if nil != err {
+		err = errors.Wrap(err, "Failed to delete file")
+		cache.logger.Error(err.Error())
+		return err
+	}
+	cache.invalidateTiers(path)
+	cache.releaseQuota(path)
+	if cache.indexes(path) {
+		if err := cache.removeFromIndex(path); nil != err {
+			cache.logger.Warn(fmt.Sprintf("Failed to update index for path=%v: %v", path, err.Error()))
+		}
+	}
+	cache.mirrorDelete(path, opts)
+	cache.fire(Event{Op: EventDelete, Path: path, VersionID: opts.VersionID})
+
+	cache.logger.Info(fmt.Sprintf("Successfully deleted path=%v", path))
 	return nil
 }
 
 const jsonContentType = "application/json"
 const csvContentType = "text/csv"
 const protobufContentType = "application/x-protobuf"
+const tsvContentType = "text/tab-separated-values"
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+const gobContentType = "application/x-gob"
+const pngContentType = "image/png"
+const jpegContentType = "image/jpeg"
+const gifContentType = "image/gif"
 
 var defaultExtensions map[string]string
 
@@ -317,15 +726,36 @@ func init() {
 		jsonContentType:     "json",
 		csvContentType:      "csv",
 		protobufContentType: "pb",
+		tsvContentType:      "tsv",
+		xlsxContentType:     "xlsx",
+		gobContentType:      "gob",
+		pngContentType:      "png",
+		jpegContentType:     "jpg",
+		gifContentType:      "gif",
 	}
 }
 
+// pathFix appends the extension of contentType to path unless present, compound extensions such as .csv.gz are kept
 func pathFix(path, contentType string) string {
-	ext := filepath.Ext(path)[1:]
+	ext := strings.TrimPrefix(filepath.Ext(trimCompression(path)), ".")
 
 	expected, ok := defaultExtensions[contentType]
 	if !ok || ext == expected {
 		return path
 	}
+	if trimmed := trimCompression(path); trimmed != path {
+		return fmt.Sprintf("%v.%v%v", trimmed, expected, filepath.Ext(path))
+	}
 	return fmt.Sprintf("%v.%v", path, expected)
 }
+
+// contentTypeForPath returns the content type matching the extension of path, or "" if unknown
+func contentTypeForPath(path string) string {
+	ext := strings.TrimPrefix(filepath.Ext(trimCompression(path)), ".")
+	for contentType, expected := range defaultExtensions {
+		if ext == expected {
+			return contentType
+		}
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+package minioproto
+
+import (
+	"context"
+	"github.com/minio/minio-go/v7"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CallOption configures the defaults of a view returned by With
+type CallOption func(*Cache)
+
+// CallTimeout bounds every operation of the view by a deadline starting when With is called,
+// call Release on the view once it is no longer used to free the timer
+func CallTimeout(timeout time.Duration) CallOption {
+	return func(cache *Cache) {
+		cache.ctx, cache.cancel = context.WithTimeout(cache.ctx, timeout)
+	}
+}
+
+// CallContext runs every operation of the view with ctx, e.g. the context of an incoming request
+func CallContext(ctx context.Context) CallOption {
+	return func(cache *Cache) {
+		cache.ctx = ctx
+	}
+}
+
+// CallTags adds object tags to every WriteData of the view, tags set on the PutObjectOptions take precedence
+func CallTags(tags map[string]string) CallOption {
+	return func(cache *Cache) {
+		merged := map[string]string{}
+		for k, v := range cache.callTags {
+			merged[k] = v
+		}
+		for k, v := range tags {
+			merged[k] = v
+		}
+		cache.callTags = merged
+	}
+}
+
+// CallTenant prefixes every key read, written, stat'ed, deleted or listed through the view with
+// <prefix>/, including the keys taken by helpers such as Concat, archives, uploads, downloads, merges,
+// migrations, key rotation and ranged reads. Listings and queries return keys relative to it, so
+// they can be passed back to the view. Nested tenants are joined.
+func CallTenant(prefix string) CallOption {
+	return func(cache *Cache) {
+		cache.tenantPrefix += strings.Trim(prefix, "/") + "/"
+	}
+}
+
+// CallAttributes adds tracing attributes to the log lines and Events of the view
+func CallAttributes(attributes map[string]string) CallOption {
+	return func(cache *Cache) {
+		merged := map[string]string{}
+		for k, v := range cache.attributes {
+			merged[k] = v
+		}
+		keys := make([]string, 0, len(attributes))
+		for k, v := range attributes {
+			merged[k] = v
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fields := make([]zap.Field, len(keys))
+		for i, k := range keys {
+			fields[i] = zap.String(k, attributes[k])
+		}
+		cache.attributes = merged
+		cache.logger = cache.logger.With(fields...)
+	}
+}
+
+// With returns a lightweight view of the Cache carrying default call options. The view shares the
+// client, hooks, tiers and registries of the Cache, so request handlers can derive customized handles
+// from a single shared Cache without mutating it. Views of views inherit their defaults.
+func (cache *Cache) With(opts ...CallOption) *Cache {
+	output := cache.view()
+	output.cancel = nil
+	for _, opt := range opts {
+		opt(output)
+	}
+	return output
+}
+
+// Release frees the deadline of a view created with CallTimeout, pending operations are cancelled
+func (cache *Cache) Release() {
+	if nil != cache.cancel {
+		cache.cancel()
+	}
+}
+
+// objectKey applies the tenant prefix of the view to a path relative to it
+func (cache *Cache) objectKey(path string) string {
+	if "" == cache.tenantPrefix {
+		return path
+	}
+	return cache.tenantPrefix + strings.TrimPrefix(path, "/")
+}
+
+// relativeKey strips the tenant prefix of the view from key
+func (cache *Cache) relativeKey(key string) string {
+	return strings.TrimPrefix(key, cache.tenantPrefix)
+}
+
+// withCallTags merges the tags of the view into opts
+func (cache *Cache) withCallTags(opts minio.PutObjectOptions) minio.PutObjectOptions {
+	if 0 == len(cache.callTags) {
+		return opts
+	}
+	tags := map[string]string{}
+	for k, v := range cache.callTags {
+		tags[k] = v
+	}
+	for k, v := range opts.UserTags {
+		tags[k] = v
+	}
+	opts.UserTags = tags
+	return opts
+}
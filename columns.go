@@ -0,0 +1,178 @@
+package minioproto
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// GetCSVColumns reads only the named columns of a CSV file, keyed by the header name.
+// Records are parsed one at a time and values of other columns are never retained.
+func (cache *Cache) GetCSVColumns(path string, columns []string, csvOpts *CSVOptions, opts minio.GetObjectOptions) (map[string][]string, error) {
+	output := make(map[string][]string, len(columns))
+	for _, name := range columns {
+		output[name] = []string{}
+	}
+	err := cache.readColumns(path, columns, csvOpts, opts, func(values []string) error {
+		for i, name := range columns {
+			output[name] = append(output[name], values[i])
+		}
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return output, nil
+}
+
+// GetCSVInto reads a CSV file into output, a pointer to a slice of structs.
+// Only the columns named by `csv:"name"` field tags are parsed, supporting string, bool, int, uint and float fields.
+func (cache *Cache) GetCSVInto(path string, output interface{}, csvOpts *CSVOptions, opts minio.GetObjectOptions) error {
+	slice := reflect.ValueOf(output)
+	if reflect.Ptr != slice.Kind() || reflect.Slice != slice.Elem().Kind() || reflect.Struct != slice.Elem().Type().Elem().Kind() {
+		err := errors.New("GetCSVInto requires a pointer to a slice of structs")
+		cache.logger.Error(err.Error())
+		return err
+	}
+	slice = slice.Elem()
+	elemType := slice.Type().Elem()
+
+	columns := []string{}
+	fields := []int{}
+	for i := 0; i < elemType.NumField(); i++ {
+		name, ok := elemType.Field(i).Tag.Lookup("csv")
+		if !ok || "-" == name || "" != elemType.Field(i).PkgPath {
+			continue
+		}
+		columns = append(columns, name)
+		fields = append(fields, i)
+	}
+
+	rows := reflect.MakeSlice(slice.Type(), 0, 0)
+	err := cache.readColumns(path, columns, csvOpts, opts, func(values []string) error {
+		row := reflect.New(elemType).Elem()
+		for i, index := range fields {
+			if err := setCSVField(row.Field(index), values[i]); nil != err {
+				return errors.Wrap(err, fmt.Sprintf("Failed to parse column %v", columns[i]))
+			}
+		}
+		rows = reflect.Append(rows, row)
+		return nil
+	})
+	if nil != err {
+		return err
+	}
+	slice.Set(rows)
+	return nil
+}
+
+// readColumns parses a CSV file record by record, passing the values of the named columns to fn
+func (cache *Cache) readColumns(path string, columns []string, csvOpts *CSVOptions, opts minio.GetObjectOptions, fn func([]string) error) error {
+	path = pathFix(path, csvContentType)
+	cache.logger.Info(fmt.Sprintf("Reading CSV columns %v, path=%v", columns, path))
+	body, data, err := cache.openData(path, opts)
+	if nil != err {
+		err = errors.Wrap(err, "Failed to fetch CSV")
+		cache.logger.Error(err.Error())
+		return err
+	}
+	defer body.Close()
+	if nil != data {
+		if empty, err := cache.checkEmpty(path, data); empty {
+			return err
+		}
+	}
+
+	reader := csvOpts.configureReader(csv.NewReader(body))
+	reader.ReuseRecord = true
+	if err := csvOpts.skipRows(reader); io.EOF == err {
+		return nil
+	} else if nil != err {
+		err = errors.Wrap(err, "Failed deserialize data from CSV")
+		cache.logger.Error(err.Error())
+		return err
+	}
+
+	var indexes []int
+	values := make([]string, len(columns))
+	for {
+		record, err := reader.Read()
+		if io.EOF == err {
+			break
+		}
+		if nil != err {
+			err = errors.Wrap(err, "Failed deserialize data from CSV")
+			cache.logger.Error(err.Error())
+			return err
+		}
+
+		if nil == indexes {
+			if indexes, err = keyIndexes(record, columns); nil != err {
+				cache.logger.Error(err.Error())
+				return err
+			}
+			if nil == indexes {
+				indexes = []int{}
+			}
+			continue
+		}
+
+		for i, index := range indexes {
+			// Copy the value so the rest of the record can be released
+			values[i] = string([]byte(column(record, index)))
+		}
+		if err = fn(values); nil != err {
+			cache.logger.Error(err.Error())
+			return err
+		}
+	}
+
+	cache.logger.Info(fmt.Sprintf("Success reading path=%v", path))
+	return nil
+}
+
+// setCSVField parses value into a struct field
+func setCSVField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+		return nil
+	}
+	if "" == value {
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if nil != err {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if nil != err {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if nil != err {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if nil != err {
+			return err
+		}
+		field.SetFloat(parsed)
+	default:
+		return errors.New(fmt.Sprintf("Unsupported field type %v", field.Type()))
+	}
+	return nil
+}
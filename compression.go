@@ -0,0 +1,110 @@
+package minioproto
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Compressor compresses payloads stored under keys with a compound extension such as .csv.gz
+type Compressor struct {
+	// Extension is the suffix appended after the format extension, e.g. "gz" or "zst"
+	Extension string
+	// Encoding is the Content-Encoding of payloads the caller already compressed, e.g. "gzip", which are stored as is
+	Encoding string
+	// NewWriter wraps output with a compressing writer
+	NewWriter func(output io.Writer) (io.WriteCloser, error)
+	// NewReader wraps input with a decompressing reader
+	NewReader func(input io.Reader) (io.ReadCloser, error)
+}
+
+// GzipCompressor handles the .gz suffix, it is registered by default
+var GzipCompressor = Compressor{
+	Extension: "gz",
+	Encoding:  "gzip",
+	NewWriter: func(output io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(output), nil
+	},
+	NewReader: func(input io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(input)
+	},
+}
+
+var compressors = struct {
+	sync.RWMutex
+	byExtension map[string]Compressor
+}{byExtension: map[string]Compressor{GzipCompressor.Extension: GzipCompressor}}
+
+// RegisterCompressor makes a compound extension known to every Cache, e.g. a zstd Compressor for .json.zst
+func RegisterCompressor(compressor Compressor) {
+	compressors.Lock()
+	defer compressors.Unlock()
+	compressors.byExtension[compressor.Extension] = compressor
+}
+
+// compressorForPath returns the compressor matching the last extension of path
+func compressorForPath(path string) (Compressor, bool) {
+	compressors.RLock()
+	defer compressors.RUnlock()
+	compressor, ok := compressors.byExtension[strings.TrimPrefix(filepath.Ext(path), ".")]
+	return compressor, ok
+}
+
+// trimCompression strips a compression suffix from path
+func trimCompression(path string) string {
+	if _, ok := compressorForPath(path); ok {
+		return strings.TrimSuffix(path, filepath.Ext(path))
+	}
+	return path
+}
+
+// compressForPath compresses a payload about to be written when its key has a compression suffix.
+// Payloads whose opts.ContentEncoding already names the compressor are stored as is, and the
+// Content-Encoding supplied by the caller is never changed.
+func (cache *Cache) compressForPath(path string, data []byte, opts minio.PutObjectOptions) ([]byte, error) {
+	compressor, ok := compressorForPath(path)
+	if !ok || strings.EqualFold(opts.ContentEncoding, compressor.Encoding) {
+		return data, nil
+	}
+
+	buf := &bytes.Buffer{}
+	writer, err := compressor.NewWriter(buf)
+	if nil == err {
+		_, err = writer.Write(data)
+		if closeErr := writer.Close(); nil == err {
+			err = closeErr
+		}
+	}
+	if nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to compress path=%v", path))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressForPath inflates a payload read from a key with a compression suffix
+func (cache *Cache) decompressForPath(path string, data []byte) ([]byte, error) {
+	compressor, ok := compressorForPath(path)
+	if !ok || 0 == len(data) {
+		return data, nil
+	}
+	reader, err := compressor.NewReader(bytes.NewReader(data))
+	if nil == err {
+		data, err = ioutil.ReadAll(reader)
+		reader.Close()
+	}
+	if nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to decompress path=%v", path))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	return data, nil
+}
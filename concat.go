@@ -0,0 +1,118 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// ErrEncodedConcat is returned by Concat when a source is encrypted, compressed or delta encoded,
+// composing the stored bytes of such payloads doesn't produce a readable object
+var ErrEncodedConcat = errors.New("Concat does not support encrypted, compressed or delta encoded sources")
+
+// ErrSignedConcat is returned by Concat on a Cache created WithSignatures, the composed payload is never
+// downloaded so it can't be signed and every read of it would fail verification
+var ErrSignedConcat = errors.New("Concat is not supported with signatures")
+
+// concatDroppedMetadata are the internal metadata of the first source that don't describe the composed object
+var concatDroppedMetadata = []string{
+	signatureMetadata,
+	signatureKeyMetadata,
+	payloadHashMetadata,
+	idempotencyKeyMetadata,
+	schemaVersionMetadata,
+	expiresAtMetadata,
+	envelopeMetadata,
+}
+
+// Concat merges the source objects into dstKey server-side with ComposeObject, without downloading them.
+// Every source except the last must be at least 5 MiB, as required by multipart copy.
+// The content type is taken from the extension of dstKey, falling back to the first source, and the user metadata
+// is copied from the first source without signatures, hashes, idempotency keys, schema versions and expiry.
+// Encrypted, compressed and delta encoded sources are rejected with ErrEncodedConcat, and signed caches with ErrSignedConcat.
+func (cache *Cache) Concat(dstKey string, srcKeys ...string) (*WriteResult, error) {
+	cache.logger.Info(fmt.Sprintf("Concatenating %v objects to path=%v", len(srcKeys), dstKey))
+	if 0 == len(srcKeys) {
+		err := errors.New("Concat requires at least one source")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	if nil != cache.signer {
+		err := errors.Wrap(ErrSignedConcat, fmt.Sprintf("Failed to concat to path=%v", dstKey))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	dstKey = cache.objectKey(dstKey)
+	if err := cache.authorize(AuthPut, dstKey, nil); nil != err {
+		return nil, err
+	}
+	srcs := make([]minio.CopySrcOptions, len(srcKeys))
+	var first minio.ObjectInfo
+	for i, path := range srcKeys {
+		key := cache.objectKey(path)
+		if err := cache.authorize(AuthGet, key, nil); nil != err {
+			return nil, err
+		}
+		info, err := cache.backend.Stat(cache.ctx, key, minio.StatObjectOptions{})
+		if nil != err {
+			err = errors.Wrap(err, fmt.Sprintf("Failed to stat %v", key))
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
+		if "" != metadataValue(info.UserMetadata, envelopeMetadata) || "" != metadataValue(info.UserMetadata, deltaMetadata) || nil != cache.deltaFor(path) {
+			err = errors.Wrap(ErrEncodedConcat, fmt.Sprintf("Failed to concat %v", key))
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
+		if 0 == i {
+			first = info
+		}
+		srcs[i] = minio.CopySrcOptions{
+			Bucket: cache.bucketName,
+			Object: key,
+		}
+	}
+
+	contentType := contentTypeForPath(dstKey)
+	if "" == contentType {
+		contentType = first.ContentType
+	}
+	metadata := map[string]string{}
+	for k, v := range first.UserMetadata {
+		if !internalMetadata(k) {
+			metadata[k] = v
+		}
+	}
+	metadata["Content-Type"] = contentType
+
+	dst := minio.CopyDestOptions{
+		Bucket:          cache.bucketName,
+		Object:          dstKey,
+		UserMetadata:    metadata,
+		ReplaceMetadata: true,
+	}
+	uploadInfo, err := cache.backend.Copy(cache.ctx, dst, srcs...)
+	if nil != err {
+		err = errors.Wrap(err, "Failed to compose objects")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	result := newWriteResult(uploadInfo)
+	cache.invalidateTiers(dstKey)
+	cache.firePut(dstKey, result)
+	cache.logger.Info(fmt.Sprintf("Successfully composed bytes: %v", uploadInfo.Size))
+	return result, nil
+}
+
+// internalMetadata reports whether a user metadata name is one of concatDroppedMetadata
+func internalMetadata(name string) bool {
+	for _, dropped := range concatDroppedMetadata {
+		if strings.EqualFold(name, dropped) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package minioproto
+
+import (
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestConcat(t *testing.T) {
+	cache, _ := newTestCache(t, WithIdempotencyLedger("ledger"))
+	opts := minio.PutObjectOptions{UserMetadata: map[string]string{"Kind": "part", idempotencyKeyMetadata: "part-1"}}
+	if _, err := cache.WriteData("parts/1.csv", []byte("a,b\n"), opts); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := cache.WriteData("parts/2.csv", []byte("c,d\n"), minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := cache.Concat("joined.csv", "parts/1.csv", "parts/2.csv"); nil != err {
+		t.Fatal(err)
+	}
+	if output, err := cache.ReadData("joined.csv", minio.GetObjectOptions{}); nil != err || "a,b\nc,d\n" != string(output) {
+		t.Fatalf("expected the joined payloads, got %q %v", output, err)
+	}
+	info, err := cache.DataExists("joined.csv", minio.StatObjectOptions{})
+	if nil != err || nil == info {
+		t.Fatalf("expected the joined object, got %v", err)
+	}
+	if "part" != metadataValue(info.UserMetadata, "Kind") || "" != metadataValue(info.UserMetadata, idempotencyKeyMetadata) {
+		t.Fatalf("expected user metadata without internal metadata, got %v", info.UserMetadata)
+	}
+	if csvContentType != info.ContentType {
+		t.Fatalf("expected %v, got %v", csvContentType, info.ContentType)
+	}
+}
+
+func TestConcatEncoded(t *testing.T) {
+	cache, _ := newTestCache(t, WithProfiles(Profile{Prefix: "packed/", Compress: true}))
+	if _, err := cache.WriteData("packed/1", []byte("a"), minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := cache.Concat("joined", "packed/1"); ErrEncodedConcat != errors.Cause(err) {
+		t.Fatalf("expected ErrEncodedConcat, got %v", err)
+	}
+}
+
+func TestConcatSigned(t *testing.T) {
+	cache, _ := newTestCache(t, WithSignatures(HMACSigner{KeyID: "k", Secret: []byte("secret")}))
+	if _, err := cache.WriteData("parts/1", []byte("a"), minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := cache.Concat("joined", "parts/1"); ErrSignedConcat != errors.Cause(err) {
+		t.Fatalf("expected ErrSignedConcat, got %v", err)
+	}
+}
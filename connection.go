@@ -0,0 +1,55 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"net/url"
+	"strconv"
+)
+
+// WithRegion sets the region of the bucket, avoiding a region lookup for buckets outside the default region
+func WithRegion(region string) Option {
+	return func(cache *Cache) {
+		cache.region = region
+	}
+}
+
+// WithBucketLookup sets whether buckets are addressed by path or virtual host, defaults to auto-detection
+func WithBucketLookup(lookup minio.BucketLookupType) Option {
+	return func(cache *Cache) {
+		cache.bucketLookup = lookup
+	}
+}
+
+// urlOptions converts the query parameters of a connection url into Options:
+// region=<region>, lookup=auto|path|virtual-host|dns
+func urlOptions(query url.Values) ([]Option, error) {
+	output := []Option{}
+	if region := query.Get("region"); "" != region {
+		output = append(output, WithRegion(region))
+	}
+	switch lookup := query.Get("lookup"); lookup {
+	case "", "auto":
+	case "path":
+		output = append(output, WithBucketLookup(minio.BucketLookupPath))
+	case "virtual-host", "dns":
+		output = append(output, WithBucketLookup(minio.BucketLookupDNS))
+	default:
+		return nil, errors.New(fmt.Sprintf("Unsupported bucket lookup %v", lookup))
+	}
+	return output, nil
+}
+
+// urlSecure returns whether to use TLS, the secure query parameter overrides the scheme
+func urlSecure(scheme string, query url.Values) (bool, error) {
+	secure := query.Get("secure")
+	if "" == secure {
+		return "https" == scheme, nil
+	}
+	output, err := strconv.ParseBool(secure)
+	if nil != err {
+		return false, errors.Wrap(err, "Failed to parse secure parameter")
+	}
+	return output, nil
+}
@@ -0,0 +1,205 @@
+package minioproto
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// CSVType is the type a column is parsed as by GetCSVTyped
+type CSVType int
+
+const (
+	// CSVString keeps the field as a string
+	CSVString CSVType = iota
+	// CSVInt parses the field as an int64
+	CSVInt
+	// CSVFloat parses the field as a float64
+	CSVFloat
+	// CSVBool parses the field with strconv.ParseBool
+	CSVBool
+	// CSVTime parses the field as an RFC 3339 timestamp into a time.Time
+	CSVTime
+)
+
+// CSVOptions configures how CSV files are parsed and written, zero values keep the encoding/csv defaults
+type CSVOptions struct {
+	// Delimiter is the field delimiter, defaults to ','
+	Delimiter rune
+	// Comment marks lines to be ignored when it is the first character of the line
+	Comment rune
+	// LazyQuotes allows quotes to appear in unquoted fields and non-doubled quotes in quoted fields
+	LazyQuotes bool
+	// FieldsPerRecord is passed through to csv.Reader: 0 infers it from the first record, negative disables the check
+	FieldsPerRecord int
+	// TrimLeadingSpace ignores leading white space in fields
+	TrimLeadingSpace bool
+	// SkipRows drops the first N records, e.g. header rows, before FieldsPerRecord is checked or inferred
+	SkipRows int
+	// TypeHints are the types of the columns by index used by GetCSVTyped, columns without a hint are strings
+	TypeHints []CSVType
+}
+
+// withDelimiter returns a copy of the options using the given delimiter
+func (csvOpts *CSVOptions) withDelimiter(delimiter rune) *CSVOptions {
+	output := CSVOptions{}
+	if nil != csvOpts {
+		output = *csvOpts
+	}
+	output.Delimiter = delimiter
+	return &output
+}
+
+// configureReader applies the options to a csv.Reader
+func (csvOpts *CSVOptions) configureReader(reader *csv.Reader) *csv.Reader {
+	if nil == csvOpts {
+		return reader
+	}
+	if 0 != csvOpts.Delimiter {
+		reader.Comma = csvOpts.Delimiter
+	}
+	reader.Comment = csvOpts.Comment
+	reader.LazyQuotes = csvOpts.LazyQuotes
+	reader.FieldsPerRecord = csvOpts.FieldsPerRecord
+	reader.TrimLeadingSpace = csvOpts.TrimLeadingSpace
+	return reader
+}
+
+// configureWriter applies the options to a csv.Writer
+func (csvOpts *CSVOptions) configureWriter(writer *csv.Writer) *csv.Writer {
+	if nil != csvOpts && 0 != csvOpts.Delimiter {
+		writer.Comma = csvOpts.Delimiter
+	}
+	return writer
+}
+
+// skipRows reads past the configured number of leading records without checking their field count,
+// so a preamble doesn't fix the number of fields expected from the data
+func (csvOpts *CSVOptions) skipRows(reader *csv.Reader) error {
+	if nil == csvOpts || csvOpts.SkipRows <= 0 {
+		return nil
+	}
+	fieldsPerRecord := reader.FieldsPerRecord
+	reader.FieldsPerRecord = -1
+	defer func() { reader.FieldsPerRecord = fieldsPerRecord }()
+	for i := 0; i < csvOpts.SkipRows; i++ {
+		if _, err := reader.Read(); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// readDelimited reads and parses a delimited file from minio
+func (cache *Cache) readDelimited(path string, csvOpts *CSVOptions, opts minio.GetObjectOptions) ([][]string, error) {
+	cache.logger.Info(fmt.Sprintf("Reading CSV file, path=%v", path))
+	body, data, err := cache.openData(path, opts)
+	if nil != err {
+		err = errors.Wrap(err, "Failed to fetch CSV")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	defer body.Close()
+
+	// Spilled payloads are never empty, they are above the spill threshold
+	if nil != data {
+		if empty, err := cache.checkEmpty(path, data); empty {
+			if nil != err {
+				return nil, err
+			}
+			return [][]string{}, nil
+		}
+	}
+
+	reader := csvOpts.configureReader(csv.NewReader(body))
+	err = csvOpts.skipRows(reader)
+	if io.EOF == err {
+		return [][]string{}, nil
+	}
+	var output [][]string
+	if nil == err {
+		output, err = reader.ReadAll()
+	}
+	if nil != err {
+		err = errors.Wrap(err, "Failed deserialize data from CSV")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	cache.logger.Info(fmt.Sprintf("Success reading path=%v", path))
+	return output, err
+}
+
+// GetCSVTyped reads a CSV file like GetCSV and parses each field with the TypeHints of csvOpts into a string,
+// int64, float64, bool or time.Time. Empty fields of typed columns are nil.
+func (cache *Cache) GetCSVTyped(path string, csvOpts *CSVOptions, opts minio.GetObjectOptions) ([][]interface{}, error) {
+	records, err := cache.GetCSV(path, csvOpts, opts)
+	if nil != err {
+		return nil, err
+	}
+	var hints []CSVType
+	if nil != csvOpts {
+		hints = csvOpts.TypeHints
+	}
+
+	output := make([][]interface{}, len(records))
+	for i, record := range records {
+		row := make([]interface{}, len(record))
+		for j, value := range record {
+			hint := CSVString
+			if j < len(hints) {
+				hint = hints[j]
+			}
+			if row[j], err = parseCSVField(hint, value); nil != err {
+				err = errors.Wrap(err, fmt.Sprintf("Failed to parse row %v column %v of path=%v", i, j, path))
+				cache.logger.Error(err.Error())
+				return nil, err
+			}
+		}
+		output[i] = row
+	}
+	return output, nil
+}
+
+// parseCSVField converts a field to the type of its hint
+func parseCSVField(hint CSVType, value string) (interface{}, error) {
+	if CSVString == hint {
+		return value, nil
+	}
+	if "" == value {
+		return nil, nil
+	}
+	switch hint {
+	case CSVInt:
+		return strconv.ParseInt(value, 10, 64)
+	case CSVFloat:
+		return strconv.ParseFloat(value, 64)
+	case CSVBool:
+		return strconv.ParseBool(value)
+	case CSVTime:
+		return time.Parse(time.RFC3339, value)
+	}
+	return nil, errors.New(fmt.Sprintf("Unsupported type hint %v", hint))
+}
+
+// writeDelimited serializes the records and writes them to minio with the given content type
+func (cache *Cache) writeDelimited(path string, records [][]string, csvOpts *CSVOptions, contentType string, opts minio.PutObjectOptions) (*WriteResult, error) {
+	// Serialize the CSV to bytes
+	buf := &bytes.Buffer{}
+	writer := csvOpts.configureWriter(csv.NewWriter(buf))
+	if err := writer.WriteAll(records); nil != err {
+		err = errors.Wrap(err, "Failed serialize data as CSV")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	// Write the data
+	opts.ContentType = contentType
+	path = pathFix(path, opts.ContentType)
+	return cache.WriteData(path, buf.Bytes(), opts)
+}
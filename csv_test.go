@@ -0,0 +1,65 @@
+package minioproto
+
+import (
+	"github.com/minio/minio-go/v7"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetCSVOptions(t *testing.T) {
+	cache, _ := newTestCache(t)
+	payload := "exported by tool\n# comment\nname;count\na\"b;1\n c;2\n"
+	if _, err := cache.WriteData("export.csv", []byte(payload), minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	csvOpts := &CSVOptions{Delimiter: ';', Comment: '#', LazyQuotes: true, TrimLeadingSpace: true, SkipRows: 1}
+	records, err := cache.GetCSV("export", csvOpts, minio.GetObjectOptions{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	expected := [][]string{{"name", "count"}, {"a\"b", "1"}, {"c", "2"}}
+	if !reflect.DeepEqual(expected, records) {
+		t.Fatalf("expected %q, got %q", expected, records)
+	}
+
+	// Skipping every row returns no records
+	if records, err := cache.GetCSV("export", &CSVOptions{SkipRows: 10, LazyQuotes: true}, minio.GetObjectOptions{}); nil != err || 0 != len(records) {
+		t.Fatalf("expected no records, got %q %v", records, err)
+	}
+}
+
+func TestGetCSVTyped(t *testing.T) {
+	cache, _ := newTestCache(t)
+	records := [][]string{
+		{"name", "count", "ratio", "active", "at"},
+		{"a", "1", "0.5", "true", "2024-01-31T09:00:00Z"},
+		{"b", "", "", "", ""},
+	}
+	if _, err := cache.PutCSV("typed", records, nil, minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+
+	csvOpts := &CSVOptions{SkipRows: 1, TypeHints: []CSVType{CSVString, CSVInt, CSVFloat, CSVBool, CSVTime}}
+	output, err := cache.GetCSVTyped("typed", csvOpts, minio.GetObjectOptions{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	expected := [][]interface{}{
+		{"a", int64(1), 0.5, true, time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)},
+		{"b", nil, nil, nil, nil},
+	}
+	if !reflect.DeepEqual(expected, output) {
+		t.Fatalf("expected %v, got %v", expected, output)
+	}
+
+	// Without hints every field is a string
+	output, err = cache.GetCSVTyped("typed", nil, minio.GetObjectOptions{})
+	if nil != err || "count" != output[0][1] || "1" != output[1][1] {
+		t.Fatalf("expected strings, got %v %v", output, err)
+	}
+
+	if _, err := cache.GetCSVTyped("typed", &CSVOptions{TypeHints: []CSVType{CSVString, CSVInt}}, minio.GetObjectOptions{}); nil == err {
+		t.Fatal("expected the header to fail the int hint")
+	}
+}
@@ -0,0 +1,411 @@
+package minioproto
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"strings"
+	"sync"
+)
+
+// ErrCorruptDelta is returned when a delta object can't be applied to its base
+var ErrCorruptDelta = errors.New("Corrupt delta")
+
+// deltaHeadMagic prefixes the head manifest stored at the key of a delta encoded PROTO
+var deltaHeadMagic = []byte("MPDH")
+
+// deltaMagic prefixes every delta object
+var deltaMagic = []byte("MPD1")
+
+// deltaMetadata marks the head manifest of a delta encoded PROTO, so it is decoded even after the delta rule is removed
+const deltaMetadata = "Payload-Delta"
+
+const (
+	deltaOpCopy   byte = 'C'
+	deltaOpInsert byte = 'I'
+)
+
+const defaultDeltaSnapshotEvery = 16
+const defaultDeltaBlockSize = 4096
+
+// maxDeltaStates bounds the previous versions kept in memory, the least recently used idle keys are evicted
+const maxDeltaStates = 256
+
+// DeltaOptions configures delta encoded PROTOs
+type DeltaOptions struct {
+	// SnapshotEvery writes a full snapshot after this many deltas, defaults to 16
+	SnapshotEvery int
+	// BlockSize is the size of the blocks matched against the previous version, defaults to 4 KiB
+	BlockSize int
+}
+
+// deltaRule enables delta encoding under a prefix
+type deltaRule struct {
+	prefix string
+	opts   DeltaOptions
+}
+
+// deltaHead is the manifest stored at the key of a delta encoded PROTO. Retired holds the snapshot and deltas
+// superseded by the current base, they are deleted by the next snapshot so readers of the previous head can finish.
+type deltaHead struct {
+	Base    string   `json:"base"`
+	Deltas  []string `json:"deltas"`
+	Retired []string `json:"retired,omitempty"`
+}
+
+// deltaRegistry keeps the last payload of recently used delta encoded keys, so writes diff against it without a download
+type deltaRegistry struct {
+	sync.Mutex
+	order  *list.List
+	states map[string]*list.Element
+}
+
+// deltaItem is an entry of the registry LRU
+type deltaItem struct {
+	key   string
+	state *deltaState
+}
+
+// deltaState is the last known head and payload of a key
+type deltaState struct {
+	sync.Mutex
+	head    deltaHead
+	payload []byte
+	// users counts the callers holding the state, which is never evicted while in use
+	users int
+}
+
+// newDeltaRegistry creates an empty registry
+func newDeltaRegistry() *deltaRegistry {
+	return &deltaRegistry{order: list.New(), states: map[string]*list.Element{}}
+}
+
+// WithDeltaPROTO makes PutPROTO under prefix store compact deltas against the previous version plus
+// periodic full snapshots under <key>.deltas/, and GetPROTO reconstruct them transparently. The object
+// at the key holds a small manifest marked with Payload-Delta metadata, so it should only be read through GetPROTO. The previous version is
+// kept in memory after the first read or write of a key. Meant for large PROTOs with small changes and a
+// single writer. Superseded snapshots and deltas are deleted by the snapshot after next, so only the latest
+// version can be read, and the previous versions of at most 256 recently used keys are kept in memory.
+func WithDeltaPROTO(prefix string, deltaOpts DeltaOptions) Option {
+	return func(cache *Cache) {
+		if deltaOpts.SnapshotEvery <= 0 {
+			deltaOpts.SnapshotEvery = defaultDeltaSnapshotEvery
+		}
+		if deltaOpts.BlockSize <= 0 {
+			deltaOpts.BlockSize = defaultDeltaBlockSize
+		}
+		cache.deltaRules = append(cache.deltaRules, deltaRule{prefix: prefix, opts: deltaOpts})
+	}
+}
+
+// deltaFor returns the delta options of the longest matching prefix, or nil when path isn't delta encoded
+func (cache *Cache) deltaFor(path string) *DeltaOptions {
+	var output *DeltaOptions
+	longest := -1
+	for i, rule := range cache.deltaRules {
+		if strings.HasPrefix(path, rule.prefix) && len(rule.prefix) > longest {
+			output = &cache.deltaRules[i].opts
+			longest = len(rule.prefix)
+		}
+	}
+	return output
+}
+
+// deltaState returns the cached state of path, keyed by object key so tenant views don't share states.
+// The state must be released with releaseDeltaState.
+func (cache *Cache) deltaState(path string) *deltaState {
+	key := cache.objectKey(path)
+	registry := cache.deltas
+	registry.Lock()
+	defer registry.Unlock()
+	element, ok := registry.states[key]
+	if ok {
+		registry.order.MoveToFront(element)
+	} else {
+		element = registry.order.PushFront(&deltaItem{key: key, state: &deltaState{}})
+		registry.states[key] = element
+	}
+	state := element.Value.(*deltaItem).state
+	state.users++
+
+	// Evict the least recently used states that nobody holds
+	for candidate := registry.order.Back(); len(registry.states) > maxDeltaStates && nil != candidate; {
+		previous := candidate.Prev()
+		if item := candidate.Value.(*deltaItem); 0 == item.state.users {
+			registry.order.Remove(candidate)
+			delete(registry.states, item.key)
+		}
+		candidate = previous
+	}
+	return state
+}
+
+// releaseDeltaState marks a state returned by deltaState as no longer used
+func (cache *Cache) releaseDeltaState(state *deltaState) {
+	cache.deltas.Lock()
+	defer cache.deltas.Unlock()
+	state.users--
+}
+
+// putDelta writes payload as a delta against the current version of path, or as a full snapshot
+func (cache *Cache) putDelta(path string, payload []byte, deltaOpts *DeltaOptions, opts minio.PutObjectOptions) (*WriteResult, error) {
+	state := cache.deltaState(path)
+	defer cache.releaseDeltaState(state)
+	state.Lock()
+	defer state.Unlock()
+
+	// Refresh the previous version when another process may have written it
+	current, metadata, err := cache.readPayload(path, minio.GetObjectOptions{})
+	if nil != err && "NoSuchKey" != minio.ToErrorResponse(errors.Cause(err)).Code {
+		return nil, err
+	}
+	previous, head := []byte(nil), deltaHead{}
+	if nil == err {
+		if previous, head, err = cache.resolveDeltaLocked(path, state, current, metadata); nil != err {
+			return nil, err
+		}
+	}
+
+	prefix := path + ".deltas/"
+	objectOpts := minio.PutObjectOptions{ContentType: "application/octet-stream"}
+	var stale []string
+	var delta []byte
+	if 0 != len(head.Base) && len(head.Deltas)+1 < deltaOpts.SnapshotEvery {
+		delta = encodeDelta(previous, payload, deltaOpts.BlockSize)
+	}
+	if nil != delta && len(delta) < len(payload)/2 {
+		key := prefix + sortableID() + ".delta"
+		if _, err := cache.WriteData(key, delta, objectOpts); nil != err {
+			return nil, err
+		}
+		head.Deltas = append(append([]string{}, head.Deltas...), key)
+		cache.logger.Info(fmt.Sprintf("Wrote delta of %v bytes for %v bytes at path=%v", len(delta), len(payload), path))
+	} else {
+		key := prefix + sortableID() + ".base"
+		if _, err := cache.WriteData(key, payload, objectOpts); nil != err {
+			return nil, err
+		}
+		// Delete the versions retired by the previous snapshot and retire the ones superseded now
+		stale = head.Retired
+		retired := []string{}
+		if "" != head.Base {
+			retired = append(append(retired, head.Base), head.Deltas...)
+		}
+		head = deltaHead{Base: key, Deltas: []string{}, Retired: retired}
+		cache.logger.Info(fmt.Sprintf("Wrote snapshot of %v bytes at path=%v", len(payload), path))
+	}
+
+	// The head is written last so readers never see deltas that are missing
+	encoded, err := json.Marshal(head)
+	if nil != err {
+		return nil, err
+	}
+	opts = withUserMetadata(opts, deltaMetadata, "head")
+	result, err := cache.WriteData(path, append(append([]byte{}, deltaHeadMagic...), encoded...), opts)
+	if nil != err {
+		return nil, err
+	}
+	state.head, state.payload = head, payload
+
+	for _, key := range stale {
+		if err := cache.DeleteData(key, minio.RemoveObjectOptions{}); nil != err {
+			cache.logger.Warn(fmt.Sprintf("Failed to delete superseded delta path=%v: %v", key, err.Error()))
+		}
+	}
+	return result, nil
+}
+
+// resolveDelta reconstructs the payload of a delta encoded PROTO from its head, other payloads are returned untouched
+func (cache *Cache) resolveDelta(path string, data []byte, metadata map[string]string) ([]byte, error) {
+	if !isDeltaHead(data, metadata) && nil == cache.deltaFor(path) {
+		return data, nil
+	}
+	state := cache.deltaState(path)
+	defer cache.releaseDeltaState(state)
+	state.Lock()
+	defer state.Unlock()
+	payload, _, err := cache.resolveDeltaLocked(path, state, data, metadata)
+	return payload, err
+}
+
+// isDeltaHead reports whether a payload read with metadata is the head manifest written by putDelta
+func isDeltaHead(data []byte, metadata map[string]string) bool {
+	return "" != metadataValue(metadata, deltaMetadata) && bytes.HasPrefix(data, deltaHeadMagic)
+}
+
+// resolveDeltaLocked reconstructs a payload from its head, reusing the cached payload when the head is unchanged
+func (cache *Cache) resolveDeltaLocked(path string, state *deltaState, data []byte, metadata map[string]string) ([]byte, deltaHead, error) {
+	head := deltaHead{}
+	if !bytes.HasPrefix(data, deltaHeadMagic) {
+		// Written before delta encoding was enabled, the next write is a full snapshot
+		return data, head, nil
+	}
+	if err := json.Unmarshal(data[len(deltaHeadMagic):], &head); nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to decode delta head of path=%v", path))
+		cache.logger.Error(err.Error())
+		return nil, head, err
+	}
+	if head.Base == state.head.Base && len(head.Deltas) == len(state.head.Deltas) &&
+		(0 == len(head.Deltas) || head.Deltas[len(head.Deltas)-1] == state.head.Deltas[len(state.head.Deltas)-1]) {
+		return state.payload, head, nil
+	}
+
+	payload, err := cache.ReadData(head.Base, minio.GetObjectOptions{})
+	if nil != err {
+		return nil, head, errors.Wrap(err, fmt.Sprintf("Failed to read delta snapshot of path=%v", path))
+	}
+	for _, key := range head.Deltas {
+		delta, err := cache.ReadData(key, minio.GetObjectOptions{})
+		if nil != err {
+			return nil, head, errors.Wrap(err, fmt.Sprintf("Failed to read delta of path=%v", path))
+		}
+		if payload, err = applyDelta(payload, delta, cache.maxGetSize); nil != err {
+			err = errors.Wrap(err, fmt.Sprintf("Failed to apply delta %v", key))
+			cache.logger.Error(err.Error())
+			return nil, head, err
+		}
+	}
+	state.head, state.payload = head, payload
+	return payload, head, nil
+}
+
+// encodeDelta describes target as copies of base blocks and inserted bytes, matching blocks at any
+// offset of target with an rsync style rolling checksum
+func encodeDelta(base, target []byte, blockSize int) []byte {
+	output := append([]byte{}, deltaMagic...)
+	output = appendUvarint(output, uint64(len(target)))
+
+	index := map[uint32][]int{}
+	for offset := 0; offset+blockSize <= len(base); offset += blockSize {
+		weak := rollingChecksum(base[offset : offset+blockSize])
+		index[weak] = append(index[weak], offset)
+	}
+
+	literal := 0
+	flush := func(end int) {
+		if end > literal {
+			output = append(output, deltaOpInsert)
+			output = appendUvarint(output, uint64(end-literal))
+			output = append(output, target[literal:end]...)
+		}
+	}
+
+	i := 0
+	var a, b uint32
+	if len(target) >= blockSize {
+		a, b = checksumParts(target[:blockSize])
+	}
+	for i+blockSize <= len(target) {
+		match := -1
+		for _, offset := range index[a|b<<16] {
+			if bytes.Equal(base[offset:offset+blockSize], target[i:i+blockSize]) {
+				match = offset
+				break
+			}
+		}
+		if match >= 0 {
+			flush(i)
+			length := blockSize
+			for match+length < len(base) && i+length < len(target) && base[match+length] == target[i+length] {
+				length++
+			}
+			output = append(output, deltaOpCopy)
+			output = appendUvarint(output, uint64(match))
+			output = appendUvarint(output, uint64(length))
+			i += length
+			literal = i
+			if i+blockSize <= len(target) {
+				a, b = checksumParts(target[i : i+blockSize])
+			}
+			continue
+		}
+		if i+blockSize == len(target) {
+			break
+		}
+		// Roll the checksum one byte forward
+		out, in := uint32(target[i]), uint32(target[i+blockSize])
+		a = (a - out + in) & 0xffff
+		b = (b - uint32(blockSize)*out + a) & 0xffff
+		i++
+	}
+	flush(len(target))
+	return output
+}
+
+// applyDelta rebuilds the target described by delta from base. The target size recorded in the delta is
+// only trusted up to limit, when set, and memory is allocated as the target is rebuilt rather than upfront.
+func applyDelta(base, delta []byte, limit int64) ([]byte, error) {
+	if !bytes.HasPrefix(delta, deltaMagic) {
+		return nil, errors.Wrap(ErrCorruptDelta, "missing magic")
+	}
+	reader := bytes.NewReader(delta[len(deltaMagic):])
+	size, err := binary.ReadUvarint(reader)
+	if nil != err {
+		return nil, errors.Wrap(ErrCorruptDelta, err.Error())
+	}
+	if limit > 0 && size > uint64(limit) {
+		return nil, errors.Wrap(ErrCorruptDelta, fmt.Sprintf("target of %v bytes exceeds the limit of %v", size, limit))
+	}
+	capacity := uint64(len(base) + len(delta))
+	if size < capacity {
+		capacity = size
+	}
+	output := make([]byte, 0, capacity)
+	for {
+		op, err := reader.ReadByte()
+		if nil != err {
+			break
+		}
+		switch op {
+		case deltaOpCopy:
+			offset, errOffset := binary.ReadUvarint(reader)
+			length, errLength := binary.ReadUvarint(reader)
+			if nil != errOffset || nil != errLength || offset > uint64(len(base)) || length > uint64(len(base))-offset {
+				return nil, errors.Wrap(ErrCorruptDelta, "copy out of range")
+			}
+			if length > size-uint64(len(output)) {
+				return nil, errors.Wrap(ErrCorruptDelta, "copy past the target size")
+			}
+			output = append(output, base[offset:offset+length]...)
+		case deltaOpInsert:
+			length, err := binary.ReadUvarint(reader)
+			if nil != err || length > uint64(reader.Len()) || length > size-uint64(len(output)) {
+				return nil, errors.Wrap(ErrCorruptDelta, "insert out of range")
+			}
+			chunk := make([]byte, length)
+			reader.Read(chunk)
+			output = append(output, chunk...)
+		default:
+			return nil, errors.Wrap(ErrCorruptDelta, fmt.Sprintf("unknown op %v", op))
+		}
+	}
+	if uint64(len(output)) != size {
+		return nil, errors.Wrap(ErrCorruptDelta, fmt.Sprintf("expected %v bytes, rebuilt %v", size, len(output)))
+	}
+	return output, nil
+}
+
+// rollingChecksum is the rsync weak checksum of block
+func rollingChecksum(block []byte) uint32 {
+	a, b := checksumParts(block)
+	return a | b<<16
+}
+
+// checksumParts returns the two 16 bit halves of the rsync weak checksum
+func checksumParts(block []byte) (uint32, uint32) {
+	var a, b uint32
+	for i, value := range block {
+		a += uint32(value)
+		b += uint32(len(block)-i) * uint32(value)
+	}
+	return a & 0xffff, b & 0xffff
+}
+
+func appendUvarint(output []byte, value uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(output, buf[:binary.PutUvarint(buf, value)]...)
+}
@@ -0,0 +1,142 @@
+package minioproto
+
+import (
+	"bytes"
+	"context"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDelta(t *testing.T) {
+	base := []byte("the quick brown fox jumps over the lazy dog, the quick brown fox jumps again")
+	tests := []struct {
+		name   string
+		base   []byte
+		target []byte
+	}{
+		{"identical", base, base},
+		{"empty base", []byte{}, base},
+		{"empty target", base, []byte{}},
+		{"both empty", []byte{}, []byte{}},
+		{"appended", base, append(append([]byte{}, base...), " and sleeps"...)},
+		{"prepended", base, append([]byte("first "), base...)},
+		{"changed middle", base, bytes.Replace(base, []byte("lazy"), []byte("busy"), 1)},
+		{"shorter than block", []byte("abc"), []byte("abd")},
+		{"unrelated", base, []byte("completely different content with no shared blocks at all")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delta := encodeDelta(test.base, test.target, 4)
+			if !bytes.HasPrefix(delta, deltaMagic) {
+				t.Fatalf("delta is missing the magic: %q", delta)
+			}
+			output, err := applyDelta(test.base, delta, 0)
+			if nil != err {
+				t.Fatalf("applyDelta failed: %v", err)
+			}
+			if !bytes.Equal(output, test.target) {
+				t.Fatalf("expected %q, rebuilt %q", test.target, output)
+			}
+		})
+	}
+}
+
+func TestEncodeDeltaCopiesBlocks(t *testing.T) {
+	base := bytes.Repeat([]byte("0123456789abcdef"), 64)
+	target := append(append([]byte{}, base...), 'x')
+	if delta := encodeDelta(base, target, 16); len(delta) >= len(target)/2 {
+		t.Fatalf("expected a compact delta, got %v bytes for %v", len(delta), len(target))
+	}
+}
+
+func TestApplyDeltaCorrupt(t *testing.T) {
+	base := []byte("0123456789")
+	huge := appendUvarint(append([]byte{}, deltaMagic...), 1<<62)
+	tests := []struct {
+		name  string
+		delta []byte
+		limit int64
+	}{
+		{"missing magic", []byte("XXXX"), 0},
+		{"missing size", deltaMagic, 0},
+		{"copy out of range", append(append([]byte{}, deltaMagic...), 5, deltaOpCopy, 8, 5), 0},
+		{"copy past size", append(append([]byte{}, deltaMagic...), 2, deltaOpCopy, 0, 5), 0},
+		{"insert out of range", append(append([]byte{}, deltaMagic...), 5, deltaOpInsert, 9, 'a'), 0},
+		{"insert past size", append(append([]byte{}, deltaMagic...), 1, deltaOpInsert, 2, 'a', 'b'), 0},
+		{"unknown op", append(append([]byte{}, deltaMagic...), 1, 'Z'), 0},
+		{"size mismatch", append(append([]byte{}, deltaMagic...), 3, deltaOpCopy, 0, 2), 0},
+		{"huge size", huge, 0},
+		{"size over limit", append(append([]byte{}, deltaMagic...), 5, deltaOpCopy, 0, 5), 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := applyDelta(base, test.delta, test.limit); ErrCorruptDelta != errors.Cause(err) {
+				t.Fatalf("expected ErrCorruptDelta, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDeltaPROTO(t *testing.T) {
+	rule := WithDeltaPROTO("models/", DeltaOptions{SnapshotEvery: 3, BlockSize: 16})
+	cache, backend := newTestCache(t, rule)
+	base := strings.Repeat("0123456789abcdef", 64)
+	for i := 0; i < 5; i++ {
+		value := base + strconv.Itoa(i)
+		if _, err := cache.PutPROTO("models/weights", wrapperspb.String(value), nil, minio.PutObjectOptions{}); nil != err {
+			t.Fatal(err)
+		}
+		// Another Cache on the same backend can't reuse the payload kept in memory
+		reader, err := NewWithBackend(context.Background(), zap.NewNop(), backend, rule)
+		if nil != err {
+			t.Fatal(err)
+		}
+		msg := &wrapperspb.StringValue{}
+		if err := reader.GetPROTO("models/weights", msg, nil, minio.GetObjectOptions{}); nil != err {
+			t.Fatal(err)
+		}
+		if value != msg.Value {
+			t.Fatalf("version %v: expected %v bytes, got %v", i, len(value), len(msg.Value))
+		}
+	}
+	deltas, err := cache.List("models/weights.pb.deltas/", minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 0 == len(deltas) || len(deltas) > 6 {
+		t.Fatalf("expected superseded deltas to be deleted, got %v objects", len(deltas))
+	}
+
+	// Heads stay readable after the delta rule is removed
+	plain, err := NewWithBackend(context.Background(), zap.NewNop(), backend)
+	if nil != err {
+		t.Fatal(err)
+	}
+	msg := &wrapperspb.StringValue{}
+	if err := plain.GetPROTO("models/weights", msg, nil, minio.GetObjectOptions{}); nil != err || base+"4" != msg.Value {
+		t.Fatalf("expected the latest version without the delta rule, got %v", err)
+	}
+}
+
+func TestDeltaHeadMagicWithoutRule(t *testing.T) {
+	cache, _ := newTestCache(t)
+	// "MPDH" followed by one more byte is a valid unknown fixed32 field 9, so this is a valid StringValue
+	encoded, err := proto.Marshal(wrapperspb.String("value"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	payload := append(append(append([]byte{}, deltaHeadMagic...), 'x'), encoded...)
+	if _, err := cache.WriteData("plain/raw.pb", payload, minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	msg := &wrapperspb.StringValue{}
+	if err := cache.GetPROTO("plain/raw.pb", msg, nil, minio.GetObjectOptions{}); nil != err || "value" != msg.Value {
+		t.Fatalf("expected a payload outside delta prefixes to be decoded as is, got %q %v", msg.Value, err)
+	}
+}
@@ -0,0 +1,309 @@
+package minioproto
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DiffKind is how DiffObjects compared two objects, chosen from the extension of the first key
+type DiffKind string
+
+const (
+	// DiffJSON compares decoded JSON documents path by path
+	DiffJSON DiffKind = "json"
+	// DiffCSV compares CSV and TSV files row by row and column by column, the first row is the header
+	DiffCSV DiffKind = "csv"
+	// DiffBinary compares raw bytes
+	DiffBinary DiffKind = "binary"
+)
+
+// DiffOp is the kind of change of a diff entry
+type DiffOp string
+
+const (
+	// DiffAdded is present in b only
+	DiffAdded DiffOp = "added"
+	// DiffRemoved is present in a only
+	DiffRemoved DiffOp = "removed"
+	// DiffChanged is present in both with different values
+	DiffChanged DiffOp = "changed"
+)
+
+// JSONChange is a difference at a JSON path such as $.items[2].name
+type JSONChange struct {
+	Op     DiffOp      `json:"op"`
+	Path   string      `json:"path"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// CSVChange is a difference in a data row, counted from 0 after the header.
+// Added and removed rows have an empty Column and the whole row joined with commas.
+type CSVChange struct {
+	Op     DiffOp `json:"op"`
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// ByteRange is a range of differing bytes, a length difference is reported as a range past the shorter object
+type ByteRange struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+// ObjectDiff is the structured difference between two objects
+type ObjectDiff struct {
+	A     string       `json:"a"`
+	B     string       `json:"b"`
+	Kind  DiffKind     `json:"kind"`
+	Equal bool         `json:"equal"`
+	JSON  []JSONChange `json:"json,omitempty"`
+	CSV   []CSVChange  `json:"csv,omitempty"`
+	Bytes []ByteRange  `json:"bytes,omitempty"`
+}
+
+// PrefixDiff summarizes the keys that differ between two prefixes, keys are relative to the prefixes
+type PrefixDiff struct {
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Changed   []string `json:"changed"`
+	Unchanged int      `json:"unchanged"`
+}
+
+// DiffObjects compares the objects at a and b, as JSON or CSV when the extension of a says so, otherwise as bytes
+func (cache *Cache) DiffObjects(a, b string) (*ObjectDiff, error) {
+	cache.logger.Info(fmt.Sprintf("Comparing path=%v with path=%v", a, b))
+	dataA, err := cache.ReadData(a, minio.GetObjectOptions{})
+	if nil != err {
+		return nil, err
+	}
+	dataB, err := cache.ReadData(b, minio.GetObjectOptions{})
+	if nil != err {
+		return nil, err
+	}
+
+	output := &ObjectDiff{A: a, B: b}
+	switch contentType := contentTypeForPath(a); contentType {
+	case jsonContentType:
+		output.Kind = DiffJSON
+		err = diffJSON(output, dataA, dataB)
+	case csvContentType, tsvContentType:
+		output.Kind = DiffCSV
+		delimiter := ','
+		if tsvContentType == contentType {
+			delimiter = '\t'
+		}
+		err = diffCSV(output, dataA, dataB, delimiter)
+	default:
+		output.Kind = DiffBinary
+		output.Bytes = diffBytes(dataA, dataB)
+	}
+	if nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to compare %v with %v", a, b))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	output.Equal = 0 == len(output.JSON) && 0 == len(output.CSV) && 0 == len(output.Bytes)
+	return output, nil
+}
+
+// DiffPrefix compares the objects under prefixes a and b by relative key, size and ETag
+func (cache *Cache) DiffPrefix(a, b string) (*PrefixDiff, error) {
+	objectsA, err := cache.List(a, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return nil, err
+	}
+	objectsB, err := cache.List(b, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return nil, err
+	}
+
+	remaining := map[string]minio.ObjectInfo{}
+	for _, info := range objectsB {
+		remaining[strings.TrimPrefix(info.Key, b)] = info
+	}
+	output := &PrefixDiff{Added: []string{}, Removed: []string{}, Changed: []string{}}
+	for _, info := range objectsA {
+		key := strings.TrimPrefix(info.Key, a)
+		other, ok := remaining[key]
+		delete(remaining, key)
+		switch {
+		case !ok:
+			output.Removed = append(output.Removed, key)
+		case sameObject(info, other):
+			output.Unchanged++
+		default:
+			output.Changed = append(output.Changed, key)
+		}
+	}
+	for key := range remaining {
+		output.Added = append(output.Added, key)
+	}
+	sort.Strings(output.Added)
+
+	cache.logger.Info(fmt.Sprintf("Compared prefix=%v with prefix=%v: %v added, %v removed, %v changed",
+		a, b, len(output.Added), len(output.Removed), len(output.Changed)))
+	return output, nil
+}
+
+// diffJSON decodes both documents and records their differences
+func diffJSON(output *ObjectDiff, a, b []byte) error {
+	var docA, docB interface{}
+	for _, doc := range []struct {
+		data   []byte
+		target *interface{}
+	}{{a, &docA}, {b, &docB}} {
+		decoder := json.NewDecoder(bytes.NewReader(doc.data))
+		decoder.UseNumber()
+		if err := decoder.Decode(doc.target); nil != err {
+			return err
+		}
+	}
+	output.JSON = []JSONChange{}
+	compareJSON(&output.JSON, "$", docA, docB)
+	return nil
+}
+
+// compareJSON walks two decoded values, appending a change for every differing path
+func compareJSON(changes *[]JSONChange, path string, a, b interface{}) {
+	switch valueA := a.(type) {
+	case map[string]interface{}:
+		valueB, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := []string{}
+		for key := range valueA {
+			keys = append(keys, key)
+		}
+		for key := range valueB {
+			if _, ok := valueA[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			childA, okA := valueA[key]
+			childB, okB := valueB[key]
+			childPath := fmt.Sprintf("%v.%v", path, key)
+			switch {
+			case !okA:
+				*changes = append(*changes, JSONChange{Op: DiffAdded, Path: childPath, After: childB})
+			case !okB:
+				*changes = append(*changes, JSONChange{Op: DiffRemoved, Path: childPath, Before: childA})
+			default:
+				compareJSON(changes, childPath, childA, childB)
+			}
+		}
+		return
+	case []interface{}:
+		valueB, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(valueA) || i < len(valueB); i++ {
+			childPath := fmt.Sprintf("%v[%v]", path, i)
+			switch {
+			case i >= len(valueA):
+				*changes = append(*changes, JSONChange{Op: DiffAdded, Path: childPath, After: valueB[i]})
+			case i >= len(valueB):
+				*changes = append(*changes, JSONChange{Op: DiffRemoved, Path: childPath, Before: valueA[i]})
+			default:
+				compareJSON(changes, childPath, valueA[i], valueB[i])
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, JSONChange{Op: DiffChanged, Path: path, Before: a, After: b})
+	}
+}
+
+// diffCSV parses both files and records their differences by row and header column
+func diffCSV(output *ObjectDiff, a, b []byte, delimiter rune) error {
+	records := [2][][]string{}
+	for i, data := range [][]byte{a, b} {
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.Comma = delimiter
+		reader.FieldsPerRecord = -1
+		rows, err := reader.ReadAll()
+		if nil != err {
+			return err
+		}
+		records[i] = rows
+	}
+
+	output.CSV = []CSVChange{}
+	header := []string{}
+	if 0 != len(records[0]) {
+		header = records[0][0]
+	}
+	rowsA, rowsB := dataRows(records[0]), dataRows(records[1])
+	for row := 0; row < len(rowsA) || row < len(rowsB); row++ {
+		switch {
+		case row >= len(rowsA):
+			output.CSV = append(output.CSV, CSVChange{Op: DiffAdded, Row: row, After: strings.Join(rowsB[row], ",")})
+		case row >= len(rowsB):
+			output.CSV = append(output.CSV, CSVChange{Op: DiffRemoved, Row: row, Before: strings.Join(rowsA[row], ",")})
+		default:
+			for index := 0; index < len(rowsA[row]) || index < len(rowsB[row]); index++ {
+				before, after := column(rowsA[row], index), column(rowsB[row], index)
+				if before == after {
+					continue
+				}
+				name := column(header, index)
+				if "" == name {
+					name = fmt.Sprint(index)
+				}
+				output.CSV = append(output.CSV, CSVChange{Op: DiffChanged, Row: row, Column: name, Before: before, After: after})
+			}
+		}
+	}
+	return nil
+}
+
+// dataRows drops the header row
+func dataRows(records [][]string) [][]string {
+	if 0 == len(records) {
+		return records
+	}
+	return records[1:]
+}
+
+// diffBytes returns the ranges where a and b differ
+func diffBytes(a, b []byte) []ByteRange {
+	output := []ByteRange{}
+	shorter := len(a)
+	if len(b) < shorter {
+		shorter = len(b)
+	}
+	start := -1
+	for i := 0; i < shorter; i++ {
+		if a[i] != b[i] {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			output = append(output, ByteRange{Offset: int64(start), Length: int64(i - start)})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		output = append(output, ByteRange{Offset: int64(start), Length: int64(shorter - start)})
+	}
+	if len(a) != len(b) {
+		longer := len(a) + len(b) - shorter
+		output = append(output, ByteRange{Offset: int64(shorter), Length: int64(longer - shorter)})
+	}
+	return output
+}
@@ -0,0 +1,76 @@
+package minioproto
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		expected []ByteRange
+	}{
+		{"identical", "abcdef", "abcdef", []ByteRange{}},
+		{"both empty", "", "", []ByteRange{}},
+		{"single byte", "abcdef", "abXdef", []ByteRange{{Offset: 2, Length: 1}}},
+		{"two ranges", "abcdef", "XbcdYY", []ByteRange{{Offset: 0, Length: 1}, {Offset: 4, Length: 2}}},
+		{"longer b", "abc", "abcde", []ByteRange{{Offset: 3, Length: 2}}},
+		{"shorter b", "abcde", "aXc", []ByteRange{{Offset: 1, Length: 1}, {Offset: 3, Length: 2}}},
+		{"differs to the end", "abc", "aXYZ", []ByteRange{{Offset: 1, Length: 2}, {Offset: 3, Length: 1}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if output := diffBytes([]byte(test.a), []byte(test.b)); !reflect.DeepEqual(output, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestCompareJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		expected []JSONChange
+	}{
+		{"identical", `{"a":1,"b":[1,2]}`, `{"b":[1,2],"a":1}`, []JSONChange{}},
+		{"changed value", `{"a":1}`, `{"a":2}`, []JSONChange{
+			{Op: DiffChanged, Path: "$.a", Before: 1.0, After: 2.0},
+		}},
+		{"added and removed keys", `{"a":1,"b":2}`, `{"b":2,"c":3}`, []JSONChange{
+			{Op: DiffRemoved, Path: "$.a", Before: 1.0},
+			{Op: DiffAdded, Path: "$.c", After: 3.0},
+		}},
+		{"nested", `{"items":[{"name":"x"}]}`, `{"items":[{"name":"y"}]}`, []JSONChange{
+			{Op: DiffChanged, Path: "$.items[0].name", Before: "x", After: "y"},
+		}},
+		{"array length", `[1,2,3]`, `[1,4]`, []JSONChange{
+			{Op: DiffChanged, Path: "$[1]", Before: 2.0, After: 4.0},
+			{Op: DiffRemoved, Path: "$[2]", Before: 3.0},
+		}},
+		{"type change", `{"a":{"b":1}}`, `{"a":[1]}`, []JSONChange{
+			{Op: DiffChanged, Path: "$.a", Before: map[string]interface{}{"b": 1.0}, After: []interface{}{1.0}},
+		}},
+		{"null", `{"a":null}`, `{"a":false}`, []JSONChange{
+			{Op: DiffChanged, Path: "$.a", Before: nil, After: false},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var a, b interface{}
+			if err := json.Unmarshal([]byte(test.a), &a); nil != err {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(test.b), &b); nil != err {
+				t.Fatal(err)
+			}
+			changes := []JSONChange{}
+			compareJSON(&changes, "$", a, b)
+			if !reflect.DeepEqual(changes, test.expected) {
+				t.Fatalf("expected %+v, got %+v", test.expected, changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package minioproto
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskCache is a persistent on-disk cache bounded by size, evicting the least recently used files.
+// Every payload is stored with its SHA-256 so corrupted files are detected and discarded on read.
+// The sizes and recency of the files are tracked in memory, seeded from the directory on first use,
+// and payloads are read without holding the lock. DiskCache implements Tier, so it can also be used
+// as a layer with WithTiers.
+type DiskCache struct {
+	mutex    sync.Mutex
+	dir      string
+	maxBytes int64
+	loaded   bool
+	size     int64
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+// diskCacheMeta is stored alongside each payload
+type diskCacheMeta struct {
+	ETag     string            `json:"etag"`
+	SHA256   string            `json:"sha256"`
+	Size     int64             `json:"size"`
+	Envelope string            `json:"envelope,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// diskCacheItem is an entry of the DiskCache LRU
+type diskCacheItem struct {
+	path string
+	size int64
+}
+
+// NewDiskCache creates a DiskCache rooted at dir holding at most maxBytes of payloads (0 for unbounded)
+func NewDiskCache(dir string, maxBytes int64) *DiskCache {
+	return &DiskCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+// WithDiskCache keeps downloaded payloads in a persistent directory so restarted processes don't
+// download them again. Cached payloads are only used after checking their ETag against the bucket.
+func WithDiskCache(dir string, maxBytes int64) Option {
+	return func(cache *Cache) {
+		cache.diskCache = NewDiskCache(dir, maxBytes)
+	}
+}
+
+// Get returns the entry stored at path, or nil if absent or corrupted
+func (disk *DiskCache) Get(path string) (*TierEntry, error) {
+	entry, valid, err := disk.read(path)
+	if nil != err || valid {
+		return entry, err
+	}
+
+	// A concurrent Put may have replaced the files between reading the metadata and the payload,
+	// so only discard them if they are still invalid under the lock
+	disk.mutex.Lock()
+	defer disk.mutex.Unlock()
+	if entry, valid, err = disk.read(path); nil != err || valid {
+		return entry, err
+	}
+	return nil, disk.remove(path)
+}
+
+// read loads and verifies the entry stored at path, reporting false when it is missing or corrupted
+func (disk *DiskCache) read(path string) (*TierEntry, bool, error) {
+	dataPath, metaPath := disk.files(path)
+	metaBytes, err := ioutil.ReadFile(metaPath)
+	if os.IsNotExist(err) {
+		return nil, true, nil
+	}
+	if nil != err {
+		return nil, false, err
+	}
+	meta := diskCacheMeta{}
+	if err := json.Unmarshal(metaBytes, &meta); nil != err {
+		return nil, false, nil
+	}
+
+	data, err := ioutil.ReadFile(dataPath)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if nil != err {
+		return nil, false, err
+	}
+	sum := sha256.Sum256(data)
+	if int64(len(data)) != meta.Size || hex.EncodeToString(sum[:]) != meta.SHA256 {
+		return nil, false, nil
+	}
+
+	// The modification time of the payload persists recency across restarts
+	now := time.Now()
+	if err := os.Chtimes(dataPath, now, now); nil != err && !os.IsNotExist(err) {
+		return nil, false, err
+	}
+	disk.mutex.Lock()
+	if err := disk.load(); nil == err {
+		disk.touch(path, meta.Size)
+	}
+	disk.mutex.Unlock()
+	return &TierEntry{Data: data, ETag: meta.ETag, Envelope: meta.Envelope, Metadata: meta.Metadata}, true, nil
+}
+
+// Put stores an entry at path, evicting the least recently used payloads to stay within the size limit
+func (disk *DiskCache) Put(path string, entry *TierEntry) error {
+	if disk.maxBytes > 0 && int64(len(entry.Data)) > disk.maxBytes {
+		return disk.Delete(path)
+	}
+
+	sum := sha256.Sum256(entry.Data)
+	meta, err := json.Marshal(diskCacheMeta{
+		ETag:     entry.ETag,
+		SHA256:   hex.EncodeToString(sum[:]),
+		Size:     int64(len(entry.Data)),
+		Envelope: entry.Envelope,
+		Metadata: entry.Metadata,
+	})
+	if nil != err {
+		return err
+	}
+
+	disk.mutex.Lock()
+	defer disk.mutex.Unlock()
+	if err := disk.load(); nil != err {
+		return err
+	}
+	dataPath, metaPath := disk.files(path)
+	if err := writeFileAtomic(dataPath, entry.Data); nil != err {
+		return err
+	}
+	if err := writeFileAtomic(metaPath, meta); nil != err {
+		return err
+	}
+	disk.touch(path, int64(len(entry.Data)))
+	return disk.evict()
+}
+
+// Delete removes the entry at path if present
+func (disk *DiskCache) Delete(path string) error {
+	disk.mutex.Lock()
+	defer disk.mutex.Unlock()
+	return disk.remove(path)
+}
+
+func (disk *DiskCache) remove(path string) error {
+	dataPath, metaPath := disk.files(path)
+	for _, file := range []string{dataPath, metaPath} {
+		if err := os.Remove(file); nil != err && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	path = diskCacheKey(path)
+	if element, ok := disk.entries[path]; ok {
+		disk.order.Remove(element)
+		delete(disk.entries, path)
+		disk.size -= element.Value.(*diskCacheItem).size
+	}
+	return nil
+}
+
+// touch records path as the most recently used entry with the given size
+func (disk *DiskCache) touch(path string, size int64) {
+	path = diskCacheKey(path)
+	if element, ok := disk.entries[path]; ok {
+		item := element.Value.(*diskCacheItem)
+		disk.size += size - item.size
+		item.size = size
+		disk.order.MoveToFront(element)
+		return
+	}
+	disk.entries[path] = disk.order.PushFront(&diskCacheItem{path: path, size: size})
+	disk.size += size
+}
+
+// load seeds the in-memory LRU from the payloads already in the directory, oldest first
+func (disk *DiskCache) load() error {
+	if disk.loaded {
+		return nil
+	}
+
+	type cachedFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	files := []cachedFile{}
+	dataDir := filepath.Join(disk.dir, "data")
+	err := filepath.Walk(dataDir, func(file string, info os.FileInfo, err error) error {
+		if nil != err {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(dataDir, file)
+		if nil != err {
+			return err
+		}
+		files = append(files, cachedFile{path: filepath.ToSlash(rel), size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if nil != err {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, file := range files {
+		disk.touch(file.path, file.size)
+	}
+	disk.loaded = true
+	return nil
+}
+
+// Evict removes the least recently used payloads until the cache fits in its size limit
+func (disk *DiskCache) Evict() error {
+	disk.mutex.Lock()
+	defer disk.mutex.Unlock()
+	if err := disk.load(); nil != err {
+		return err
+	}
+	return disk.evict()
+}
+
+// evict removes the least recently used payloads until the cache fits in maxBytes
+func (disk *DiskCache) evict() error {
+	if disk.maxBytes <= 0 {
+		return nil
+	}
+	for disk.size > disk.maxBytes && disk.order.Len() > 0 {
+		if err := disk.remove(disk.order.Back().Value.(*diskCacheItem).path); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// files returns the payload and metadata files for path, which can't escape the directory
+func (disk *DiskCache) files(path string) (string, string) {
+	clean := filepath.FromSlash(diskCacheKey(path))
+	return filepath.Join(disk.dir, "data", clean), filepath.Join(disk.dir, "meta", clean+".json")
+}
+
+// diskCacheKey is the cleaned relative path the payload of path is stored and tracked under
+func diskCacheKey(path string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+path)), "/")
+}
+
+// readDiskCache returns the cached payload for path if its ETag still matches the bucket
+func (cache *Cache) readDiskCache(path string) (*TierEntry, bool) {
+	entry, err := cache.diskCache.Get(path)
+	if nil != err {
+		cache.logger.Warn(fmt.Sprintf("Failed to read disk cache for path=%v: %v", path, err.Error()))
+		return &TierEntry{}, false
+	}
+	if nil == entry {
+		return &TierEntry{}, false
+	}
+
+	if err := cache.authorize(AuthStat, path, nil); nil != err {
+		return &TierEntry{}, false
+	}
+	info, err := cache.backend.Stat(cache.ctx, path, minio.StatObjectOptions{})
+	if nil != err || info.ETag != entry.ETag {
+		cache.logger.Info(fmt.Sprintf("Disk cache is stale for path=%v", path))
+		if err := cache.diskCache.Delete(path); nil != err {
+			cache.logger.Warn(fmt.Sprintf("Failed to remove stale disk cache for path=%v: %v", path, err.Error()))
+		}
+		return &TierEntry{}, false
+	}
+
+	cache.logger.Info(fmt.Sprintf("Disk cache hit for path=%v", path))
+	return entry, true
+}
+
+// writeDiskCache stores a payload read from or written to the bucket
+func (cache *Cache) writeDiskCache(path string, entry *TierEntry) {
+	if err := cache.diskCache.Put(path, entry); nil != err {
+		cache.logger.Warn(fmt.Sprintf("Failed to write disk cache for path=%v: %v", path, err.Error()))
+	}
+}
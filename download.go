@@ -0,0 +1,132 @@
+package minioproto
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DownloadOptions configures DownloadPrefix
+type DownloadOptions struct {
+	// Concurrency is the number of parallel downloads, defaults to 4
+	Concurrency int
+	// SkipVerify disables the size and ETag checks of downloaded files
+	SkipVerify bool
+}
+
+// DownloadResult reports the outcome of a single file downloaded by DownloadPrefix
+type DownloadResult struct {
+	Key     string `json:"key"`
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ETag    string `json:"etag"`
+	Skipped bool   `json:"skipped"`
+	Err     error  `json:"-"`
+}
+
+// DownloadPrefix downloads every object under prefix into localDir, keeping the key layout relative to prefix.
+// Files already present with a matching size and checksum are skipped, interrupted downloads are resumed,
+// and every file is verified against the listing. The report has one entry per object, in listing order.
+func (cache *Cache) DownloadPrefix(prefix, localDir string, opts DownloadOptions) ([]DownloadResult, error) {
+	cache.logger.Info(fmt.Sprintf("Downloading prefix=%v to dir=%v", prefix, localDir))
+	objects, err := cache.List(prefix, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return nil, err
+	}
+
+	results := make([]DownloadResult, 0, len(objects))
+	for _, info := range objects {
+		name := strings.TrimPrefix(strings.TrimPrefix(info.Key, prefix), "/")
+		if "" == name || strings.HasSuffix(name, "/") {
+			continue
+		}
+		results = append(results, DownloadResult{
+			Key:  info.Key,
+			Path: filepath.Join(localDir, filepath.FromSlash(filepath.Clean("/"+name))),
+			Size: info.Size,
+			ETag: info.ETag,
+		})
+	}
+
+	parallel(opts.Concurrency, len(results), func(index int) {
+		cache.downloadFile(&results[index], opts)
+	})
+
+	failed := 0
+	for _, result := range results {
+		if nil != result.Err {
+			failed++
+		}
+	}
+	if failed > 0 {
+		err = errors.New(fmt.Sprintf("Failed to download %v of %v files", failed, len(results)))
+		cache.logger.Error(err.Error())
+		return results, err
+	}
+
+	cache.logger.Info(fmt.Sprintf("Successfully downloaded files: %v", len(results)))
+	return results, nil
+}
+
+// downloadFile downloads a single object, recording the outcome on the result
+func (cache *Cache) downloadFile(result *DownloadResult, opts DownloadOptions) {
+	if nil == verifyFile(result.Path, result.Size, result.ETag) {
+		cache.logger.Info(fmt.Sprintf("Skipping up to date file=%v", result.Path))
+		result.Skipped = true
+		return
+	}
+
+	key := cache.objectKey(result.Key)
+	if err := cache.authorize(AuthGet, key, nil); nil != err {
+		result.Err = err
+		return
+	}
+
+	// On minio FGetObject resumes from a partial file named after the ETag
+	err := cache.getFile(key, result.Path)
+	if nil == err && !opts.SkipVerify {
+		err = verifyFile(result.Path, result.Size, result.ETag)
+	}
+	if nil != err {
+		result.Err = errors.Wrap(err, fmt.Sprintf("Failed to download %v", result.Key))
+		cache.logger.Error(result.Err.Error())
+		return
+	}
+	cache.logger.Info(fmt.Sprintf("Downloaded path=%v to file=%v", result.Key, result.Path))
+}
+
+// verifyFile checks a local file against the size and ETag of an object,
+// the checksum is only compared for single part uploads whose ETag is the MD5 of the content
+func verifyFile(file string, size int64, etag string) error {
+	info, err := os.Stat(file)
+	if nil != err {
+		return err
+	}
+	if info.Size() != size {
+		return &ContentMismatchError{Path: file, Field: "size", Expected: fmt.Sprintf("%v", size), Actual: fmt.Sprintf("%v", info.Size())}
+	}
+
+	etag = strings.Trim(etag, "\"")
+	if strings.Contains(etag, "-") {
+		return nil
+	}
+	reader, err := os.Open(file)
+	if nil != err {
+		return err
+	}
+	defer reader.Close()
+	hash := md5.New()
+	if _, err := io.Copy(hash, reader); nil != err {
+		return err
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != etag {
+		return &ContentMismatchError{Path: file, Field: "etag", Expected: etag, Actual: actual}
+	}
+	return nil
+}
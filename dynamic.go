@@ -0,0 +1,99 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"sync"
+)
+
+// descriptorRegistry holds the descriptors registered with RegisterDescriptorSet
+type descriptorRegistry struct {
+	sync.RWMutex
+	set   *descriptorpb.FileDescriptorSet
+	files *protoregistry.Files
+}
+
+// RegisterDescriptorSet makes the messages of a FileDescriptorSet available to the dynamic PROTO helpers,
+// files already registered under the same name are kept
+func (cache *Cache) RegisterDescriptorSet(set *descriptorpb.FileDescriptorSet) error {
+	cache.descriptors.Lock()
+	defer cache.descriptors.Unlock()
+
+	merged := &descriptorpb.FileDescriptorSet{}
+	seen := map[string]bool{}
+	if nil != cache.descriptors.set {
+		for _, file := range cache.descriptors.set.File {
+			seen[file.GetName()] = true
+			merged.File = append(merged.File, file)
+		}
+	}
+	for _, file := range set.GetFile() {
+		if !seen[file.GetName()] {
+			seen[file.GetName()] = true
+			merged.File = append(merged.File, file)
+		}
+	}
+
+	files, err := protodesc.NewFiles(merged)
+	if nil != err {
+		err = errors.Wrap(err, "Failed to build descriptors from FileDescriptorSet")
+		cache.logger.Error(err.Error())
+		return err
+	}
+
+	cache.descriptors.set = merged
+	cache.descriptors.files = files
+	cache.logger.Info(fmt.Sprintf("Registered %v proto files", len(merged.File)))
+	return nil
+}
+
+// LoadDescriptorSet reads a serialized FileDescriptorSet from minio and registers it
+func (cache *Cache) LoadDescriptorSet(path string, opts minio.GetObjectOptions) error {
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := cache.GetPROTO(path, set, nil, opts); nil != err {
+		return err
+	}
+	return cache.RegisterDescriptorSet(set)
+}
+
+// NewDynamicMessage creates an empty message of a registered type, for use with PutPROTO and GetPROTO
+func (cache *Cache) NewDynamicMessage(messageFullName string) (*dynamicpb.Message, error) {
+	cache.descriptors.RLock()
+	files := cache.descriptors.files
+	cache.descriptors.RUnlock()
+	if nil == files {
+		files = &protoregistry.Files{}
+	}
+
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(messageFullName))
+	if nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to find descriptor for %v", messageFullName))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	messageDescriptor, ok := descriptor.(protoreflect.MessageDescriptor)
+	if !ok {
+		err = errors.New(fmt.Sprintf("Descriptor %v is not a message", messageFullName))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	return dynamicpb.NewMessage(messageDescriptor), nil
+}
+
+// GetPROTODynamic reads a PROTO file from minio using a message type from the registered descriptor sets
+func (cache *Cache) GetPROTODynamic(path, messageFullName string, opts minio.GetObjectOptions) (*dynamicpb.Message, error) {
+	output, err := cache.NewDynamicMessage(messageFullName)
+	if nil != err {
+		return nil, err
+	}
+	if err := cache.GetPROTO(path, output, nil, opts); nil != err {
+		return nil, err
+	}
+	return output, nil
+}
@@ -0,0 +1,61 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrEmptyPayload is returned by the Get helpers when reading a zero-byte object with EmptyAsError
+var ErrEmptyPayload = errors.New("Object has an empty payload")
+
+// ErrNilMessage is returned when a nil proto.Message is passed to the PROTO helpers
+var ErrNilMessage = errors.New("Proto message is nil")
+
+// EmptyPayloadMode controls how the Get helpers treat zero-byte objects
+type EmptyPayloadMode int
+
+const (
+	// EmptyAsZeroValue decodes zero-byte objects to the zero value of each format:
+	// an empty message for PROTO, an untouched output for JSON and no records for CSV
+	EmptyAsZeroValue EmptyPayloadMode = iota
+	// EmptyAsError returns ErrEmptyPayload when a zero-byte object is read
+	EmptyAsError
+)
+
+// WithEmptyPayloads sets how the Get helpers treat zero-byte objects, defaults to EmptyAsZeroValue
+func WithEmptyPayloads(mode EmptyPayloadMode) Option {
+	return func(cache *Cache) {
+		cache.emptyMode = mode
+	}
+}
+
+// PutEmptyMarker writes a zero-byte object to minio, for use as a sentinel or flag
+func (cache *Cache) PutEmptyMarker(path string, opts minio.PutObjectOptions) (*WriteResult, error) {
+	cache.logger.Info(fmt.Sprintf("Writing empty marker, path=%v", path))
+	return cache.WriteData(path, []byte{}, opts)
+}
+
+// checkEmpty reports whether the payload is empty, returning ErrEmptyPayload if empty payloads are not allowed
+func (cache *Cache) checkEmpty(path string, payload []byte) (bool, error) {
+	if len(payload) > 0 {
+		return false, nil
+	}
+	if cache.emptyMode == EmptyAsError {
+		err := errors.Wrap(ErrEmptyPayload, fmt.Sprintf("Failed to decode path=%v", path))
+		cache.logger.Error(err.Error())
+		return true, err
+	}
+	cache.logger.Info(fmt.Sprintf("Empty payload at path=%v", path))
+	return true, nil
+}
+
+// checkMessage rejects nil and typed-nil proto messages before they reach the proto runtime
+func (cache *Cache) checkMessage(data proto.Message) error {
+	if nil == data || !data.ProtoReflect().IsValid() {
+		cache.logger.Error(ErrNilMessage.Error())
+		return ErrNilMessage
+	}
+	return nil
+}
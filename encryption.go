@@ -0,0 +1,303 @@
+package minioproto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrDecryptionFailed is returned when an encrypted payload can't be decrypted
+var ErrDecryptionFailed = errors.New("Failed to decrypt payload")
+
+// ErrConcurrentModification is returned by Rotate when an object changed while it was being re-encrypted
+var ErrConcurrentModification = errors.New("Object was modified concurrently")
+
+// encryptionMagic prefixes every encrypted payload, followed by the key id length, key id, nonce and ciphertext
+var encryptionMagic = []byte("MPE1")
+
+// envelopeMetadata lists the envelopes wrapping a stored payload in the order they were applied. Payloads are
+// only unwrapped when it names them, so plaintext that happens to start with a magic is returned as stored.
+const envelopeMetadata = "Payload-Envelope"
+
+// encryptedEnvelope marks payloads sealed by encryptPayload
+const encryptedEnvelope = "encrypted"
+
+// KeyProvider supplies the AES-256 keys used for client-side encryption
+type KeyProvider interface {
+	// CurrentKey returns the id and 32 byte key used to encrypt new payloads
+	CurrentKey() (string, []byte, error)
+	// Key returns the key with the given id, for decrypting existing payloads
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeyProvider is a KeyProvider with a current key and optional older keys for decryption
+type StaticKeyProvider struct {
+	ID      string
+	Secret  []byte
+	Retired map[string][]byte
+}
+
+// CurrentKey returns the current key
+func (keys StaticKeyProvider) CurrentKey() (string, []byte, error) {
+	return keys.ID, keys.Secret, nil
+}
+
+// Key returns the current key or a retired key by id
+func (keys StaticKeyProvider) Key(id string) ([]byte, error) {
+	if id == keys.ID {
+		return keys.Secret, nil
+	}
+	if secret, ok := keys.Retired[id]; ok {
+		return secret, nil
+	}
+	return nil, errors.New(fmt.Sprintf("Unknown encryption key %v", id))
+}
+
+// WithEncryption encrypts payloads client-side with AES-256-GCM in WriteData and decrypts them in
+// ReadData, so every format helper stores ciphertext. Unencrypted payloads are still readable.
+// Operations that bypass WriteData and ReadData (ranged reads, packed records, Concat, archives,
+// UploadDir and DownloadPrefix) work on the stored bytes and aren't encrypted.
+func WithEncryption(keys KeyProvider) Option {
+	return func(cache *Cache) {
+		cache.keys = keys
+	}
+}
+
+// encryptPayload seals data with the current key of the provider
+func encryptPayload(keys KeyProvider, data []byte) ([]byte, error) {
+	id, secret, err := keys.CurrentKey()
+	if nil != err {
+		return nil, errors.Wrap(err, "Failed to get encryption key")
+	}
+	if len(id) > 255 {
+		return nil, errors.New("Encryption key id is longer than 255 bytes")
+	}
+	aead, err := newAEAD(secret)
+	if nil != err {
+		return nil, err
+	}
+
+	header := append(append([]byte{}, encryptionMagic...), byte(len(id)))
+	header = append(header, id...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); nil != err {
+		return nil, err
+	}
+	output := append(append([]byte{}, header...), nonce...)
+	return aead.Seal(output, nonce, data, header), nil
+}
+
+// decryptPayload opens an encrypted payload
+func decryptPayload(keys KeyProvider, data []byte) ([]byte, error) {
+	if !isEncrypted(data) {
+		return nil, errors.Wrap(ErrDecryptionFailed, "missing header")
+	}
+	idLength := int(data[len(encryptionMagic)])
+	headerLength := len(encryptionMagic) + 1 + idLength
+	if len(data) < headerLength {
+		return nil, errors.Wrap(ErrDecryptionFailed, "truncated header")
+	}
+	id := string(data[len(encryptionMagic)+1 : headerLength])
+
+	secret, err := keys.Key(id)
+	if nil != err {
+		return nil, errors.Wrap(ErrDecryptionFailed, err.Error())
+	}
+	aead, err := newAEAD(secret)
+	if nil != err {
+		return nil, err
+	}
+	if len(data) < headerLength+aead.NonceSize() {
+		return nil, errors.Wrap(ErrDecryptionFailed, "truncated nonce")
+	}
+	nonce := data[headerLength : headerLength+aead.NonceSize()]
+	output, err := aead.Open(nil, nonce, data[headerLength+aead.NonceSize():], data[:headerLength])
+	if nil != err {
+		return nil, errors.Wrap(ErrDecryptionFailed, err.Error())
+	}
+	return output, nil
+}
+
+func isEncrypted(data []byte) bool {
+	return len(data) > len(encryptionMagic) && bytes.Equal(data[:len(encryptionMagic)], encryptionMagic)
+}
+
+func newAEAD(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if nil != err {
+		return nil, errors.Wrap(err, "Invalid encryption key")
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealPayload encrypts a payload about to be written when encryption is enabled, flagging it in the metadata
+func (cache *Cache) sealPayload(path string, data []byte, opts minio.PutObjectOptions) ([]byte, minio.PutObjectOptions, error) {
+	keys := cache.keysFor(path)
+	if nil == keys {
+		return data, opts, nil
+	}
+	output, err := encryptPayload(keys, data)
+	if nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to encrypt path=%v", path))
+		cache.logger.Error(err.Error())
+		return nil, opts, err
+	}
+	return output, withEnvelope(opts, encryptedEnvelope), nil
+}
+
+// withEnvelope records an envelope applied to the payload in the metadata
+func withEnvelope(opts minio.PutObjectOptions, envelope string) minio.PutObjectOptions {
+	if current := opts.UserMetadata[envelopeMetadata]; "" != current {
+		envelope = current + "," + envelope
+	}
+	return withUserMetadata(opts, envelopeMetadata, envelope)
+}
+
+// hasEnvelope reports whether the envelopes recorded in the metadata include envelope
+func hasEnvelope(envelopes, envelope string) bool {
+	for _, name := range strings.Split(envelopes, ",") {
+		if envelope == name {
+			return true
+		}
+	}
+	return false
+}
+
+// openPayload decrypts and decompresses a payload that was read, unwrapping the envelopes recorded in its
+// metadata. Ranged reads are returned as stored.
+func (cache *Cache) openPayload(path string, data []byte, envelopes string, opts minio.GetObjectOptions) ([]byte, error) {
+	if "" != opts.Header().Get("Range") {
+		return data, nil
+	}
+	if hasEnvelope(envelopes, encryptedEnvelope) {
+		keys := cache.keysFor(path)
+		if nil == keys {
+			err := errors.Wrap(ErrDecryptionFailed, fmt.Sprintf("no keys configured for path=%v", path))
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
+		output, err := decryptPayload(keys, data)
+		if nil != err {
+			err = errors.Wrap(err, fmt.Sprintf("Failed to decrypt path=%v", path))
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
+		data = output
+	}
+	data, err := cache.decompressPayload(path, data, envelopes)
+	if nil != err {
+		return nil, err
+	}
+	return cache.decompressForPath(path, data)
+}
+
+// RotateOptions configures Rotate
+type RotateOptions struct {
+	// Concurrency is the number of objects re-encrypted in parallel, defaults to 4
+	Concurrency int
+}
+
+// Rotate re-encrypts every encrypted object under prefix with the current key of newKeys,
+// decrypting with oldKeys and preserving the content headers, user metadata, tags and storage class of each object.
+// Each object is rewritten with a conditional put on the ETag that was read, so objects that changed in between
+// fail with ErrConcurrentModification and should be retried. The backend must implement ConditionalBackend.
+// Returns the number of objects rotated.
+func (cache *Cache) Rotate(prefix string, oldKeys, newKeys KeyProvider, opts RotateOptions) (int, error) {
+	cache.logger.Info(fmt.Sprintf("Rotating encryption keys under prefix=%v", prefix))
+	objects, err := cache.List(prefix, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return 0, err
+	}
+
+	var rotated int64
+	errs := make([]error, len(objects))
+	parallel(opts.Concurrency, len(objects), func(index int) {
+		done, err := cache.rotateObject(objects[index].Key, oldKeys, newKeys)
+		if done {
+			atomic.AddInt64(&rotated, 1)
+		}
+		errs[index] = err
+	})
+
+	failed := []string{}
+	for i, err := range errs {
+		if nil != err {
+			cache.logger.Error(err.Error())
+			failed = append(failed, objects[i].Key)
+		}
+	}
+	if 0 != len(failed) {
+		err = errors.New(fmt.Sprintf("Failed to rotate %v objects: %v", len(failed), strings.Join(failed, ", ")))
+		return int(rotated), err
+	}
+
+	cache.logger.Info(fmt.Sprintf("Rotated %v of %v objects under prefix=%v", rotated, len(objects), prefix))
+	return int(rotated), nil
+}
+
+// rotateObject re-encrypts a single object, reporting whether it was rewritten
+func (cache *Cache) rotateObject(path string, oldKeys, newKeys KeyProvider) (bool, error) {
+	path = cache.objectKey(path)
+	if err := cache.authorize(AuthPut, path, nil); nil != err {
+		return false, err
+	}
+	obj, err := cache.backend.Get(cache.ctx, path, minio.GetObjectOptions{})
+	if nil != err {
+		return false, errors.Wrap(err, fmt.Sprintf("Failed to get %v", path))
+	}
+	defer obj.Close()
+	info, err := obj.Stat()
+	if nil != err {
+		return false, errors.Wrap(err, fmt.Sprintf("Failed to stat %v", path))
+	}
+	data, err := ioutil.ReadAll(obj)
+	if nil != err {
+		return false, errors.Wrap(err, fmt.Sprintf("Failed to read %v", path))
+	}
+	if !hasEnvelope(info.UserMetadata[envelopeMetadata], encryptedEnvelope) {
+		return false, nil
+	}
+
+	plaintext, err := decryptPayload(oldKeys, data)
+	if nil != err {
+		return false, errors.Wrap(err, fmt.Sprintf("Failed to decrypt %v", path))
+	}
+	sealed, err := encryptPayload(newKeys, plaintext)
+	if nil != err {
+		return false, errors.Wrap(err, fmt.Sprintf("Failed to encrypt %v", path))
+	}
+
+	tags, err := cache.objectTags(path, info)
+	if nil != err {
+		return false, err
+	}
+	opts := minio.PutObjectOptions{
+		UserMetadata:       info.UserMetadata,
+		UserTags:           tags,
+		ContentType:        info.ContentType,
+		ContentEncoding:    info.Metadata.Get("Content-Encoding"),
+		ContentDisposition: info.Metadata.Get("Content-Disposition"),
+		ContentLanguage:    info.Metadata.Get("Content-Language"),
+		CacheControl:       info.Metadata.Get("Cache-Control"),
+		StorageClass:       storageClassOf(info),
+	}
+	if opts, err = cache.signPayload(path, sealed, opts); nil != err {
+		return false, err
+	}
+	// Only replace the object read above, writers may have replaced it while it was being re-encrypted
+	if _, err := cache.putIf(path, sealed, opts, PutCondition{IfMatch: info.ETag}); nil != err {
+		if ErrPreconditionFailed == errors.Cause(err) {
+			return false, errors.Wrap(ErrConcurrentModification, path)
+		}
+		return false, errors.Wrap(err, fmt.Sprintf("Failed to write %v", path))
+	}
+	cache.invalidateTiers(path)
+	return true, nil
+}
@@ -0,0 +1,83 @@
+package minioproto
+
+import (
+	"bytes"
+	"context"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"testing"
+)
+
+var oldTestKeys = StaticKeyProvider{ID: "old", Secret: bytes.Repeat([]byte{1}, 32)}
+var newTestKeys = StaticKeyProvider{ID: "new", Secret: bytes.Repeat([]byte{2}, 32)}
+
+func TestEncryption(t *testing.T) {
+	cache, backend := newTestCache(t, WithEncryption(oldTestKeys))
+	if _, err := cache.WriteData("secret", []byte("hello"), minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	stored := getString(t, backend, "secret", minio.GetObjectOptions{})
+	if !isEncrypted([]byte(stored)) || bytes.Contains([]byte(stored), []byte("hello")) {
+		t.Fatalf("expected ciphertext, got %q", stored)
+	}
+	if data, err := cache.ReadData("secret", minio.GetObjectOptions{}); nil != err || "hello" != string(data) {
+		t.Fatalf("expected hello, got %v %v", string(data), err)
+	}
+
+	// Unencrypted payloads stay readable
+	putString(t, backend, "plain", "plain", minio.PutObjectOptions{})
+	if data, err := cache.ReadData("plain", minio.GetObjectOptions{}); nil != err || "plain" != string(data) {
+		t.Fatalf("expected plain, got %v %v", string(data), err)
+	}
+
+	other, err := NewWithBackend(context.Background(), zap.NewNop(), backend, WithEncryption(newTestKeys))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := other.ReadData("secret", minio.GetObjectOptions{}); ErrDecryptionFailed != errors.Cause(err) {
+		t.Fatalf("expected ErrDecryptionFailed with the wrong key, got %v", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	cache, backend := newTestCache(t, WithEncryption(oldTestKeys))
+	opts := minio.PutObjectOptions{ContentType: "text/plain", UserMetadata: map[string]string{"Owner": "me"}}
+	for _, key := range []string{"data/a", "data/b"} {
+		if _, err := cache.WriteData(key, []byte(key), opts); nil != err {
+			t.Fatal(err)
+		}
+	}
+	putString(t, backend, "data/plain", "plain", minio.PutObjectOptions{})
+
+	rotated, err := cache.Rotate("data/", oldTestKeys, newTestKeys, RotateOptions{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 2 != rotated {
+		t.Fatalf("expected 2 objects rotated, got %v", rotated)
+	}
+
+	rotatedCache, err := NewWithBackend(context.Background(), zap.NewNop(), backend, WithEncryption(newTestKeys))
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"data/a", "data/b"} {
+		if data, err := rotatedCache.ReadData(key, minio.GetObjectOptions{}); nil != err || key != string(data) {
+			t.Fatalf("expected %v with the new key, got %v %v", key, string(data), err)
+		}
+		if _, err := cache.ReadData(key, minio.GetObjectOptions{}); ErrDecryptionFailed != errors.Cause(err) {
+			t.Fatalf("expected the old key to fail after rotation, got %v", err)
+		}
+	}
+	info, err := backend.Stat(context.Background(), "data/a", minio.StatObjectOptions{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "text/plain" != info.ContentType || "me" != metadataValue(info.UserMetadata, "Owner") {
+		t.Fatalf("expected the content type and metadata to be preserved, got %v %v", info.ContentType, info.UserMetadata)
+	}
+	if value := getString(t, backend, "data/plain", minio.GetObjectOptions{}); "plain" != value {
+		t.Fatalf("expected unencrypted objects to be left alone, got %v", value)
+	}
+}
@@ -0,0 +1,83 @@
+package minioproto
+
+import (
+	"sync"
+)
+
+// EventOp is the kind of operation that fired an Event
+type EventOp string
+
+const (
+	// EventPut is fired after an object has been written
+	EventPut EventOp = "put"
+	// EventGet is fired after an object has been read, including reads served by local tiers
+	EventGet EventOp = "get"
+	// EventDelete is fired after an object has been removed
+	EventDelete EventOp = "delete"
+)
+
+// Event describes an operation performed by this Cache, as opposed to bucket notifications
+// which also report the operations of other clients
+type Event struct {
+	Op        EventOp
+	Path      string
+	Size      int64
+	ETag      string
+	VersionID string
+	// Attributes are the tracing attributes of the view that performed the operation, see CallAttributes
+	Attributes map[string]string
+}
+
+// eventHooks holds the callbacks registered on a Cache
+type eventHooks struct {
+	sync.RWMutex
+	hooks map[EventOp][]func(Event)
+}
+
+// OnPut registers a callback fired synchronously after every successful write
+func (cache *Cache) OnPut(fn func(Event)) {
+	cache.addHook(EventPut, fn)
+}
+
+// OnGet registers a callback fired synchronously after every successful read
+func (cache *Cache) OnGet(fn func(Event)) {
+	cache.addHook(EventGet, fn)
+}
+
+// OnDelete registers a callback fired synchronously after every successful delete
+func (cache *Cache) OnDelete(fn func(Event)) {
+	cache.addHook(EventDelete, fn)
+}
+
+func (cache *Cache) addHook(op EventOp, fn func(Event)) {
+	cache.events.Lock()
+	defer cache.events.Unlock()
+	if nil == cache.events.hooks {
+		cache.events.hooks = map[EventOp][]func(Event){}
+	}
+	cache.events.hooks[op] = append(cache.events.hooks[op], fn)
+}
+
+// fire calls the callbacks registered for the event's operation
+func (cache *Cache) fire(event Event) {
+	if nil == event.Attributes {
+		event.Attributes = cache.attributes
+	}
+	cache.events.RLock()
+	hooks := cache.events.hooks[event.Op]
+	cache.events.RUnlock()
+	for _, fn := range hooks {
+		fn(event)
+	}
+}
+
+// firePut fires an EventPut for a completed write
+func (cache *Cache) firePut(path string, result *WriteResult) {
+	cache.fire(Event{
+		Op:        EventPut,
+		Path:      path,
+		Size:      result.Size,
+		ETag:      result.ETag,
+		VersionID: result.VersionID,
+	})
+}
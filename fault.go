@@ -0,0 +1,138 @@
+package minioproto
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FaultRule injects failures into the operations of a Cache matching Prefix and Ops
+type FaultRule struct {
+	// Prefix limits the rule to keys under it, empty matches every key
+	Prefix string
+	// Ops limits the rule to the given operations, empty matches every operation
+	Ops []AuthOp
+	// ErrorRate is the probability, between 0 and 1, that an operation fails
+	ErrorRate float64
+	// Err is returned by failed operations, defaults to a *FaultError
+	Err error
+	// Latency is added before every matching operation
+	Latency time.Duration
+	// TruncateRate is the probability that a read returns only part of the payload
+	TruncateRate float64
+}
+
+// FaultError is the default error of injected failures, it is a net.Error so it is
+// handled like a network failure, e.g. writes are spooled with WithSpool
+type FaultError struct {
+	Op   AuthOp
+	Path string
+}
+
+// Error describes the injected failure
+func (err *FaultError) Error() string {
+	return fmt.Sprintf("Injected fault for %v path=%v", err.Op, err.Path)
+}
+
+// Timeout reports the fault as a timeout
+func (err *FaultError) Timeout() bool {
+	return true
+}
+
+// Temporary reports the fault as temporary
+func (err *FaultError) Temporary() bool {
+	return true
+}
+
+// faultState holds the rules and random source of the fault injection
+type faultState struct {
+	sync.Mutex
+	random *rand.Rand
+	rules  []FaultRule
+}
+
+// WithFaults injects latency, errors and truncated reads into reads, writes, stats, deletes and
+// listings for use in integration tests and staging, seed makes the injected faults reproducible.
+// Helpers that call minio directly (ranged reads, archives, Concat, uploads) are not affected.
+// Never use it in production.
+func WithFaults(seed int64, rules ...FaultRule) Option {
+	return func(cache *Cache) {
+		cache.faults = &faultState{random: rand.New(rand.NewSource(seed)), rules: rules}
+	}
+}
+
+// CallFaults injects faults into a view returned by With, leaving the shared Cache unaffected
+func CallFaults(seed int64, rules ...FaultRule) CallOption {
+	return CallOption(WithFaults(seed, rules...))
+}
+
+// injectFault delays the operation and returns an error when a matching rule fails it
+func (cache *Cache) injectFault(op AuthOp, path string) error {
+	if nil == cache.faults {
+		return nil
+	}
+	var latency time.Duration
+	var err error
+	cache.faults.Lock()
+	for _, rule := range cache.faults.matching(op, path) {
+		latency += rule.Latency
+		if nil == err && rule.ErrorRate > 0 && cache.faults.random.Float64() < rule.ErrorRate {
+			err = rule.Err
+			if nil == err {
+				err = &FaultError{Op: op, Path: path}
+			}
+		}
+	}
+	cache.faults.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		select {
+		case <-timer.C:
+		case <-cache.ctx.Done():
+			timer.Stop()
+			return cache.ctx.Err()
+		}
+	}
+	if nil != err {
+		cache.logger.Warn(fmt.Sprintf("Injecting fault for %v path=%v: %v", op, path, err.Error()))
+	}
+	return err
+}
+
+// truncateFault cuts a payload that was read when a matching rule truncates it
+func (cache *Cache) truncateFault(path string, data []byte) []byte {
+	if nil == cache.faults || 0 == len(data) {
+		return data
+	}
+	cache.faults.Lock()
+	defer cache.faults.Unlock()
+	for _, rule := range cache.faults.matching(AuthGet, path) {
+		if rule.TruncateRate > 0 && cache.faults.random.Float64() < rule.TruncateRate {
+			size := cache.faults.random.Intn(len(data))
+			cache.logger.Warn(fmt.Sprintf("Injecting truncated read of %v/%v bytes for path=%v", size, len(data), path))
+			return data[:size]
+		}
+	}
+	return data
+}
+
+// matching returns the rules applying to an operation
+func (faults *faultState) matching(op AuthOp, path string) []FaultRule {
+	output := []FaultRule{}
+	for _, rule := range faults.rules {
+		if !strings.HasPrefix(path, rule.Prefix) {
+			continue
+		}
+		matched := 0 == len(rule.Ops)
+		for _, ruleOp := range rule.Ops {
+			matched = matched || ruleOp == op
+		}
+		if matched {
+			output = append(output, rule)
+		}
+	}
+	return output
+}
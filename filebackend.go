@@ -0,0 +1,438 @@
+package minioproto
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileBackend is a Backend storing objects as files under dir/objects with their metadata in dir/meta
+type fileBackend struct {
+	mutex sync.RWMutex
+	dir   string
+}
+
+// fileMeta is stored alongside each object
+type fileMeta struct {
+	ETag               string            `json:"etag"`
+	Size               int64             `json:"size"`
+	LastModified       time.Time         `json:"lastModified"`
+	ContentType        string            `json:"contentType"`
+	CacheControl       string            `json:"cacheControl,omitempty"`
+	ContentDisposition string            `json:"contentDisposition,omitempty"`
+	ContentLanguage    string            `json:"contentLanguage,omitempty"`
+	ContentEncoding    string            `json:"contentEncoding,omitempty"`
+	StorageClass       string            `json:"storageClass,omitempty"`
+	UserMetadata       map[string]string `json:"userMetadata,omitempty"`
+	UserTags           map[string]string `json:"userTags,omitempty"`
+}
+
+// NewFileBackend returns a Backend storing objects on the local filesystem under dir, for tests and
+// environments without an object store. Versioning and multipart uploads aren't supported, listing
+// WithVersions fails with ErrUnsupportedBackend, and a key can't be both an object and the prefix of
+// another object, e.g. "a" and "a/b". Directories left empty by Delete are removed.
+func NewFileBackend(dir string) Backend {
+	return &fileBackend{dir: dir}
+}
+
+// Get returns the body of key
+func (backend *fileBackend) Get(ctx context.Context, key string, opts minio.GetObjectOptions) (BackendObject, error) {
+	if "" != opts.VersionID {
+		return nil, errors.Wrap(ErrUnsupportedBackend, "versioned reads")
+	}
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+	info, err := backend.stat(key)
+	if nil != err {
+		return nil, err
+	}
+	if match := strings.Trim(opts.Header().Get("If-Match"), "\""); "" != match && match != info.ETag {
+		return nil, minio.ErrorResponse{StatusCode: 412, Code: "PreconditionFailed", Message: "ETag does not match", Key: key}
+	}
+	start, length, err := parseRange(opts.Header().Get("Range"), info.Size)
+	if nil != err {
+		return nil, err
+	}
+
+	dataPath, _, err := backend.files(key)
+	if nil != err {
+		return nil, err
+	}
+	file, err := os.Open(dataPath)
+	if nil != err {
+		return nil, err
+	}
+	return &fileObject{SectionReader: io.NewSectionReader(file, start, length), file: file, info: info}, nil
+}
+
+// Put stores the reader at key, replacing the object atomically
+func (backend *fileBackend) Put(ctx context.Context, key string, reader io.Reader, size int64, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	return backend.PutIf(ctx, key, reader, size, opts, PutCondition{})
+}
+
+// PutIf stores the reader at key when cond holds, the condition is checked atomically with the replacement
+func (backend *fileBackend) PutIf(ctx context.Context, key string, reader io.Reader, size int64, opts minio.PutObjectOptions, cond PutCondition) (minio.UploadInfo, error) {
+	dataPath, metaPath, err := backend.files(key)
+	if nil != err {
+		return minio.UploadInfo{}, err
+	}
+	if size >= 0 {
+		reader = io.LimitReader(reader, size)
+	}
+
+	// Write outside the lock, only the renames need to be atomic. The temp file is created under the lock
+	// so a concurrent Delete can't prune its directory.
+	backend.mutex.Lock()
+	err = os.MkdirAll(filepath.Dir(dataPath), 0755)
+	var temp *os.File
+	if nil == err {
+		temp, err = ioutil.TempFile(filepath.Dir(dataPath), ".upload-")
+	}
+	backend.mutex.Unlock()
+	if nil != err {
+		return minio.UploadInfo{}, err
+	}
+	defer os.Remove(temp.Name())
+	hash := md5.New()
+	written, err := io.Copy(io.MultiWriter(temp, hash), reader)
+	if closeErr := temp.Close(); nil == err {
+		err = closeErr
+	}
+	if nil == err && size >= 0 && written != size {
+		err = io.ErrUnexpectedEOF
+	}
+	if nil != err {
+		return minio.UploadInfo{}, err
+	}
+
+	contentType := opts.ContentType
+	if "" == contentType {
+		contentType = "application/octet-stream"
+	}
+	metadata := map[string]string{}
+	for k, v := range opts.UserMetadata {
+		metadata[http.CanonicalHeaderKey(k)] = v
+	}
+	meta := fileMeta{
+		ETag:               hex.EncodeToString(hash.Sum(nil)),
+		Size:               written,
+		LastModified:       time.Now().UTC(),
+		ContentType:        contentType,
+		CacheControl:       opts.CacheControl,
+		ContentDisposition: opts.ContentDisposition,
+		ContentLanguage:    opts.ContentLanguage,
+		ContentEncoding:    opts.ContentEncoding,
+		StorageClass:       opts.StorageClass,
+		UserMetadata:       metadata,
+		UserTags:           opts.UserTags,
+	}
+	encoded, err := json.Marshal(meta)
+	if nil != err {
+		return minio.UploadInfo{}, err
+	}
+
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	if cond.IfNoneMatch || "" != cond.IfMatch {
+		current, err := backend.stat(key)
+		if nil != err && "NoSuchKey" != minio.ToErrorResponse(err).Code {
+			return minio.UploadInfo{}, err
+		}
+		exists := nil == err
+		if (cond.IfNoneMatch && exists) || ("" != cond.IfMatch && (!exists || current.ETag != strings.Trim(cond.IfMatch, "\""))) {
+			return minio.UploadInfo{}, errors.Wrap(ErrPreconditionFailed, key)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0755); nil != err {
+		return minio.UploadInfo{}, err
+	}
+	if err := ioutil.WriteFile(metaPath, encoded, 0644); nil != err {
+		return minio.UploadInfo{}, err
+	}
+	if err := os.Rename(temp.Name(), dataPath); nil != err {
+		return minio.UploadInfo{}, err
+	}
+	return minio.UploadInfo{Key: key, ETag: meta.ETag, Size: written, LastModified: meta.LastModified}, nil
+}
+
+// Stat describes key
+func (backend *fileBackend) Stat(ctx context.Context, key string, opts minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	if "" != opts.VersionID {
+		return minio.ObjectInfo{}, errors.Wrap(ErrUnsupportedBackend, "versioned reads")
+	}
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+	return backend.stat(key)
+}
+
+// List sends the objects under opts.Prefix in key order
+func (backend *fileBackend) List(ctx context.Context, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+	output := make(chan minio.ObjectInfo, 1)
+	go func() {
+		defer close(output)
+		keys, err := backend.keys()
+		if nil != err {
+			output <- minio.ObjectInfo{Err: err}
+			return
+		}
+
+		if opts.WithVersions {
+			output <- minio.ObjectInfo{Err: errors.Wrap(ErrUnsupportedBackend, "versioned listings")}
+			return
+		}
+
+		seen := map[string]bool{}
+		for _, key := range keys {
+			if !strings.HasPrefix(key, opts.Prefix) {
+				continue
+			}
+			var info minio.ObjectInfo
+			if index := strings.Index(key[len(opts.Prefix):], "/"); !opts.Recursive && index >= 0 {
+				// Group keys below the next delimiter into a common prefix like minio
+				common := key[:len(opts.Prefix)+index+1]
+				if seen[common] {
+					continue
+				}
+				seen[common] = true
+				info = minio.ObjectInfo{Key: common}
+			} else {
+				backend.mutex.RLock()
+				info, err = backend.stat(key)
+				backend.mutex.RUnlock()
+				if nil != err {
+					// Removed while listing
+					continue
+				}
+				info.IsLatest = true
+			}
+			select {
+			case output <- info:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Delete removes key, missing keys are not an error
+func (backend *fileBackend) Delete(ctx context.Context, key string, opts minio.RemoveObjectOptions) error {
+	dataPath, metaPath, err := backend.files(key)
+	if nil != err {
+		return err
+	}
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	for _, file := range []string{dataPath, metaPath} {
+		if err := os.Remove(file); nil != err && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	backend.prune(filepath.Dir(dataPath), filepath.Join(backend.dir, "objects"))
+	backend.prune(filepath.Dir(metaPath), filepath.Join(backend.dir, "meta"))
+	return nil
+}
+
+// prune removes dir and its parents below root while they are empty, the lock must be held
+func (backend *fileBackend) prune(dir, root string) {
+	for dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)) {
+		if err := os.Remove(dir); nil != err {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// Copy concatenates srcs into dst
+func (backend *fileBackend) Copy(ctx context.Context, dst minio.CopyDestOptions, srcs ...minio.CopySrcOptions) (minio.UploadInfo, error) {
+	readers := []io.Reader{}
+	var first minio.ObjectInfo
+	for i, src := range srcs {
+		getOpts := minio.GetObjectOptions{VersionID: src.VersionID}
+		if src.MatchRange {
+			if err := getOpts.SetRange(src.Start, src.End); nil != err {
+				return minio.UploadInfo{}, err
+			}
+		}
+		obj, err := backend.Get(ctx, src.Object, getOpts)
+		if nil != err {
+			return minio.UploadInfo{}, err
+		}
+		defer obj.Close()
+		if 0 == i {
+			if first, err = obj.Stat(); nil != err {
+				return minio.UploadInfo{}, err
+			}
+		}
+		readers = append(readers, obj)
+	}
+
+	opts := minio.PutObjectOptions{ContentType: first.ContentType, UserMetadata: first.UserMetadata, UserTags: first.UserTags}
+	if dst.ReplaceMetadata {
+		opts.UserMetadata = map[string]string{}
+		for k, v := range dst.UserMetadata {
+			if "Content-Type" == http.CanonicalHeaderKey(k) {
+				opts.ContentType = v
+				continue
+			}
+			opts.UserMetadata[k] = v
+		}
+	}
+	if dst.ReplaceTags {
+		opts.UserTags = dst.UserTags
+	}
+	return backend.Put(ctx, dst.Object, io.MultiReader(readers...), -1, opts)
+}
+
+// stat reads the metadata of key, the lock must be held
+func (backend *fileBackend) stat(key string) (minio.ObjectInfo, error) {
+	_, metaPath, err := backend.files(key)
+	if nil != err {
+		return minio.ObjectInfo{}, err
+	}
+	encoded, err := ioutil.ReadFile(metaPath)
+	if os.IsNotExist(err) {
+		return minio.ObjectInfo{}, noSuchKey(key)
+	}
+	if nil != err {
+		return minio.ObjectInfo{}, err
+	}
+	meta := fileMeta{}
+	if err := json.Unmarshal(encoded, &meta); nil != err {
+		return minio.ObjectInfo{}, errors.Wrap(err, fmt.Sprintf("Corrupt metadata for %v", key))
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Type", meta.ContentType)
+	for name, value := range map[string]string{
+		"Cache-Control":       meta.CacheControl,
+		"Content-Disposition": meta.ContentDisposition,
+		"Content-Language":    meta.ContentLanguage,
+		"Content-Encoding":    meta.ContentEncoding,
+	} {
+		if "" != value {
+			headers.Set(name, value)
+		}
+	}
+	for k, v := range meta.UserMetadata {
+		headers.Set("X-Amz-Meta-"+k, v)
+	}
+	return minio.ObjectInfo{
+		Key:          key,
+		ETag:         meta.ETag,
+		Size:         meta.Size,
+		LastModified: meta.LastModified,
+		ContentType:  meta.ContentType,
+		Metadata:     headers,
+		UserMetadata: meta.UserMetadata,
+		UserTags:     meta.UserTags,
+		UserTagCount: len(meta.UserTags),
+		StorageClass: meta.StorageClass,
+	}, nil
+}
+
+// keys returns every stored key in sorted order
+func (backend *fileBackend) keys() ([]string, error) {
+	root := filepath.Join(backend.dir, "meta")
+	output := []string{}
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if nil != err {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(file, ".json") {
+			return nil
+		}
+		relative, err := filepath.Rel(root, file)
+		if nil != err {
+			return err
+		}
+		output = append(output, strings.TrimSuffix(filepath.ToSlash(relative), ".json"))
+		return nil
+	})
+	sort.Strings(output)
+	return output, err
+}
+
+// files returns the data and metadata files of key, rejecting keys that escape the backend directory
+func (backend *fileBackend) files(key string) (string, string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if "" == key || cleaned != strings.TrimSuffix(key, "/") || strings.HasSuffix(key, "/") {
+		return "", "", errors.New(fmt.Sprintf("Invalid key for file backend: %v", key))
+	}
+	return filepath.Join(backend.dir, "objects", filepath.FromSlash(cleaned)),
+		filepath.Join(backend.dir, "meta", filepath.FromSlash(cleaned)+".json"), nil
+}
+
+// fileObject is a BackendObject reading a section of a file
+type fileObject struct {
+	*io.SectionReader
+	file *os.File
+	info minio.ObjectInfo
+}
+
+// Stat describes the object
+func (obj *fileObject) Stat() (minio.ObjectInfo, error) {
+	return obj.info, nil
+}
+
+// Close closes the file
+func (obj *fileObject) Close() error {
+	return obj.file.Close()
+}
+
+// parseRange converts a bytes=start-end Range header into an offset and length within size
+func parseRange(header string, size int64) (int64, int64, error) {
+	if "" == header {
+		return 0, size, nil
+	}
+	spec := strings.TrimPrefix(header, "bytes=")
+	parts := strings.SplitN(spec, "-", 2)
+	if spec == header || 2 != len(parts) {
+		return 0, 0, errors.New(fmt.Sprintf("Invalid range %v", header))
+	}
+	if "" == parts[0] {
+		suffix, err := strconv.ParseInt(parts[1], 10, 64)
+		if nil != err {
+			return 0, 0, errors.Wrap(err, fmt.Sprintf("Invalid range %v", header))
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, suffix, nil
+	}
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if nil != err {
+		return 0, 0, errors.Wrap(err, fmt.Sprintf("Invalid range %v", header))
+	}
+	end := size - 1
+	if "" != parts[1] {
+		if end, err = strconv.ParseInt(parts[1], 10, 64); nil != err {
+			return 0, 0, errors.Wrap(err, fmt.Sprintf("Invalid range %v", header))
+		}
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if start > end || start >= size {
+		return 0, 0, minio.ErrorResponse{StatusCode: 416, Code: "InvalidRange", Message: fmt.Sprintf("Invalid range %v", header)}
+	}
+	return start, end - start + 1, nil
+}
@@ -0,0 +1,296 @@
+package minioproto
+
+import (
+	"bytes"
+	"context"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header         string
+		start, length  int64
+		invalid, unsat bool
+	}{
+		{header: "", start: 0, length: 10},
+		{header: "bytes=0-4", start: 0, length: 5},
+		{header: "bytes=2-", start: 2, length: 8},
+		{header: "bytes=5-100", start: 5, length: 5},
+		{header: "bytes=-3", start: 7, length: 3},
+		{header: "bytes=-100", start: 0, length: 10},
+		{header: "bytes=9-9", start: 9, length: 1},
+		{header: "bytes=10-", unsat: true},
+		{header: "bytes=5-4", unsat: true},
+		{header: "0-4", invalid: true},
+		{header: "bytes=4", invalid: true},
+		{header: "bytes=a-4", invalid: true},
+		{header: "bytes=0-b", invalid: true},
+		{header: "bytes=-c", invalid: true},
+	}
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			start, length, err := parseRange(test.header, 10)
+			switch {
+			case test.unsat:
+				if 416 != minio.ToErrorResponse(err).StatusCode {
+					t.Fatalf("expected an unsatisfiable range, got %v", err)
+				}
+			case test.invalid:
+				if nil == err {
+					t.Fatalf("expected %q to be rejected", test.header)
+				}
+			case nil != err:
+				t.Fatalf("parseRange failed: %v", err)
+			case start != test.start || length != test.length:
+				t.Fatalf("expected %v+%v, got %v+%v", test.start, test.length, start, length)
+			}
+		})
+	}
+}
+
+// newTestFileBackend returns a file backend in a temp directory removed when the test ends
+func newTestFileBackend(t *testing.T) (*fileBackend, string) {
+	dir, err := ioutil.TempDir("", "minioproto-backend-")
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return NewFileBackend(dir).(*fileBackend), dir
+}
+
+// newTestCache returns a Cache storing objects in a file backend in a temp directory, stopped when the test ends
+func newTestCache(t *testing.T, opts ...Option) (*Cache, *fileBackend) {
+	backend, _ := newTestFileBackend(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	cache, err := NewWithBackend(ctx, zap.NewNop(), backend, opts...)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return cache, backend
+}
+
+// putString stores value at key, failing the test on error
+func putString(t *testing.T, backend Backend, key, value string, opts minio.PutObjectOptions) minio.UploadInfo {
+	info, err := backend.Put(context.Background(), key, bytes.NewReader([]byte(value)), int64(len(value)), opts)
+	if nil != err {
+		t.Fatalf("Put of %v failed: %v", key, err)
+	}
+	return info
+}
+
+// getString reads key with opts, failing the test on error
+func getString(t *testing.T, backend Backend, key string, opts minio.GetObjectOptions) string {
+	obj, err := backend.Get(context.Background(), key, opts)
+	if nil != err {
+		t.Fatalf("Get of %v failed: %v", key, err)
+	}
+	defer obj.Close()
+	data, err := ioutil.ReadAll(obj)
+	if nil != err {
+		t.Fatalf("Read of %v failed: %v", key, err)
+	}
+	return string(data)
+}
+
+func TestFileBackendPutGetStat(t *testing.T) {
+	backend, _ := newTestFileBackend(t)
+	ctx := context.Background()
+	opts := minio.PutObjectOptions{
+		ContentType:  "text/plain",
+		UserMetadata: map[string]string{"kind": "note"},
+		UserTags:     map[string]string{"team": "data"},
+		StorageClass: "REDUCED_REDUNDANCY",
+		CacheControl: "no-cache",
+	}
+	upload := putString(t, backend, "a/b.txt", "hello world", opts)
+	if "5eb63bbbe01eeed093cb22bb8f5acdc3" != upload.ETag || 11 != upload.Size {
+		t.Fatalf("unexpected upload %+v", upload)
+	}
+
+	info, err := backend.Stat(ctx, "a/b.txt", minio.StatObjectOptions{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if upload.ETag != info.ETag || "text/plain" != info.ContentType || "REDUCED_REDUNDANCY" != info.StorageClass ||
+		"note" != info.UserMetadata["Kind"] || "data" != info.UserTags["team"] || "no-cache" != info.Metadata.Get("Cache-Control") {
+		t.Fatalf("unexpected stat %+v", info)
+	}
+
+	ranged := minio.GetObjectOptions{}
+	ranged.SetRange(6, 10)
+	if output := getString(t, backend, "a/b.txt", ranged); "world" != output {
+		t.Fatalf("expected world, got %q", output)
+	}
+	if output := getString(t, backend, "a/b.txt", minio.GetObjectOptions{}); "hello world" != output {
+		t.Fatalf("expected hello world, got %q", output)
+	}
+
+	matching := minio.GetObjectOptions{}
+	matching.SetMatchETag("other")
+	if _, err := backend.Get(ctx, "a/b.txt", matching); 412 != minio.ToErrorResponse(err).StatusCode {
+		t.Fatalf("expected a failed precondition, got %v", err)
+	}
+	if _, err := backend.Get(ctx, "a/b.txt", minio.GetObjectOptions{VersionID: "v1"}); ErrUnsupportedBackend != errors.Cause(err) {
+		t.Fatalf("expected ErrUnsupportedBackend, got %v", err)
+	}
+	if _, err := backend.Stat(ctx, "missing", minio.StatObjectOptions{}); "NoSuchKey" != minio.ToErrorResponse(err).Code {
+		t.Fatalf("expected NoSuchKey, got %v", err)
+	}
+}
+
+func TestFileBackendInvalidKeys(t *testing.T) {
+	backend, _ := newTestFileBackend(t)
+	for _, key := range []string{"", "../escape", "a/../b", "a//b", "dir/"} {
+		t.Run(key, func(t *testing.T) {
+			if _, err := backend.Put(context.Background(), key, bytes.NewReader(nil), 0, minio.PutObjectOptions{}); nil == err {
+				t.Fatalf("expected %q to be rejected", key)
+			}
+		})
+	}
+}
+
+func TestFileBackendList(t *testing.T) {
+	backend, _ := newTestFileBackend(t)
+	for _, key := range []string{"logs/2024/a", "logs/2024/b", "logs/2025/c", "logs/top", "other/d"} {
+		putString(t, backend, key, key, minio.PutObjectOptions{})
+	}
+
+	tests := []struct {
+		name     string
+		opts     minio.ListObjectsOptions
+		expected []string
+	}{
+		{"everything", minio.ListObjectsOptions{Recursive: true}, []string{"logs/2024/a", "logs/2024/b", "logs/2025/c", "logs/top", "other/d"}},
+		{"recursive prefix", minio.ListObjectsOptions{Prefix: "logs/2024/", Recursive: true}, []string{"logs/2024/a", "logs/2024/b"}},
+		{"common prefixes", minio.ListObjectsOptions{Prefix: "logs/"}, []string{"logs/2024/", "logs/2025/", "logs/top"}},
+		{"top level", minio.ListObjectsOptions{}, []string{"logs/", "other/"}},
+		{"no match", minio.ListObjectsOptions{Prefix: "none/", Recursive: true}, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := []string{}
+			for info := range backend.List(context.Background(), test.opts) {
+				if nil != info.Err {
+					t.Fatal(info.Err)
+				}
+				keys = append(keys, info.Key)
+			}
+			if !reflect.DeepEqual(keys, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, keys)
+			}
+		})
+	}
+
+	for info := range backend.List(context.Background(), minio.ListObjectsOptions{WithVersions: true}) {
+		if ErrUnsupportedBackend != errors.Cause(info.Err) {
+			t.Fatalf("expected ErrUnsupportedBackend, got %+v", info)
+		}
+	}
+}
+
+func TestFileBackendDelete(t *testing.T) {
+	backend, dir := newTestFileBackend(t)
+	ctx := context.Background()
+	putString(t, backend, "a/b/c", "c", minio.PutObjectOptions{})
+	putString(t, backend, "a/d", "d", minio.PutObjectOptions{})
+
+	if err := backend.Delete(ctx, "a/b/c", minio.RemoveObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if err := backend.Delete(ctx, "a/b/c", minio.RemoveObjectOptions{}); nil != err {
+		t.Fatalf("deleting a missing key failed: %v", err)
+	}
+	if _, err := backend.Stat(ctx, "a/b/c", minio.StatObjectOptions{}); "NoSuchKey" != minio.ToErrorResponse(err).Code {
+		t.Fatalf("expected NoSuchKey, got %v", err)
+	}
+	for _, root := range []string{"objects", "meta"} {
+		if _, err := os.Stat(filepath.Join(dir, root, "a", "b")); !os.IsNotExist(err) {
+			t.Fatalf("expected the empty %v directory to be pruned, got %v", root, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, root, "a")); nil != err {
+			t.Fatalf("expected the %v directory holding a/d to remain: %v", root, err)
+		}
+	}
+
+	// Pruned directories are recreated by later writes
+	putString(t, backend, "a/b/e", "e", minio.PutObjectOptions{})
+	if output := getString(t, backend, "a/b/e", minio.GetObjectOptions{}); "e" != output {
+		t.Fatalf("expected e, got %q", output)
+	}
+}
+
+func TestFileBackendPutIf(t *testing.T) {
+	backend, _ := newTestFileBackend(t)
+	ctx := context.Background()
+	put := func(value string, cond PutCondition) (minio.UploadInfo, error) {
+		return backend.PutIf(ctx, "lease", bytes.NewReader([]byte(value)), int64(len(value)), minio.PutObjectOptions{}, cond)
+	}
+
+	if _, err := put("a", PutCondition{IfMatch: "missing"}); ErrPreconditionFailed != errors.Cause(err) {
+		t.Fatalf("expected If-Match on a missing key to fail, got %v", err)
+	}
+	first, err := put("a", PutCondition{IfNoneMatch: true})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := put("b", PutCondition{IfNoneMatch: true}); ErrPreconditionFailed != errors.Cause(err) {
+		t.Fatalf("expected If-None-Match on an existing key to fail, got %v", err)
+	}
+	if _, err := put("b", PutCondition{IfMatch: "stale"}); ErrPreconditionFailed != errors.Cause(err) {
+		t.Fatalf("expected If-Match with a stale ETag to fail, got %v", err)
+	}
+	if _, err := put("b", PutCondition{IfMatch: "\"" + first.ETag + "\""}); nil != err {
+		t.Fatalf("expected If-Match with the current ETag to succeed, got %v", err)
+	}
+	if output := getString(t, backend, "lease", minio.GetObjectOptions{}); "b" != output {
+		t.Fatalf("expected b, got %q", output)
+	}
+}
+
+func TestFileBackendCopy(t *testing.T) {
+	backend, _ := newTestFileBackend(t)
+	ctx := context.Background()
+	putString(t, backend, "part1", "hello ", minio.PutObjectOptions{ContentType: "text/plain", UserMetadata: map[string]string{"kind": "a"}})
+	putString(t, backend, "part2", "world", minio.PutObjectOptions{})
+
+	dst := minio.CopyDestOptions{Object: "joined"}
+	if _, err := backend.Copy(ctx, dst, minio.CopySrcOptions{Object: "part1"}, minio.CopySrcOptions{Object: "part2"}); nil != err {
+		t.Fatal(err)
+	}
+	if output := getString(t, backend, "joined", minio.GetObjectOptions{}); "hello world" != output {
+		t.Fatalf("expected hello world, got %q", output)
+	}
+	info, err := backend.Stat(ctx, "joined", minio.StatObjectOptions{})
+	if nil != err || "text/plain" != info.ContentType || "a" != info.UserMetadata["Kind"] {
+		t.Fatalf("expected the metadata of the first source, got %+v %v", info, err)
+	}
+
+	dst = minio.CopyDestOptions{
+		Object:          "replaced",
+		ReplaceMetadata: true,
+		UserMetadata:    map[string]string{"Content-Type": "application/json", "kind": "b"},
+	}
+	if _, err := backend.Copy(ctx, dst, minio.CopySrcOptions{Object: "part2"}); nil != err {
+		t.Fatal(err)
+	}
+	info, err = backend.Stat(ctx, "replaced", minio.StatObjectOptions{})
+	if nil != err || "application/json" != info.ContentType || "b" != info.UserMetadata["Kind"] {
+		t.Fatalf("expected the replaced metadata, got %+v %v", info, err)
+	}
+
+	ranged := minio.CopySrcOptions{Object: "part1", MatchRange: true, Start: 0, End: 3}
+	if _, err := backend.Copy(ctx, minio.CopyDestOptions{Object: "range"}, ranged); nil != err {
+		t.Fatal(err)
+	}
+	if output := getString(t, backend, "range", minio.GetObjectOptions{}); "hell" != output {
+		t.Fatalf("expected hell, got %q", output)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/gnagel/minio-proto
 go 1.15
 
 require (
+	github.com/360EntSecGroup-Skylar/excelize/v2 v2.3.2
 	github.com/minio/minio-go/v7 v7.0.5
 	github.com/pkg/errors v0.9.1
 	go.uber.org/zap v1.16.0
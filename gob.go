@@ -0,0 +1,61 @@
+package minioproto
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+)
+
+// GOBExists checks if Gob file exists in minio
+func (cache *Cache) GOBExists(path string, opts minio.StatObjectOptions) (*minio.ObjectInfo, error) {
+	path = pathFix(path, gobContentType)
+	return cache.DataExists(path, opts)
+}
+
+// GetGob reads a Gob encoded Go value from minio into output, a pointer.
+// Gob has no schema evolution beyond field matching by name, so use it only for internal state.
+func (cache *Cache) GetGob(path string, output interface{}, opts minio.GetObjectOptions) error {
+	path = pathFix(path, gobContentType)
+	cache.logger.Info(fmt.Sprintf("Reading Gob file, path=%v", path))
+	reader, data, err := cache.openData(path, opts)
+	if nil != err {
+		err = errors.Wrap(err, "Failed to fetch Gob file")
+		cache.logger.Error(err.Error())
+		return err
+	}
+	defer reader.Close()
+
+	if nil != data {
+		if empty, err := cache.checkEmpty(path, data); empty {
+			return err
+		}
+	}
+
+	// Deserialize from Gob
+	if err = gob.NewDecoder(reader).Decode(output); nil != err {
+		err = errors.Wrap(err, "Failed deserialize data from gob")
+		cache.logger.Error(err.Error())
+		return err
+	}
+
+	cache.logger.Info(fmt.Sprintf("Success reading path=%v", path))
+	return nil
+}
+
+// PutGob writes a Go value to minio with encoding/gob, interface fields must have their types registered with gob.Register
+func (cache *Cache) PutGob(path string, data interface{}, opts minio.PutObjectOptions) (*WriteResult, error) {
+	// Serialize to Gob
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(data); nil != err {
+		err = errors.Wrap(err, "Failed serialize data as gob")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	// Write the data
+	opts.ContentType = gobContentType
+	path = pathFix(path, opts.ContentType)
+	return cache.WriteData(path, buf.Bytes(), opts)
+}
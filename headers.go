@@ -0,0 +1,94 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// ObjectHeaders are the HTTP headers MinIO serves with an object, used by browsers and CDNs
+type ObjectHeaders struct {
+	CacheControl       string
+	ContentDisposition string
+	ContentLanguage    string
+	ContentEncoding    string
+}
+
+// Apply returns opts with the non-empty headers set, headers already set on opts are kept
+func (headers ObjectHeaders) Apply(opts minio.PutObjectOptions) minio.PutObjectOptions {
+	if "" == opts.CacheControl {
+		opts.CacheControl = headers.CacheControl
+	}
+	if "" == opts.ContentDisposition {
+		opts.ContentDisposition = headers.ContentDisposition
+	}
+	if "" == opts.ContentLanguage {
+		opts.ContentLanguage = headers.ContentLanguage
+	}
+	if "" == opts.ContentEncoding {
+		opts.ContentEncoding = headers.ContentEncoding
+	}
+	return opts
+}
+
+// HeadersOf returns the headers of a stat result, e.g. from DataExists
+func HeadersOf(info *minio.ObjectInfo) ObjectHeaders {
+	if nil == info {
+		return ObjectHeaders{}
+	}
+	return ObjectHeaders{
+		CacheControl:       info.Metadata.Get("Cache-Control"),
+		ContentDisposition: info.Metadata.Get("Content-Disposition"),
+		ContentLanguage:    info.Metadata.Get("Content-Language"),
+		ContentEncoding:    info.Metadata.Get("Content-Encoding"),
+	}
+}
+
+// headerRule holds the default headers for writes under a prefix
+type headerRule struct {
+	prefix  string
+	headers ObjectHeaders
+}
+
+// WithHeaders sets default headers for writes under prefix, the longest matching prefix wins
+// and headers set on the PutObjectOptions of a write take precedence
+func WithHeaders(prefix string, headers ObjectHeaders) Option {
+	return func(cache *Cache) {
+		cache.headerRules = append(cache.headerRules, headerRule{prefix: prefix, headers: headers})
+	}
+}
+
+// applyHeaders fills opts with the default headers configured for path
+func (cache *Cache) applyHeaders(path string, opts minio.PutObjectOptions) minio.PutObjectOptions {
+	var match *headerRule
+	for i, rule := range cache.headerRules {
+		if strings.HasPrefix(path, rule.prefix) && (nil == match || len(rule.prefix) > len(match.prefix)) {
+			match = &cache.headerRules[i]
+		}
+	}
+	if nil == match {
+		return opts
+	}
+	return match.headers.Apply(opts)
+}
+
+// StatHeaders returns the headers served with the object at path
+func (cache *Cache) StatHeaders(path string, opts minio.StatObjectOptions) (*ObjectHeaders, error) {
+	path = cache.objectKey(path)
+	if err := cache.authorize(AuthStat, path, nil); nil != err {
+		return nil, err
+	}
+	opts, err := cache.resolveVersion(path, opts)
+	if nil != err {
+		return nil, err
+	}
+	info, err := cache.backend.Stat(cache.ctx, path, opts)
+	if nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to stat %v", path))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	headers := HeadersOf(&info)
+	return &headers, nil
+}
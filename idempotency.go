@@ -0,0 +1,138 @@
+package minioproto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// ErrIdempotencyConflict is returned when an idempotency key is reused for a different path or payload
+var ErrIdempotencyConflict = errors.New("Idempotency key was already used for a different write")
+
+const idempotencyKeyMetadata = "Idempotency-Key"
+const payloadHashMetadata = "Payload-Sha256"
+const defaultIdempotencyPrefix = ".idempotency"
+
+// idempotencyRecord is stored in the ledger for every idempotent write
+type idempotencyRecord struct {
+	Key           string      `json:"key"`
+	Path          string      `json:"path"`
+	PayloadSHA256 string      `json:"payloadSha256"`
+	Result        WriteResult `json:"result"`
+}
+
+// WithIdempotencyLedger sets the prefix where idempotency keys are recorded, defaults to ".idempotency"
+func WithIdempotencyLedger(prefix string) Option {
+	return func(cache *Cache) {
+		cache.idempotencyPrefix = prefix
+	}
+}
+
+// IdempotentPut tags the put options with an idempotency key. Repeating a write with the same key
+// and payload returns the original WriteResult without writing again, while reusing the key for a
+// different path or payload fails with ErrIdempotencyConflict. The ledger is written with If-None-Match,
+// so the backend must implement ConditionalBackend and only the first write of a key is recorded.
+func IdempotentPut(opts minio.PutObjectOptions, key string) minio.PutObjectOptions {
+	return withUserMetadata(opts, idempotencyKeyMetadata, key)
+}
+
+// withUserMetadata returns the options with an extra user metadata entry, without modifying the caller's map
+func withUserMetadata(opts minio.PutObjectOptions, key, value string) minio.PutObjectOptions {
+	metadata := map[string]string{}
+	for k, v := range opts.UserMetadata {
+		metadata[k] = v
+	}
+	metadata[key] = value
+	opts.UserMetadata = metadata
+	return opts
+}
+
+// idempotencyKey returns the idempotency key set by IdempotentPut, if any
+func idempotencyKey(opts minio.PutObjectOptions) string {
+	for k, v := range opts.UserMetadata {
+		if http.CanonicalHeaderKey(k) == idempotencyKeyMetadata {
+			return v
+		}
+	}
+	return ""
+}
+
+// checkIdempotency returns the recorded result when the write was already performed with the same key
+func (cache *Cache) checkIdempotency(path, key string, data []byte) (*WriteResult, string, error) {
+	sum := sha256.Sum256(data)
+	payloadHash := hex.EncodeToString(sum[:])
+
+	record, err := cache.readIdempotency(key)
+	if nil != err || nil == record {
+		return nil, payloadHash, err
+	}
+	if err := cache.matchIdempotency(path, key, payloadHash, record); nil != err {
+		return nil, payloadHash, err
+	}
+
+	cache.logger.Info(fmt.Sprintf("Skipping repeated write of path=%v with idempotency key %v", path, key))
+	return &record.Result, payloadHash, nil
+}
+
+// recordIdempotency stores the result of an idempotent write in the ledger unless a concurrent write
+// recorded the key first, which fails with ErrIdempotencyConflict when it was for a different write
+func (cache *Cache) recordIdempotency(path, key, payloadHash string, result *WriteResult) error {
+	record := &idempotencyRecord{
+		Key:           key,
+		Path:          path,
+		PayloadSHA256: payloadHash,
+		Result:        *result,
+	}
+	payload, err := json.Marshal(record)
+	if nil != err {
+		return err
+	}
+	recorded, err := cache.putRecord(cache.idempotencyPath(key), payload, PutCondition{IfNoneMatch: true})
+	if nil != err || recorded {
+		return err
+	}
+
+	if record, err = cache.readIdempotency(key); nil != err || nil == record {
+		return err
+	}
+	return cache.matchIdempotency(path, key, payloadHash, record)
+}
+
+// readIdempotency reads the ledger record of key, nil when the key wasn't used yet
+func (cache *Cache) readIdempotency(key string) (*idempotencyRecord, error) {
+	ledgerPath := cache.idempotencyPath(key)
+	data, _, err := cache.getRecord(ledgerPath)
+	if nil != err || nil == data {
+		return nil, err
+	}
+	record := &idempotencyRecord{}
+	if err := json.Unmarshal(data, record); nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed deserialize idempotency record %v", ledgerPath))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	return record, nil
+}
+
+// matchIdempotency fails with ErrIdempotencyConflict when record is for a different path or payload
+func (cache *Cache) matchIdempotency(path, key, payloadHash string, record *idempotencyRecord) error {
+	if record.Path != path || record.PayloadSHA256 != payloadHash {
+		err := errors.Wrap(ErrIdempotencyConflict, fmt.Sprintf("Key %v was used for path=%v", key, record.Path))
+		cache.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+func (cache *Cache) idempotencyPath(key string) string {
+	prefix := cache.idempotencyPrefix
+	if "" == prefix {
+		prefix = defaultIdempotencyPrefix
+	}
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%v/%v.json", prefix, hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,53 @@
+package minioproto
+
+import (
+	"context"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"reflect"
+	"testing"
+)
+
+func TestIdempotentPut(t *testing.T) {
+	cache, backend := newTestCache(t)
+	opts := IdempotentPut(minio.PutObjectOptions{}, "request-1")
+	first, err := cache.WriteData("key", []byte("hello"), opts)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// A repeated write returns the recorded result without writing again
+	putString(t, backend, "key", "changed", minio.PutObjectOptions{})
+	repeated, err := cache.WriteData("key", []byte("hello"), opts)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, repeated) {
+		t.Fatalf("expected the recorded result %+v, got %+v", first, repeated)
+	}
+	if value := getString(t, backend, "key", minio.GetObjectOptions{}); "changed" != value {
+		t.Fatalf("expected the repeated write to be skipped, got %v", value)
+	}
+
+	if _, err := cache.WriteData("key", []byte("other"), opts); ErrIdempotencyConflict != errors.Cause(err) {
+		t.Fatalf("expected ErrIdempotencyConflict for another payload, got %v", err)
+	}
+	if _, err := cache.WriteData("other", []byte("hello"), opts); ErrIdempotencyConflict != errors.Cause(err) {
+		t.Fatalf("expected ErrIdempotencyConflict for another path, got %v", err)
+	}
+
+	objects, err := cache.List(defaultIdempotencyPrefix+"/", minio.ListObjectsOptions{Recursive: true})
+	if nil != err || 1 != len(objects) {
+		t.Fatalf("expected a single ledger record, got %v %v", objects, err)
+	}
+}
+
+func TestIdempotencyLedger(t *testing.T) {
+	cache, backend := newTestCache(t, WithIdempotencyLedger("ledger"))
+	if _, err := cache.WriteData("key", []byte("hello"), IdempotentPut(minio.PutObjectOptions{}, "request-1")); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := backend.Stat(context.Background(), cache.idempotencyPath("request-1"), minio.StatObjectOptions{}); nil != err {
+		t.Fatalf("expected the record under the ledger prefix: %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package minioproto
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"path/filepath"
+)
+
+// ImageFormat is the encoding used by PutImage
+type ImageFormat string
+
+const (
+	// ImagePNG encodes images losslessly as PNG
+	ImagePNG ImageFormat = "png"
+	// ImageJPEG encodes images as JPEG with the default quality
+	ImageJPEG ImageFormat = "jpeg"
+	// ImageGIF encodes images as GIF with the Plan9 palette
+	ImageGIF ImageFormat = "gif"
+)
+
+// thumbnailPrefix is where thumbnails generated by PutImage are stored
+const thumbnailPrefix = "thumbs"
+
+// WithThumbnails makes PutImage also write scaled copies fitting within each size in pixels, see ThumbnailPath
+func WithThumbnails(sizes ...int) Option {
+	return func(cache *Cache) {
+		cache.thumbnailSizes = append(cache.thumbnailSizes, sizes...)
+	}
+}
+
+// ThumbnailPath returns the key of the thumbnail of path generated for size
+func ThumbnailPath(path string, size int) string {
+	return fmt.Sprintf("%v/%v/%v", thumbnailPrefix, size, path)
+}
+
+// contentType returns the content type of the image format
+func (format ImageFormat) contentType() (string, error) {
+	switch format {
+	case ImagePNG:
+		return pngContentType, nil
+	case ImageJPEG:
+		return jpegContentType, nil
+	case ImageGIF:
+		return gifContentType, nil
+	}
+	return "", errors.New(fmt.Sprintf("Unsupported image format %v", format))
+}
+
+// encode serializes img in the image format
+func (format ImageFormat) encode(img image.Image) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var err error
+	switch format {
+	case ImagePNG:
+		err = png.Encode(buf, img)
+	case ImageJPEG:
+		err = jpeg.Encode(buf, img, nil)
+	case ImageGIF:
+		err = gif.Encode(buf, img, nil)
+	default:
+		err = errors.New(fmt.Sprintf("Unsupported image format %v", format))
+	}
+	return buf.Bytes(), err
+}
+
+// GetImage reads and decodes a PNG, JPEG or GIF image from minio, returning the detected format
+func (cache *Cache) GetImage(path string, opts minio.GetObjectOptions) (image.Image, ImageFormat, error) {
+	cache.logger.Info(fmt.Sprintf("Reading image file, path=%v", path))
+	data, err := cache.ReadData(path, opts)
+	if nil != err {
+		err = errors.Wrap(err, "Failed to fetch image")
+		cache.logger.Error(err.Error())
+		return nil, "", err
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if nil != err {
+		err = errors.Wrap(err, "Failed deserialize image")
+		cache.logger.Error(err.Error())
+		return nil, "", err
+	}
+
+	cache.logger.Info(fmt.Sprintf("Success reading path=%v", path))
+	return img, ImageFormat(format), nil
+}
+
+// PutImage encodes and writes an image to minio, followed by any thumbnails configured with WithThumbnails
+func (cache *Cache) PutImage(path string, img image.Image, format ImageFormat, opts minio.PutObjectOptions) (*WriteResult, error) {
+	contentType, err := format.contentType()
+	if nil != err {
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	payload, err := format.encode(img)
+	if nil != err {
+		err = errors.Wrap(err, "Failed serialize image")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	// Write the data, keeping .jpeg when that is the extension already used
+	opts.ContentType = contentType
+	if !(ImageJPEG == format && ".jpeg" == filepath.Ext(path)) {
+		path = pathFix(path, opts.ContentType)
+	}
+	result, err := cache.WriteData(path, payload, opts)
+	if nil != err {
+		return nil, err
+	}
+
+	for _, size := range cache.thumbnailSizes {
+		thumbnail, err := format.encode(scaleImage(img, size))
+		if nil != err {
+			err = errors.Wrap(err, fmt.Sprintf("Failed serialize thumbnail of size %v", size))
+			cache.logger.Error(err.Error())
+			return result, err
+		}
+		if _, err = cache.WriteData(ThumbnailPath(path, size), thumbnail, opts); nil != err {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// scaleImage downscales img by area averaging to fit within size x size, smaller images are returned as is
+func scaleImage(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if size <= 0 || (width <= size && height <= size) {
+		return img
+	}
+
+	scaledWidth, scaledHeight := size, size
+	if width > height {
+		scaledHeight = maxInt(1, height*size/width)
+	} else {
+		scaledWidth = maxInt(1, width*size/height)
+	}
+
+	output := image.NewRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
+	for y := 0; y < scaledHeight; y++ {
+		y0, y1 := bounds.Min.Y+y*height/scaledHeight, bounds.Min.Y+maxInt((y+1)*height/scaledHeight, y*height/scaledHeight+1)
+		for x := 0; x < scaledWidth; x++ {
+			x0, x1 := bounds.Min.X+x*width/scaledWidth, bounds.Min.X+maxInt((x+1)*width/scaledWidth, x*width/scaledWidth+1)
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					count++
+				}
+			}
+			output.Set(x, y, color.RGBA64{
+				R: uint16(r / count),
+				G: uint16(g / count),
+				B: uint16(b / count),
+				A: uint16(a / count),
+			})
+		}
+	}
+	return output
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
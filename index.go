@@ -0,0 +1,326 @@
+package minioproto
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// indexRecordDir holds the reverse record of every indexed key, the dot can't clash with an escaped attribute
+const indexRecordDir = ".keys"
+
+// indexLocks is the number of lock stripes serializing index updates of the same key within a process
+const indexLocks = 64
+
+// secondaryIndex is the configuration and per key write locks of the index maintained by a Cache
+type secondaryIndex struct {
+	prefix     string
+	attributes []string
+	locks      [indexLocks]sync.Mutex
+}
+
+// WithIndex maintains a secondary index of the given attributes, read from the user metadata
+// or tags of each write. Every indexed value is an empty marker object <indexPrefix>/<attribute>/<value>/<key>,
+// and <indexPrefix>/.keys/<key>.json records the values of each key, so a write only touches the markers
+// that changed. Failures are only logged, so call RebuildIndex periodically to repair lost updates.
+func WithIndex(indexPrefix string, attributes ...string) Option {
+	return func(cache *Cache) {
+		cache.index = &secondaryIndex{prefix: indexPrefix, attributes: attributes}
+	}
+}
+
+// Query returns the keys whose attributes match every filter, e.g. {"customer": "x", "kind": "model"}
+func (cache *Cache) Query(filters map[string]string) ([]string, error) {
+	if nil == cache.index {
+		err := errors.New("Query requires a Cache created WithIndex")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	var output []string
+	first := true
+	for attribute, value := range filters {
+		keys, err := cache.indexedKeys(attribute, value)
+		if nil != err {
+			return nil, err
+		}
+		if first {
+			output = keys
+			first = false
+		} else {
+			output = intersectSorted(output, keys)
+		}
+		if 0 == len(output) {
+			break
+		}
+	}
+
+	cache.logger.Info(fmt.Sprintf("Query matched %v keys", len(output)))
+	return append([]string{}, output...), nil
+}
+
+// RebuildIndex recreates the index entries of every object under prefix from its metadata and tags,
+// removing the entries of objects under prefix that no longer exist or changed their values
+func (cache *Cache) RebuildIndex(prefix string) error {
+	if nil == cache.index {
+		err := errors.New("RebuildIndex requires a Cache created WithIndex")
+		cache.logger.Error(err.Error())
+		return err
+	}
+
+	objects, err := cache.List(prefix, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return err
+	}
+	indexed := map[string]map[string]string{}
+	for _, object := range objects {
+		key := cache.objectKey(object.Key)
+		if !cache.indexes(key) {
+			continue
+		}
+		if err := cache.authorize(AuthStat, key, nil); nil != err {
+			return err
+		}
+		info, err := cache.backend.Stat(cache.ctx, key, minio.StatObjectOptions{})
+		if nil != err {
+			return errors.Wrap(err, fmt.Sprintf("Failed to stat %v", object.Key))
+		}
+		tags, err := cache.objectTags(key, info)
+		if nil != err {
+			return err
+		}
+		values := cache.indexAttributes(info.UserMetadata, tags)
+		indexed[object.Key] = values
+		if err := cache.setIndexValues(object.Key, values, true); nil != err {
+			return err
+		}
+	}
+
+	// Sweep the markers and records of keys under prefix that weren't written above
+	for _, attribute := range cache.index.attributes {
+		dir := fmt.Sprintf("%v/%v/", cache.index.prefix, indexSegment(attribute))
+		markers, err := cache.List(dir, minio.ListObjectsOptions{Recursive: true})
+		if nil != err {
+			return err
+		}
+		for _, marker := range markers {
+			value, path, err := splitIndexMarker(strings.TrimPrefix(marker.Key, dir))
+			if nil != err || !strings.HasPrefix(path, prefix) {
+				continue
+			}
+			if current, ok := indexed[path][attribute]; ok && current == value {
+				continue
+			}
+			if err := cache.DeleteData(marker.Key, minio.RemoveObjectOptions{}); nil != err {
+				return err
+			}
+		}
+	}
+	dir := fmt.Sprintf("%v/%v/", cache.index.prefix, indexRecordDir)
+	records, err := cache.List(dir, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return err
+	}
+	for _, record := range records {
+		path, err := url.PathUnescape(strings.TrimSuffix(strings.TrimPrefix(record.Key, dir), ".json"))
+		if _, ok := indexed[path]; nil != err || ok || !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if err := cache.DeleteData(record.Key, minio.RemoveObjectOptions{}); nil != err {
+			return err
+		}
+	}
+	cache.logger.Info(fmt.Sprintf("Rebuilt index from %v objects under prefix=%v", len(indexed), prefix))
+	return nil
+}
+
+// indexes reports whether writes and deletes of the object key maintain the index,
+// the index prefix is relative to the tenant of the view like the keys it holds
+func (cache *Cache) indexes(key string) bool {
+	return nil != cache.index && !strings.HasPrefix(cache.relativeKey(key), cache.index.prefix+"/")
+}
+
+// updateIndex records the indexed attributes of a written object, removing it from any previous values
+func (cache *Cache) updateIndex(key string, opts minio.PutObjectOptions) error {
+	values := cache.indexAttributes(opts.UserMetadata, opts.UserTags)
+	return cache.setIndexValues(cache.relativeKey(key), values, false)
+}
+
+// removeFromIndex drops a deleted object from every attribute
+func (cache *Cache) removeFromIndex(key string) error {
+	return cache.setIndexValues(cache.relativeKey(key), map[string]string{}, false)
+}
+
+// setIndexValues moves path to the given attribute values. Unless force is set only the markers that
+// differ from the recorded values are written, and new markers are written before stale ones are
+// deleted so queries never miss a key while it is updated.
+func (cache *Cache) setIndexValues(path string, values map[string]string, force bool) error {
+	lock := cache.index.lockFor(path)
+	lock.Lock()
+	defer lock.Unlock()
+
+	previous, err := cache.readIndexRecord(path)
+	if nil != err {
+		return err
+	}
+	changed := force
+	for _, attribute := range cache.index.attributes {
+		value, ok := values[attribute]
+		if old, had := previous[attribute]; !ok || (!force && had && old == value) {
+			changed = changed || had != ok
+			continue
+		}
+		changed = true
+		if _, err := cache.WriteData(cache.indexMarkerPath(attribute, value, path), []byte{}, minio.PutObjectOptions{}); nil != err {
+			return err
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	record := cache.indexRecordPath(path)
+	if 0 == len(values) {
+		err = cache.DeleteData(record, minio.RemoveObjectOptions{})
+	} else {
+		var payload []byte
+		if payload, err = json.Marshal(values); nil == err {
+			_, err = cache.WriteData(record, payload, minio.PutObjectOptions{ContentType: jsonContentType})
+		}
+	}
+	if nil != err {
+		return err
+	}
+
+	for attribute, old := range previous {
+		if value, ok := values[attribute]; ok && value == old {
+			continue
+		}
+		if err := cache.DeleteData(cache.indexMarkerPath(attribute, old, path), minio.RemoveObjectOptions{}); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexAttributes picks the indexed attributes from the metadata (matched case-insensitively) and tags
+func (cache *Cache) indexAttributes(metadata, tags map[string]string) map[string]string {
+	output := map[string]string{}
+	for _, attribute := range cache.index.attributes {
+		for k, v := range metadata {
+			if http.CanonicalHeaderKey(k) == http.CanonicalHeaderKey(attribute) {
+				output[attribute] = v
+			}
+		}
+		if v, ok := tags[attribute]; ok {
+			output[attribute] = v
+		}
+	}
+	return output
+}
+
+// indexedKeys lists the sorted keys holding value for attribute
+func (cache *Cache) indexedKeys(attribute, value string) ([]string, error) {
+	dir := fmt.Sprintf("%v/%v/%v/", cache.index.prefix, indexSegment(attribute), indexSegment(value))
+	markers, err := cache.List(dir, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return nil, err
+	}
+	output := make([]string, 0, len(markers))
+	for _, marker := range markers {
+		path, err := url.PathUnescape(strings.TrimPrefix(marker.Key, dir))
+		if nil != err {
+			err = errors.Wrap(err, fmt.Sprintf("Failed to decode index marker %v", marker.Key))
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
+		output = append(output, path)
+	}
+	sort.Strings(output)
+	return output, nil
+}
+
+// readIndexRecord reads the recorded values of path. A missing record means the key isn't indexed yet,
+// any other error is returned so a transient failure can't drop the key from the index.
+func (cache *Cache) readIndexRecord(path string) (map[string]string, error) {
+	record := cache.indexRecordPath(path)
+	data, _, err := cache.readData(record, minio.GetObjectOptions{}, false)
+	if nil != err {
+		if "NoSuchKey" == minio.ToErrorResponse(errors.Cause(err)).Code {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	values := map[string]string{}
+	if err := json.Unmarshal(data, &values); nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed deserialize index record %v", record))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	return values, nil
+}
+
+func (cache *Cache) indexMarkerPath(attribute, value, path string) string {
+	return fmt.Sprintf("%v/%v/%v/%v", cache.index.prefix, indexSegment(attribute), indexSegment(value), indexSegment(path))
+}
+
+func (cache *Cache) indexRecordPath(path string) string {
+	return fmt.Sprintf("%v/%v/%v.json", cache.index.prefix, indexRecordDir, indexSegment(path))
+}
+
+// lockFor returns the lock stripe of path
+func (index *secondaryIndex) lockFor(path string) *sync.Mutex {
+	hash := fnv.New32a()
+	hash.Write([]byte(path))
+	return &index.locks[hash.Sum32()%indexLocks]
+}
+
+// indexSegment escapes a value into a single key segment. Dots are escaped so "." and ".." stay valid keys,
+// and the empty value is stored as a lone "%" which escaping never produces.
+func indexSegment(value string) string {
+	if "" == value {
+		return "%"
+	}
+	return strings.Replace(url.PathEscape(value), ".", "%2E", -1)
+}
+
+// splitIndexMarker decodes the value and key of a marker relative to its attribute directory
+func splitIndexMarker(marker string) (string, string, error) {
+	parts := strings.SplitN(marker, "/", 2)
+	if 2 != len(parts) {
+		return "", "", errors.New(fmt.Sprintf("Invalid index marker %v", marker))
+	}
+	value := ""
+	if "%" != parts[0] {
+		var err error
+		if value, err = url.PathUnescape(parts[0]); nil != err {
+			return "", "", err
+		}
+	}
+	path, err := url.PathUnescape(parts[1])
+	return value, path, err
+}
+
+func intersectSorted(a, b []string) []string {
+	output := []string{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			output = append(output, a[i])
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return output
+}
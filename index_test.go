@@ -0,0 +1,86 @@
+package minioproto
+
+import (
+	"github.com/minio/minio-go/v7"
+	"reflect"
+	"testing"
+)
+
+// query runs Query, failing the test on error
+func query(t *testing.T, cache *Cache, filters map[string]string) []string {
+	keys, err := cache.Query(filters)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestIndex(t *testing.T) {
+	cache, _ := newTestCache(t, WithIndex("idx", "customer", "kind"))
+	writes := map[string]minio.PutObjectOptions{
+		"data/a": {UserMetadata: map[string]string{"Customer": "x"}, UserTags: map[string]string{"kind": "model"}},
+		"data/b": {UserMetadata: map[string]string{"customer": "x"}, UserTags: map[string]string{"kind": "data"}},
+		"data/c": {UserMetadata: map[string]string{"customer": "y/z"}, UserTags: map[string]string{"kind": "model"}},
+	}
+	for key, opts := range writes {
+		if _, err := cache.WriteData(key, []byte(key), opts); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	if keys := query(t, cache, map[string]string{"customer": "x"}); !reflect.DeepEqual([]string{"data/a", "data/b"}, keys) {
+		t.Fatalf("expected data/a and data/b, got %v", keys)
+	}
+	if keys := query(t, cache, map[string]string{"customer": "x", "kind": "model"}); !reflect.DeepEqual([]string{"data/a"}, keys) {
+		t.Fatalf("expected data/a, got %v", keys)
+	}
+	if keys := query(t, cache, map[string]string{"customer": "y/z"}); !reflect.DeepEqual([]string{"data/c"}, keys) {
+		t.Fatalf("expected data/c, got %v", keys)
+	}
+
+	// Rewrites move keys between values and deletes remove them
+	if _, err := cache.WriteData("data/a", []byte("a"), minio.PutObjectOptions{UserMetadata: map[string]string{"customer": "y/z"}}); nil != err {
+		t.Fatal(err)
+	}
+	if err := cache.DeleteData("data/b", minio.RemoveObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if keys := query(t, cache, map[string]string{"customer": "x"}); 0 != len(keys) {
+		t.Fatalf("expected no keys, got %v", keys)
+	}
+	if keys := query(t, cache, map[string]string{"customer": "y/z"}); !reflect.DeepEqual([]string{"data/a", "data/c"}, keys) {
+		t.Fatalf("expected data/a and data/c, got %v", keys)
+	}
+	if keys := query(t, cache, map[string]string{"kind": "model"}); !reflect.DeepEqual([]string{"data/c"}, keys) {
+		t.Fatalf("expected data/c, got %v", keys)
+	}
+}
+
+func TestRebuildIndex(t *testing.T) {
+	cache, backend := newTestCache(t, WithIndex("idx", "customer"))
+	if _, err := cache.WriteData("data/a", []byte("a"), minio.PutObjectOptions{UserMetadata: map[string]string{"customer": "x"}}); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := cache.WriteData("data/b", []byte("b"), minio.PutObjectOptions{UserMetadata: map[string]string{"customer": "x"}}); nil != err {
+		t.Fatal(err)
+	}
+
+	// Writes and deletes that bypass the Cache leave the index stale
+	putString(t, backend, "data/c", "c", minio.PutObjectOptions{UserMetadata: map[string]string{"customer": "x"}})
+	if err := backend.Delete(cache.ctx, "data/b", minio.RemoveObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if keys := query(t, cache, map[string]string{"customer": "x"}); !reflect.DeepEqual([]string{"data/a", "data/b"}, keys) {
+		t.Fatalf("expected the stale index, got %v", keys)
+	}
+
+	if err := cache.RebuildIndex("data/"); nil != err {
+		t.Fatal(err)
+	}
+	if keys := query(t, cache, map[string]string{"customer": "x"}); !reflect.DeepEqual([]string{"data/a", "data/c"}, keys) {
+		t.Fatalf("expected data/a and data/c, got %v", keys)
+	}
+	if objects, err := cache.List("idx/.keys/", minio.ListObjectsOptions{Recursive: true}); nil != err || 2 != len(objects) {
+		t.Fatalf("expected two index records, got %v %v", objects, err)
+	}
+}
@@ -0,0 +1,99 @@
+package minioproto
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.uber.org/zap"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// corruptingBackend stores a different payload than the one it was given
+type corruptingBackend struct {
+	*fileBackend
+}
+
+func (backend corruptingBackend) Put(ctx context.Context, key string, reader io.Reader, size int64, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	data, err := ioutil.ReadAll(reader)
+	if nil != err {
+		return minio.UploadInfo{}, err
+	}
+	data = append(data, '!')
+	return backend.fileBackend.Put(ctx, key, bytes.NewReader(data), int64(len(data)), opts)
+}
+
+func TestContentVerification(t *testing.T) {
+	cache, backend := newTestCache(t, WithContentVerification())
+	if _, err := cache.WriteData("verified", []byte("hello"), minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if data, err := cache.ReadData("verified", minio.GetObjectOptions{}); nil != err || "hello" != string(data) {
+		t.Fatalf("expected hello, got %v %v", string(data), err)
+	}
+
+	corrupt, err := NewWithBackend(context.Background(), zap.NewNop(), corruptingBackend{backend}, WithContentVerification())
+	if nil != err {
+		t.Fatal(err)
+	}
+	_, err = corrupt.WriteData("corrupt", []byte("hello"), minio.PutObjectOptions{})
+	mismatch := &ContentMismatchError{}
+	if !errors.As(err, &mismatch) || "size" != mismatch.Field {
+		t.Fatalf("expected a size mismatch, got %v", err)
+	}
+}
+
+func TestConditionalChecksums(t *testing.T) {
+	var mutex sync.Mutex
+	headers := http.Header{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "conditional-probe") {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		mutex.Lock()
+		headers = r.Header.Clone()
+		mutex.Unlock()
+		w.Header().Set("ETag", "\"etag\"")
+	}))
+	defer server.Close()
+
+	endpoint, _ := url.Parse(server.URL)
+	options := minio.Options{Creds: credentials.NewStaticV4("access", "secret", "")}
+	client, err := minio.New(endpoint.Host, &options)
+	if nil != err {
+		t.Fatal(err)
+	}
+	backend, err := NewMinioBackend(client, "bucket", options)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	data := []byte("hello")
+	opts := minio.PutObjectOptions{SendContentMd5: true}
+	if _, err := backend.(ConditionalBackend).PutIf(context.Background(), "key", bytes.NewReader(data), int64(len(data)), opts, PutCondition{IfNoneMatch: true}); nil != err {
+		t.Fatal(err)
+	}
+
+	md5Sum := md5.Sum(data)
+	sum := sha256.Sum256(data)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if expected := base64.StdEncoding.EncodeToString(md5Sum[:]); expected != headers.Get("Content-Md5") {
+		t.Fatalf("expected Content-MD5 %v, got %v", expected, headers.Get("Content-Md5"))
+	}
+	if expected := base64.StdEncoding.EncodeToString(sum[:]); expected != headers.Get("X-Amz-Checksum-Sha256") {
+		t.Fatalf("expected x-amz-checksum-sha256 %v, got %v", expected, headers.Get("X-Amz-Checksum-Sha256"))
+	}
+}
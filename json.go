@@ -0,0 +1,110 @@
+package minioproto
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+)
+
+// JSONCodec marshals and unmarshals JSON payloads, allowing alternative implementations
+// such as json-iterator's ConfigCompatibleWithStandardLibrary to be plugged in
+type JSONCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// StdJSONCodec is the default JSONCodec built on encoding/json, with configurable decoder settings
+type StdJSONCodec struct {
+	// UseNumber decodes numbers into json.Number instead of float64
+	UseNumber bool
+	// DisallowUnknownFields fails decoding when an object has keys without a matching struct field
+	DisallowUnknownFields bool
+}
+
+// Marshal serializes v with encoding/json
+func (codec StdJSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal deserializes data into v with encoding/json using the configured decoder settings
+func (codec StdJSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return codec.Decode(bytes.NewReader(data), v)
+}
+
+// JSONStreamCodec is a JSONCodec that can also decode from a reader, used by GetJSON for spilled payloads
+type JSONStreamCodec interface {
+	JSONCodec
+	Decode(reader io.Reader, v interface{}) error
+}
+
+// Decode deserializes a single JSON value from reader into v with encoding/json using the configured decoder settings
+func (codec StdJSONCodec) Decode(reader io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(reader)
+	if codec.UseNumber {
+		decoder.UseNumber()
+	}
+	if codec.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); nil != err {
+		return err
+	}
+	if _, err := decoder.Token(); io.EOF != err {
+		return errors.New("invalid character after top-level value")
+	}
+	return nil
+}
+
+// WithJSONCodec replaces the JSONCodec used by PutJSON and GetJSON, defaults to StdJSONCodec{}
+func WithJSONCodec(codec JSONCodec) Option {
+	return func(cache *Cache) {
+		cache.jsonCodec = codec
+	}
+}
+
+// WithCanonicalJSON makes PutJSON produce byte-identical output for identical inputs:
+// object keys are sorted (including struct fields), HTML characters are not escaped
+// and each level is indented with indent (empty for compact output).
+// Canonical output is always produced with encoding/json, bypassing any JSONCodec.
+func WithCanonicalJSON(indent string) Option {
+	return func(cache *Cache) {
+		cache.canonicalJSON = true
+		cache.jsonIndent = indent
+	}
+}
+
+// marshalJSON serializes data for PutJSON using the configured encoding
+func (cache *Cache) marshalJSON(data interface{}) ([]byte, error) {
+	if !cache.canonicalJSON {
+		return cache.jsonCodec.Marshal(data)
+	}
+
+	payload, err := encodeJSON(data, "")
+	if nil != err {
+		return nil, err
+	}
+
+	// Round trip through generic values so struct fields are sorted like map keys
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); nil != err {
+		return nil, err
+	}
+	return encodeJSON(generic, cache.jsonIndent)
+}
+
+// encodeJSON marshals without HTML escaping and without the trailing newline added by json.Encoder
+func encodeJSON(data interface{}, indent string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if "" != indent {
+		encoder.SetIndent("", indent)
+	}
+	if err := encoder.Encode(data); nil != err {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,63 @@
+package minioproto
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+)
+
+// GetJSONArray streams a JSON file holding a top-level array, decoding one element at a time
+// into a value from newElem and passing it to fn, so the whole array is never held in memory
+func (cache *Cache) GetJSONArray(path string, newElem func() interface{}, fn func(elem interface{}) error, opts minio.GetObjectOptions) error {
+	path = pathFix(path, jsonContentType)
+	cache.logger.Info(fmt.Sprintf("Streaming Json array, path=%v", path))
+	reader, err := cache.openStream(path, opts)
+	if nil != err {
+		err = errors.Wrap(err, "Failed to fetch JSON file")
+		cache.logger.Error(err.Error())
+		return err
+	}
+	defer reader.Close()
+
+	decoder := json.NewDecoder(reader)
+	if cache.strictDecoding {
+		decoder.DisallowUnknownFields()
+	}
+	token, err := decoder.Token()
+	if nil != err {
+		err = errors.Wrap(err, "Failed to read start of JSON array")
+		cache.logger.Error(err.Error())
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || '[' != delim {
+		err = errors.New(fmt.Sprintf("Expected a JSON array, found %v", token))
+		cache.logger.Error(err.Error())
+		return err
+	}
+
+	count := 0
+	for decoder.More() {
+		elem := newElem()
+		if err := decoder.Decode(elem); nil != err {
+			if cache.strictDecoding {
+				err = strictJSONError(err)
+			}
+			err = errors.Wrap(err, fmt.Sprintf("Failed deserialize array element %v", count))
+			cache.logger.Error(err.Error())
+			return err
+		}
+		if err := fn(elem); nil != err {
+			return err
+		}
+		count++
+	}
+	if _, err := decoder.Token(); nil != err {
+		err = errors.Wrap(err, "Failed to read end of JSON array")
+		cache.logger.Error(err.Error())
+		return err
+	}
+
+	cache.logger.Info(fmt.Sprintf("Success reading %v elements from path=%v", count, path))
+	return nil
+}
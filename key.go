@@ -0,0 +1,237 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrInvalidKey is returned when a Key has an empty or unsafe segment, or a key string can't be parsed
+var ErrInvalidKey = errors.New("Invalid key")
+
+const tenantAttribute = "tenant"
+
+// Key builds canonical object keys segment by segment, e.g.
+// K("models").Tenant(id).Date(t).Name("weights").Proto() -> "models/tenant=<id>/2024/01/31/weights.pb".
+// Dates use the yyyy/MM/dd layout of ListBetween, attributes are written as name=value.
+// Validation errors are reported when the key is built.
+type Key struct {
+	segments []string
+	name     string
+	err      error
+}
+
+// ParsedKey is a key string split back into the parts written by a Key
+type ParsedKey struct {
+	Root       string
+	Tenant     string
+	Date       time.Time
+	HasDate    bool
+	Attributes map[string]string
+	// Segments are the plain segments added with Path, in order
+	Segments    []string
+	Name        string
+	ContentType string
+}
+
+// K starts a Key under root
+func K(root string) *Key {
+	key := &Key{}
+	return key.add(root, "root")
+}
+
+// Tenant adds a tenant=<id> segment
+func (key *Key) Tenant(id string) *Key {
+	return key.Attr(tenantAttribute, id)
+}
+
+// Attr adds a name=value segment
+func (key *Key) Attr(name, value string) *Key {
+	if err := validateKeySegment(name, "attribute name"); nil != err {
+		key.fail(err)
+		return key
+	}
+	if err := validateKeySegment(value, "attribute "+name); nil != err {
+		key.fail(err)
+		return key
+	}
+	key.segments = append(key.segments, name+"="+value)
+	return key
+}
+
+// Date adds yyyy/MM/dd segments for the UTC date of t
+func (key *Key) Date(t time.Time) *Key {
+	key.segments = append(key.segments, strings.Split(ExpandTimeTemplate(t, "{yyyy}/{MM}/{dd}"), "/")...)
+	return key
+}
+
+// Hour adds yyyy/MM/dd/HH segments for the UTC hour of t
+func (key *Key) Hour(t time.Time) *Key {
+	key.segments = append(key.segments, strings.Split(ExpandTimeTemplate(t, "{yyyy}/{MM}/{dd}/{HH}"), "/")...)
+	return key
+}
+
+// Path adds plain segments
+func (key *Key) Path(segments ...string) *Key {
+	for _, segment := range segments {
+		key.add(segment, "segment")
+	}
+	return key
+}
+
+// Name sets the object name, the extension is added by the format method
+func (key *Key) Name(name string) *Key {
+	if err := validateKeySegment(name, "name"); nil != err {
+		key.fail(err)
+		return key
+	}
+	key.name = name
+	return key
+}
+
+// Proto builds the key with the .pb extension
+func (key *Key) Proto() (string, error) {
+	return key.Build(protobufContentType)
+}
+
+// JSON builds the key with the .json extension
+func (key *Key) JSON() (string, error) {
+	return key.Build(jsonContentType)
+}
+
+// CSV builds the key with the .csv extension
+func (key *Key) CSV() (string, error) {
+	return key.Build(csvContentType)
+}
+
+// TSV builds the key with the .tsv extension
+func (key *Key) TSV() (string, error) {
+	return key.Build(tsvContentType)
+}
+
+// XLSX builds the key with the .xlsx extension
+func (key *Key) XLSX() (string, error) {
+	return key.Build(xlsxContentType)
+}
+
+// Gob builds the key with the .gob extension
+func (key *Key) Gob() (string, error) {
+	return key.Build(gobContentType)
+}
+
+// Build returns the key with the extension of contentType, or the first validation error
+func (key *Key) Build(contentType string) (string, error) {
+	if nil != key.err {
+		return "", key.err
+	}
+	if "" == key.name {
+		return "", errors.Wrap(ErrInvalidKey, "missing name")
+	}
+	ext, ok := defaultExtensions[contentType]
+	if !ok {
+		return "", errors.Wrap(ErrInvalidKey, fmt.Sprintf("unknown content type %v", contentType))
+	}
+	return strings.Join(append(append([]string{}, key.segments...), key.name+"."+ext), "/"), nil
+}
+
+// add appends a plain segment
+func (key *Key) add(segment, what string) *Key {
+	if err := validateKeySegment(segment, what); nil != err {
+		key.fail(err)
+		return key
+	}
+	if strings.Contains(segment, "=") {
+		key.fail(errors.Wrap(ErrInvalidKey, fmt.Sprintf("%v %q contains =", what, segment)))
+		return key
+	}
+	key.segments = append(key.segments, segment)
+	return key
+}
+
+// fail records the first validation error
+func (key *Key) fail(err error) {
+	if nil == key.err {
+		key.err = err
+	}
+}
+
+// validateKeySegment rejects empty, relative and unprintable segments and segments containing a slash
+func validateKeySegment(segment, what string) error {
+	switch {
+	case "" == segment:
+		return errors.Wrap(ErrInvalidKey, fmt.Sprintf("empty %v", what))
+	case "." == segment || ".." == segment:
+		return errors.Wrap(ErrInvalidKey, fmt.Sprintf("%v %q is relative", what, segment))
+	case strings.Contains(segment, "/"):
+		return errors.Wrap(ErrInvalidKey, fmt.Sprintf("%v %q contains /", what, segment))
+	}
+	for _, r := range segment {
+		if !unicode.IsPrint(r) {
+			return errors.Wrap(ErrInvalidKey, fmt.Sprintf("%v %q contains unprintable characters", what, segment))
+		}
+	}
+	return nil
+}
+
+// ParseKey splits a key built by Key back into its parts
+func ParseKey(key string) (*ParsedKey, error) {
+	segments := strings.Split(key, "/")
+	if len(segments) < 2 {
+		return nil, errors.Wrap(ErrInvalidKey, fmt.Sprintf("%q has no root", key))
+	}
+	output := &ParsedKey{
+		Root:        segments[0],
+		Attributes:  map[string]string{},
+		Segments:    []string{},
+		ContentType: contentTypeForPath(key),
+	}
+	last := trimCompression(segments[len(segments)-1])
+	output.Name = strings.TrimSuffix(last, filepath.Ext(last))
+
+	rest := segments[1 : len(segments)-1]
+	for i := 0; i < len(rest); i++ {
+		segment := rest[i]
+		if index := strings.Index(segment, "="); index >= 0 {
+			name, value := segment[:index], segment[index+1:]
+			output.Attributes[name] = value
+			if tenantAttribute == name {
+				output.Tenant = value
+			}
+			continue
+		}
+		if date, width, ok := parseKeyDate(rest[i:]); ok && !output.HasDate {
+			output.Date, output.HasDate = date, true
+			i += width - 1
+			continue
+		}
+		output.Segments = append(output.Segments, segment)
+	}
+	return output, nil
+}
+
+// parseKeyDate reads yyyy/MM/dd with an optional HH from the start of segments
+func parseKeyDate(segments []string) (time.Time, int, bool) {
+	values := []int{}
+	for i, width := range []int{4, 2, 2, 2} {
+		if i >= len(segments) || len(segments[i]) != width {
+			break
+		}
+		value, err := strconv.Atoi(segments[i])
+		if nil != err {
+			break
+		}
+		values = append(values, value)
+	}
+	if len(values) < 3 {
+		return time.Time{}, 0, false
+	}
+	date := time.Date(values[0], time.Month(values[1]), values[2], 0, 0, 0, 0, time.UTC)
+	if 4 == len(values) {
+		date = date.Add(time.Duration(values[3]) * time.Hour)
+	}
+	return date, len(values), true
+}
@@ -0,0 +1,82 @@
+package minioproto
+
+import (
+	"github.com/pkg/errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected ParsedKey
+	}{
+		{
+			key: "models/weights.pb",
+			expected: ParsedKey{
+				Root:        "models",
+				Attributes:  map[string]string{},
+				Segments:    []string{},
+				Name:        "weights",
+				ContentType: protobufContentType,
+			},
+		},
+		{
+			key: "models/tenant=acme/kind=model/2024/01/31/weights.pb",
+			expected: ParsedKey{
+				Root:        "models",
+				Tenant:      "acme",
+				Date:        time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+				HasDate:     true,
+				Attributes:  map[string]string{"tenant": "acme", "kind": "model"},
+				Segments:    []string{},
+				Name:        "weights",
+				ContentType: protobufContentType,
+			},
+		},
+		{
+			key: "events/2024/01/31/09/eu/batch.json.gz",
+			expected: ParsedKey{
+				Root:        "events",
+				Date:        time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC),
+				HasDate:     true,
+				Attributes:  map[string]string{},
+				Segments:    []string{"eu"},
+				Name:        "batch",
+				ContentType: jsonContentType,
+			},
+		},
+		{
+			key: "exports/daily/2024/1/31/report.csv",
+			expected: ParsedKey{
+				Root:        "exports",
+				Attributes:  map[string]string{},
+				Segments:    []string{"daily", "2024", "1", "31"},
+				Name:        "report",
+				ContentType: csvContentType,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			output, err := ParseKey(test.key)
+			if nil != err {
+				t.Fatalf("ParseKey failed: %v", err)
+			}
+			if !reflect.DeepEqual(*output, test.expected) {
+				t.Fatalf("expected %+v, got %+v", test.expected, *output)
+			}
+		})
+	}
+}
+
+func TestParseKeyErrors(t *testing.T) {
+	for _, key := range []string{"", "weights.pb"} {
+		t.Run(key, func(t *testing.T) {
+			if _, err := ParseKey(key); ErrInvalidKey != errors.Cause(err) {
+				t.Fatalf("expected ErrInvalidKey, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"strings"
+)
+
+// ErrNoObjects is returned when no object exists under a prefix
+var ErrNoObjects = errors.New("No objects found under prefix")
+
+// Latest returns the most recently modified object under prefix
+func (cache *Cache) Latest(prefix string) (*minio.ObjectInfo, error) {
+	return cache.latest(prefix, "", func(a, b minio.ObjectInfo) bool {
+		return a.LastModified.After(b.LastModified)
+	})
+}
+
+// LatestByKey returns the object with the greatest key under prefix,
+// for layouts with sortable keys such as "snapshots/2024-01-31.json"
+func (cache *Cache) LatestByKey(prefix string) (*minio.ObjectInfo, error) {
+	return cache.latest(prefix, "", func(a, b minio.ObjectInfo) bool {
+		return a.Key > b.Key
+	})
+}
+
+// GetLatestJSON reads the most recently modified JSON file under prefix, returning the object it was read from
+func (cache *Cache) GetLatestJSON(prefix string, output interface{}, opts minio.GetObjectOptions) (*minio.ObjectInfo, error) {
+	info, err := cache.latest(prefix, jsonContentType, func(a, b minio.ObjectInfo) bool {
+		return a.LastModified.After(b.LastModified)
+	})
+	if nil != err {
+		return nil, err
+	}
+	if err := cache.GetJSON(info.Key, output, opts); nil != err {
+		return nil, err
+	}
+	return info, nil
+}
+
+// GetLatestPROTO reads the most recently modified PROTO file under prefix, returning the object it was read from
+func (cache *Cache) GetLatestPROTO(prefix string, data proto.Message, unmarshalOpts *proto.UnmarshalOptions, opts minio.GetObjectOptions) (*minio.ObjectInfo, error) {
+	info, err := cache.latest(prefix, protobufContentType, func(a, b minio.ObjectInfo) bool {
+		return a.LastModified.After(b.LastModified)
+	})
+	if nil != err {
+		return nil, err
+	}
+	if err := cache.GetPROTO(info.Key, data, unmarshalOpts, opts); nil != err {
+		return nil, err
+	}
+	return info, nil
+}
+
+// latest picks the first object under prefix according to newer, optionally restricted to a content type's extension
+func (cache *Cache) latest(prefix, contentType string, newer func(a, b minio.ObjectInfo) bool) (*minio.ObjectInfo, error) {
+	objects, err := cache.List(prefix, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return nil, err
+	}
+
+	var output *minio.ObjectInfo
+	for i, info := range objects {
+		if strings.HasSuffix(info.Key, "/") {
+			continue
+		}
+		if "" != contentType && pathFix(info.Key, contentType) != info.Key {
+			continue
+		}
+		if nil == output || newer(info, *output) {
+			output = &objects[i]
+		}
+	}
+
+	if nil == output {
+		err = errors.Wrap(ErrNoObjects, fmt.Sprintf("Failed to find latest object under prefix=%v", prefix))
+		cache.logger.Info(err.Error())
+		return nil, err
+	}
+	cache.logger.Info(fmt.Sprintf("Latest object under prefix=%v is path=%v", prefix, output.Key))
+	return output, nil
+}
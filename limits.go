@@ -0,0 +1,76 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+)
+
+// ErrTooLarge is returned when a payload exceeds the configured size limits
+var ErrTooLarge = errors.New("Payload exceeds the configured size limit")
+
+// WithMaxPutSize rejects writes larger than maxBytes with ErrTooLarge
+func WithMaxPutSize(maxBytes int64) Option {
+	return func(cache *Cache) {
+		cache.maxPutSize = maxBytes
+	}
+}
+
+// WithMaxGetSize aborts reads as soon as more than maxBytes have been received, returning ErrTooLarge
+func WithMaxGetSize(maxBytes int64) Option {
+	return func(cache *Cache) {
+		cache.maxGetSize = maxBytes
+	}
+}
+
+// WithSizeWarning logs a warning whenever a payload larger than thresholdBytes is read or written
+func WithSizeWarning(thresholdBytes int64) Option {
+	return func(cache *Cache) {
+		cache.warnSize = thresholdBytes
+	}
+}
+
+// checkPutSize enforces the size limits before a payload is uploaded
+func (cache *Cache) checkPutSize(path string, size int64) error {
+	if cache.maxPutSize > 0 && size > cache.maxPutSize {
+		err := errors.Wrap(ErrTooLarge, fmt.Sprintf("Refusing to write %v bytes to path=%v (max=%v)", size, path, cache.maxPutSize))
+		cache.logger.Error(err.Error())
+		return err
+	}
+	cache.warnIfLarge(path, size)
+	return nil
+}
+
+// checkGetSize enforces the size limits on an object about to be downloaded
+func (cache *Cache) checkGetSize(path string, size int64) error {
+	if cache.maxGetSize > 0 && size > cache.maxGetSize {
+		err := errors.Wrap(ErrTooLarge, fmt.Sprintf("Refusing to read %v bytes from path=%v (max=%v)", size, path, cache.maxGetSize))
+		cache.logger.Error(err.Error())
+		return err
+	}
+	cache.warnIfLarge(path, size)
+	return nil
+}
+
+// readLimited reads the full reader, aborting once more than maxGetSize bytes have been received
+func (cache *Cache) readLimited(path string, reader io.Reader) ([]byte, error) {
+	if cache.maxGetSize <= 0 {
+		return ioutil.ReadAll(reader)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(reader, cache.maxGetSize+1))
+	if nil != err {
+		return nil, err
+	}
+	if int64(len(data)) > cache.maxGetSize {
+		return nil, cache.checkGetSize(path, int64(len(data)))
+	}
+	return data, nil
+}
+
+func (cache *Cache) warnIfLarge(path string, size int64) {
+	if cache.warnSize > 0 && size > cache.warnSize {
+		cache.logger.Warn(fmt.Sprintf("Large payload at path=%v with %v bytes (threshold=%v)", path, size, cache.warnSize))
+	}
+}
@@ -0,0 +1,14 @@
+package minioproto
+
+import (
+	"github.com/minio/minio-go/v7"
+)
+
+// List returns the objects stored under the given prefix
+func (cache *Cache) List(prefix string, opts minio.ListObjectsOptions) ([]minio.ObjectInfo, error) {
+	output, err := cache.ListPartial(cache.ctx, prefix, opts)
+	if nil != err {
+		return nil, err
+	}
+	return output, nil
+}
@@ -0,0 +1,308 @@
+package minioproto
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultMaintenanceLeaseKey = ".maintenance/leader.json"
+const defaultMaintenanceLeaseTTL = time.Minute
+const minMaintenanceLeaseTTL = 3 * time.Second
+
+// MaintenanceOptions configures NewMaintenance
+type MaintenanceOptions struct {
+	// LeaseKey is the object used for leader election, defaults to .maintenance/leader.json
+	LeaseKey string
+	// LeaseTTL is how long leadership lasts without renewal, defaults to 1m and is at least 3s
+	LeaseTTL time.Duration
+	// Holder identifies this replica in the lease, defaults to a random ID
+	Holder string
+}
+
+// Maintenance runs registered housekeeping tasks on their schedules. Shared tasks only run on the
+// replica holding the leader lease stored in the bucket, local tasks run on every replica.
+// The lease is taken and renewed with conditional writes, so the backend must implement ConditionalBackend,
+// and it is renewed every LeaseTTL/3 while shared tasks run so long tasks don't lose leadership midway.
+// When a renewal fails the context passed to the running shared tasks is cancelled.
+type Maintenance struct {
+	cache *Cache
+	opts  MaintenanceOptions
+
+	mutex sync.Mutex
+	tasks []*maintenanceTask
+}
+
+// maintenanceTask is a registered task and its next run time
+type maintenanceTask struct {
+	name     string
+	schedule Schedule
+	local    bool
+	fn       func(ctx context.Context) error
+	next     time.Time
+}
+
+// leaderLease is the content of the leader election object
+type leaderLease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// NewMaintenance creates a Maintenance runner for the Cache, start it with Run
+func (cache *Cache) NewMaintenance(maintenanceOpts MaintenanceOptions) *Maintenance {
+	if "" == maintenanceOpts.LeaseKey {
+		maintenanceOpts.LeaseKey = defaultMaintenanceLeaseKey
+	}
+	if maintenanceOpts.LeaseTTL <= 0 {
+		maintenanceOpts.LeaseTTL = defaultMaintenanceLeaseTTL
+	} else if maintenanceOpts.LeaseTTL < minMaintenanceLeaseTTL {
+		maintenanceOpts.LeaseTTL = minMaintenanceLeaseTTL
+	}
+	if "" == maintenanceOpts.Holder {
+		token := make([]byte, 8)
+		rand.Read(token)
+		maintenanceOpts.Holder = hex.EncodeToString(token)
+	}
+	return &Maintenance{cache: cache, opts: maintenanceOpts}
+}
+
+// Register adds a task that only runs on the leader, for housekeeping of the shared bucket
+func (maintenance *Maintenance) Register(name string, schedule Schedule, fn func(ctx context.Context) error) {
+	maintenance.register(name, schedule, false, fn)
+}
+
+// RegisterLocal adds a task that runs on every replica, for housekeeping of local state such as disk caches
+func (maintenance *Maintenance) RegisterLocal(name string, schedule Schedule, fn func(ctx context.Context) error) {
+	maintenance.register(name, schedule, true, fn)
+}
+
+func (maintenance *Maintenance) register(name string, schedule Schedule, local bool, fn func(ctx context.Context) error) {
+	maintenance.mutex.Lock()
+	defer maintenance.mutex.Unlock()
+	maintenance.tasks = append(maintenance.tasks, &maintenanceTask{
+		name:     name,
+		schedule: schedule,
+		local:    local,
+		fn:       fn,
+		next:     schedule.Next(time.Now()),
+	})
+}
+
+// RunNow runs a registered task immediately regardless of its schedule and leadership
+func (maintenance *Maintenance) RunNow(ctx context.Context, name string) error {
+	maintenance.mutex.Lock()
+	defer maintenance.mutex.Unlock()
+	for _, task := range maintenance.tasks {
+		if name == task.name {
+			return maintenance.runTask(ctx, task)
+		}
+	}
+	return errors.New(fmt.Sprintf("Maintenance task %v is not registered", name))
+}
+
+// Run runs due tasks until ctx is done, renewing or contending for leadership on every tick.
+// Task failures are logged and the task is retried at its next scheduled time.
+func (maintenance *Maintenance) Run(ctx context.Context) error {
+	tick := maintenance.opts.LeaseTTL / 3
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		maintenance.runDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			maintenance.resign()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDue runs every task whose next run time has passed, shared tasks are skipped once leadership is lost
+func (maintenance *Maintenance) runDue(ctx context.Context, now time.Time) {
+	maintenance.mutex.Lock()
+	defer maintenance.mutex.Unlock()
+
+	due := []*maintenanceTask{}
+	for _, task := range maintenance.tasks {
+		if !task.next.IsZero() && !now.Before(task.next) {
+			due = append(due, task)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].next.Before(due[j].next) })
+
+	var shared context.Context
+	checked := false
+	for _, task := range due {
+		taskCtx := ctx
+		if !task.local {
+			if !checked {
+				checked = true
+				if maintenance.lead() {
+					var cancel context.CancelFunc
+					shared, cancel = context.WithCancel(ctx)
+					defer cancel()
+					stop := maintenance.renew(cancel)
+					defer close(stop)
+				}
+			}
+			if nil == shared || nil != shared.Err() {
+				continue
+			}
+			taskCtx = shared
+		}
+		maintenance.runTask(taskCtx, task)
+		task.next = task.schedule.Next(now)
+	}
+}
+
+// renew keeps renewing the leader lease in the background until the returned channel is closed,
+// calling cancel and stopping when leadership is lost
+func (maintenance *Maintenance) renew(cancel context.CancelFunc) chan struct{} {
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(maintenance.opts.LeaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			if !maintenance.lead() {
+				maintenance.cache.logger.Warn("Lost maintenance leadership while running shared tasks")
+				cancel()
+				return
+			}
+		}
+	}()
+	return stop
+}
+
+// runTask runs a single task and logs the outcome
+func (maintenance *Maintenance) runTask(ctx context.Context, task *maintenanceTask) error {
+	logger := maintenance.cache.logger
+	logger.Info(fmt.Sprintf("Running maintenance task %v", task.name))
+	start := time.Now()
+	if err := task.fn(ctx); nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed maintenance task %v", task.name))
+		logger.Error(err.Error())
+		return err
+	}
+	logger.Info(fmt.Sprintf("Finished maintenance task %v in %v", task.name, time.Since(start)))
+	return nil
+}
+
+// lead acquires or renews the leader lease, returning whether this replica is the leader
+func (maintenance *Maintenance) lead() bool {
+	current, etag, err := maintenance.currentLeader()
+	if nil != err {
+		maintenance.cache.logger.Warn(fmt.Sprintf("Failed to read maintenance leader: %v", err.Error()))
+		return false
+	}
+	if nil != current && current.Holder != maintenance.opts.Holder && time.Now().Before(current.Expires) {
+		return false
+	}
+
+	lease := &leaderLease{Holder: maintenance.opts.Holder, Expires: time.Now().Add(maintenance.opts.LeaseTTL)}
+	written, err := maintenance.writeLeader(lease, etag)
+	if nil != err {
+		maintenance.cache.logger.Warn(fmt.Sprintf("Failed to write maintenance leader: %v", err.Error()))
+		return false
+	}
+	return written
+}
+
+// resign releases the leader lease if this replica holds it
+func (maintenance *Maintenance) resign() {
+	current, etag, err := maintenance.currentLeader()
+	if nil == err && nil != current && current.Holder == maintenance.opts.Holder {
+		maintenance.writeLeader(&leaderLease{Holder: maintenance.opts.Holder, Expires: time.Now()}, etag)
+	}
+}
+
+// currentLeader reads the leader lease and its ETag from the bucket, bypassing local caches, or nil if there is none
+func (maintenance *Maintenance) currentLeader() (*leaderLease, string, error) {
+	data, etag, err := maintenance.cache.getRecord(maintenance.opts.LeaseKey)
+	if nil != err || nil == data {
+		return nil, "", err
+	}
+	lease := &leaderLease{}
+	if err := json.Unmarshal(data, lease); nil != err {
+		return nil, "", errors.Wrap(err, "Failed deserialize leader lease from json")
+	}
+	return lease, etag, nil
+}
+
+// writeLeader replaces the leader lease with the given ETag, or creates it when etag is empty,
+// reporting false when another replica changed the lease first
+func (maintenance *Maintenance) writeLeader(lease *leaderLease, etag string) (bool, error) {
+	payload, err := json.Marshal(lease)
+	if nil != err {
+		return false, err
+	}
+	cond := PutCondition{IfNoneMatch: "" == etag, IfMatch: etag}
+	return maintenance.cache.putRecord(maintenance.opts.LeaseKey, payload, cond)
+}
+
+// CleanupIncompleteUploadsTask returns a task running CleanupIncompleteUploads
+func CleanupIncompleteUploadsTask(cache *Cache, prefix string, olderThan time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := cache.With(CallContext(ctx)).CleanupIncompleteUploads(prefix, olderThan)
+		return err
+	}
+}
+
+// DeletePartitionsTask returns a task deleting time partitions under prefix older than retention
+func DeletePartitionsTask(cache *Cache, prefix string, retention time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := cache.With(CallContext(ctx)).DeletePartitionsBefore(prefix, time.Now().Add(-retention))
+		return err
+	}
+}
+
+// EvictDiskCacheTask returns a local task trimming the disk cache to its size limit
+func EvictDiskCacheTask(cache *Cache) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if nil == cache.diskCache {
+			return nil
+		}
+		return cache.diskCache.Evict()
+	}
+}
+
+// ReplaySpoolTask returns a local task replaying writes spooled while minio was unreachable
+func ReplaySpoolTask(cache *Cache) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := cache.With(CallContext(ctx)).ReplaySpool()
+		return err
+	}
+}
+
+// InventoryTask returns a task exporting the listing of prefix as a CSV with key, size, etag and last modified columns
+func InventoryTask(cache *Cache, prefix, dstKey string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		cache := cache.With(CallContext(ctx))
+		objects, err := cache.List(prefix, minio.ListObjectsOptions{Recursive: true})
+		if nil != err {
+			return err
+		}
+		records := [][]string{{"key", "size", "etag", "last_modified"}}
+		for _, info := range objects {
+			records = append(records, []string{
+				info.Key,
+				fmt.Sprint(info.Size),
+				info.ETag,
+				info.LastModified.UTC().Format(time.RFC3339),
+			})
+		}
+		_, err = cache.PutCSV(dstKey, records, nil, minio.PutObjectOptions{})
+		return err
+	}
+}
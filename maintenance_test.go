@@ -0,0 +1,86 @@
+package minioproto
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceLeaseTTL(t *testing.T) {
+	cache, _ := newTestCache(t)
+	if ttl := cache.NewMaintenance(MaintenanceOptions{LeaseTTL: time.Nanosecond}).opts.LeaseTTL; minMaintenanceLeaseTTL != ttl {
+		t.Fatalf("expected the minimum lease TTL, got %v", ttl)
+	}
+	if ttl := cache.NewMaintenance(MaintenanceOptions{}).opts.LeaseTTL; defaultMaintenanceLeaseTTL != ttl {
+		t.Fatalf("expected the default lease TTL, got %v", ttl)
+	}
+}
+
+func TestMaintenanceLeadership(t *testing.T) {
+	cache, _ := newTestCache(t)
+	leader := cache.NewMaintenance(MaintenanceOptions{Holder: "leader", LeaseTTL: minMaintenanceLeaseTTL})
+	follower := cache.NewMaintenance(MaintenanceOptions{Holder: "follower", LeaseTTL: minMaintenanceLeaseTTL})
+
+	runs := map[string]int{}
+	for _, maintenance := range []*Maintenance{leader, follower} {
+		holder := maintenance.opts.Holder
+		maintenance.Register("shared", Every(time.Minute), func(ctx context.Context) error {
+			runs[holder+"/shared"]++
+			return nil
+		})
+		maintenance.RegisterLocal("local", Every(time.Minute), func(ctx context.Context) error {
+			runs[holder+"/local"]++
+			return nil
+		})
+	}
+
+	later := time.Now().Add(time.Hour)
+	leader.runDue(context.Background(), later)
+	follower.runDue(context.Background(), later)
+	expected := map[string]int{"leader/shared": 1, "leader/local": 1, "follower/local": 1}
+	for key, count := range expected {
+		if count != runs[key] {
+			t.Fatalf("expected %v runs of %v, got %v", count, key, runs)
+		}
+	}
+	if 0 != runs["follower/shared"] {
+		t.Fatalf("expected the follower to skip shared tasks, got %v", runs)
+	}
+}
+
+func TestMaintenanceLostLeadership(t *testing.T) {
+	cache, _ := newTestCache(t)
+	maintenance := cache.NewMaintenance(MaintenanceOptions{Holder: "leader", LeaseTTL: minMaintenanceLeaseTTL})
+	other := cache.NewMaintenance(MaintenanceOptions{Holder: "other", LeaseTTL: time.Hour})
+
+	cancelled := false
+	maintenance.Register("long", Every(time.Minute), func(ctx context.Context) error {
+		// Another replica takes over the lease while the task runs
+		_, etag, err := other.currentLeader()
+		if nil != err {
+			return err
+		}
+		if _, err := other.writeLeader(&leaderLease{Holder: "other", Expires: time.Now().Add(time.Hour)}, etag); nil != err {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			cancelled = true
+		case <-time.After(2 * minMaintenanceLeaseTTL):
+		}
+		return nil
+	})
+	skipped := true
+	maintenance.Register("next", Every(time.Minute), func(ctx context.Context) error {
+		skipped = false
+		return nil
+	})
+
+	maintenance.runDue(context.Background(), time.Now().Add(time.Hour))
+	if !cancelled {
+		t.Fatal("expected the task context to be cancelled when leadership was lost")
+	}
+	if !skipped {
+		t.Fatal("expected later shared tasks to be skipped after leadership was lost")
+	}
+}
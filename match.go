@@ -0,0 +1,59 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ListGlob returns the objects whose keys match a path.Match pattern such as "runs/2024-*/metrics.json".
+// Only the literal portion before the first wildcard is listed, the rest is filtered client-side.
+func (cache *Cache) ListGlob(pattern string) ([]minio.ObjectInfo, error) {
+	if _, err := path.Match(pattern, ""); nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Invalid glob pattern %v", pattern))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	prefix := pattern
+	if index := strings.IndexAny(pattern, "*?[\\"); index >= 0 {
+		prefix = pattern[:index]
+	}
+	return cache.listMatching(prefix, func(key string) bool {
+		ok, _ := path.Match(pattern, key)
+		return ok
+	})
+}
+
+// ListRegex returns the objects whose keys match the regular expression.
+// Anchored expressions ("^runs/2024-.*") only list the literal prefix, others list the whole bucket.
+func (cache *Cache) ListRegex(re *regexp.Regexp) ([]minio.ObjectInfo, error) {
+	prefix := ""
+	if expr := re.String(); strings.HasPrefix(expr, "^") {
+		if unanchored, err := regexp.Compile(expr[1:]); nil == err {
+			prefix, _ = unanchored.LiteralPrefix()
+		}
+	}
+	return cache.listMatching(prefix, re.MatchString)
+}
+
+// listMatching lists the prefix recursively, keeping the objects accepted by match
+func (cache *Cache) listMatching(prefix string, match func(key string) bool) ([]minio.ObjectInfo, error) {
+	objects, err := cache.List(prefix, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return nil, err
+	}
+
+	output := []minio.ObjectInfo{}
+	for _, info := range objects {
+		if match(info.Key) {
+			output = append(output, info)
+		}
+	}
+
+	cache.logger.Info(fmt.Sprintf("Matched %v of %v objects under prefix=%v", len(output), len(objects), prefix))
+	return output, nil
+}
@@ -0,0 +1,392 @@
+package minioproto
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/csv"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// MergeCSVOptions configures MergeCSV, the zero value concatenates the sources in order
+type MergeCSVOptions struct {
+	// CSV configures parsing the sources and writing the destination
+	CSV *CSVOptions
+	// KeyColumns names the header columns rows are sorted and deduplicated by, defaults to every column
+	KeyColumns []string
+	// Sort orders the rows by the key columns, each source is sorted on its own and then merged
+	Sort bool
+	// Dedupe keeps only the first row seen for each key
+	Dedupe bool
+	// PutOptions is used when writing the destination, the content type is always CSV
+	PutOptions minio.PutObjectOptions
+}
+
+// MergeCSV merges the CSV sources into a single CSV at dstKey. The header of the first source is written once,
+// later sources have their header dropped and their columns aligned to it by name.
+// Sources are read like ReadData, so encrypted, compressed and signed sources are opened first, and without
+// Sort they are streamed row by row. Sort loads each source fully into memory in turn to sort it into a temp file.
+// The destination is streamed straight to the backend unless encryption, signatures, compression or a profile
+// apply to dstKey, in which case it is buffered and written through WriteData. Streamed destinations bypass
+// WriteData like ArchivePrefix, so they aren't indexed, mirrored by StartDualWrite or kept in the local tiers.
+func (cache *Cache) MergeCSV(dstKey string, mergeOpts *MergeCSVOptions, srcKeys ...string) (*WriteResult, error) {
+	if nil == mergeOpts {
+		mergeOpts = &MergeCSVOptions{}
+	}
+	dstKey = pathFix(dstKey, csvContentType)
+	cache.logger.Info(fmt.Sprintf("Merging %v CSV files to path=%v", len(srcKeys), dstKey))
+	if 0 == len(srcKeys) {
+		err := errors.New("MergeCSV requires at least one source")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	opts := mergeOpts.PutOptions
+	opts.ContentType = csvContentType
+	if cache.needsWholePayload(dstKey) {
+		buf := &bytes.Buffer{}
+		if err := cache.writeMerged(buf, mergeOpts, srcKeys); nil != err {
+			err = errors.Wrap(err, "Failed to merge CSV")
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
+		return cache.WriteData(dstKey, buf.Bytes(), opts)
+	}
+
+	dstKey = cache.objectKey(dstKey)
+	if err := cache.authorize(AuthPut, dstKey, nil); nil != err {
+		return nil, err
+	}
+
+	// Stream the merged rows through a pipe so the destination never has to be held in memory
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(cache.writeMerged(writer, mergeOpts, srcKeys))
+	}()
+
+	uploadInfo, err := cache.backend.Put(cache.ctx, dstKey, reader, -1, opts)
+	reader.CloseWithError(err)
+	if nil != err {
+		err = errors.Wrap(err, "Failed to upload merged CSV")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	result := newWriteResult(uploadInfo)
+	cache.invalidateTiers(dstKey)
+	cache.firePut(dstKey, result)
+	cache.logger.Info(fmt.Sprintf("Successfully merged CSV bytes: %v", uploadInfo.Size))
+	return result, nil
+}
+
+// csvSource yields the aligned rows of one source
+type csvSource struct {
+	reader  *csv.Reader
+	closer  io.Closer
+	mapping []int
+}
+
+// next returns the next row aligned to the merged header, or io.EOF
+func (source *csvSource) next() ([]string, error) {
+	record, err := source.reader.Read()
+	if nil != err {
+		return nil, err
+	}
+	if nil == source.mapping {
+		return record, nil
+	}
+	row := make([]string, len(source.mapping))
+	for i, index := range source.mapping {
+		if index >= 0 {
+			row[i] = column(record, index)
+		}
+	}
+	return row, nil
+}
+
+// writeMerged writes the header and merged rows of the sources to output
+func (cache *Cache) writeMerged(output io.Writer, mergeOpts *MergeCSVOptions, srcKeys []string) error {
+	csvOpts := mergeOpts.CSV
+	writer := csvOpts.configureWriter(csv.NewWriter(output))
+
+	var header []string
+	var keys []int
+	sources := []*csvSource{}
+	defer func() {
+		for _, source := range sources {
+			source.closer.Close()
+		}
+	}()
+
+	for _, key := range srcKeys {
+		obj, err := cache.openStream(key, minio.GetObjectOptions{})
+		if nil != err {
+			return err
+		}
+		source := &csvSource{reader: csvOpts.configureReader(csv.NewReader(obj)), closer: obj}
+		source.reader.FieldsPerRecord = -1
+		sources = append(sources, source)
+
+		sourceHeader, err := source.reader.Read()
+		if io.EOF == err {
+			continue
+		}
+		if nil != err {
+			return errors.Wrap(err, fmt.Sprintf("Failed to read header of %v", key))
+		}
+
+		if nil == header {
+			header = sourceHeader
+			if keys, err = keyIndexes(header, mergeOpts.KeyColumns); nil != err {
+				return err
+			}
+			if err = writer.Write(header); nil != err {
+				return err
+			}
+			continue
+		}
+		if source.mapping, err = alignHeader(header, sourceHeader); nil != err {
+			return errors.Wrap(err, fmt.Sprintf("Failed to align header of %v", key))
+		}
+	}
+
+	if mergeOpts.Sort {
+		sorted, err := cache.sortSources(sources, keys, csvOpts)
+		sources = append(sources, sorted...)
+		if nil != err {
+			return err
+		}
+		err = mergeSorted(writer, sorted, keys, mergeOpts.Dedupe)
+		if nil != err {
+			return err
+		}
+	} else {
+		seen := map[string]bool{}
+		for _, source := range sources {
+			for {
+				row, err := source.next()
+				if io.EOF == err {
+					break
+				}
+				if nil != err {
+					return errors.Wrap(err, "Failed to read CSV row")
+				}
+				if mergeOpts.Dedupe {
+					rowKey := joinKey(row, keys)
+					if seen[rowKey] {
+						continue
+					}
+					seen[rowKey] = true
+				}
+				if err = writer.Write(row); nil != err {
+					return err
+				}
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// sortSources sorts each source by the key columns into a temp file, holding one source in memory at a time
+func (cache *Cache) sortSources(sources []*csvSource, keys []int, csvOpts *CSVOptions) ([]*csvSource, error) {
+	sorted := []*csvSource{}
+	for _, source := range sources {
+		rows := [][]string{}
+		for {
+			row, err := source.next()
+			if io.EOF == err {
+				break
+			}
+			if nil != err {
+				return sorted, errors.Wrap(err, "Failed to read CSV row")
+			}
+			rows = append(rows, row)
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			return compareKeys(rows[i], rows[j], keys) < 0
+		})
+
+		file, err := ioutil.TempFile("", "minioproto-merge-*.csv")
+		if nil != err {
+			return sorted, errors.Wrap(err, "Failed to create temp file")
+		}
+		sorted = append(sorted, &csvSource{closer: &tempFile{file}})
+		writer := csvOpts.configureWriter(csv.NewWriter(file))
+		if err = writer.WriteAll(rows); nil != err {
+			return sorted, errors.Wrap(err, "Failed to write temp file")
+		}
+		if _, err = file.Seek(0, io.SeekStart); nil != err {
+			return sorted, err
+		}
+		reader := csvOpts.configureReader(csv.NewReader(file))
+		reader.FieldsPerRecord = -1
+		reader.Comment = 0
+		sorted[len(sorted)-1].reader = reader
+	}
+	return sorted, nil
+}
+
+// tempFile removes the file when closed
+type tempFile struct {
+	*os.File
+}
+
+// Close closes and removes the file
+func (file *tempFile) Close() error {
+	file.File.Close()
+	return os.Remove(file.Name())
+}
+
+// mergeHead is the current row of a sorted source
+type mergeHead struct {
+	row    []string
+	source int
+}
+
+// mergeHeap orders heads by key, breaking ties by source order
+type mergeHeap struct {
+	heads []mergeHead
+	keys  []int
+}
+
+func (h *mergeHeap) Len() int { return len(h.heads) }
+func (h *mergeHeap) Less(i, j int) bool {
+	if c := compareKeys(h.heads[i].row, h.heads[j].row, h.keys); 0 != c {
+		return c < 0
+	}
+	return h.heads[i].source < h.heads[j].source
+}
+func (h *mergeHeap) Swap(i, j int)      { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+func (h *mergeHeap) Push(x interface{}) { h.heads = append(h.heads, x.(mergeHead)) }
+func (h *mergeHeap) Pop() interface{} {
+	head := h.heads[len(h.heads)-1]
+	h.heads = h.heads[:len(h.heads)-1]
+	return head
+}
+
+// mergeSorted performs a k-way merge of the sorted sources into writer
+func mergeSorted(writer *csv.Writer, sources []*csvSource, keys []int, dedupe bool) error {
+	h := &mergeHeap{keys: keys}
+	advance := func(i int) error {
+		row, err := sources[i].next()
+		if io.EOF == err {
+			return nil
+		}
+		if nil != err {
+			return errors.Wrap(err, "Failed to read sorted CSV row")
+		}
+		heap.Push(h, mergeHead{row: row, source: i})
+		return nil
+	}
+	for i := range sources {
+		if err := advance(i); nil != err {
+			return err
+		}
+	}
+
+	var last []string
+	for h.Len() > 0 {
+		head := heap.Pop(h).(mergeHead)
+		if !dedupe || nil == last || 0 != compareKeys(last, head.row, keys) {
+			if err := writer.Write(head.row); nil != err {
+				return err
+			}
+			last = head.row
+		}
+		if err := advance(head.source); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyIndexes resolves the key column names against the header, defaulting to every column
+func keyIndexes(header []string, columns []string) ([]int, error) {
+	if 0 == len(columns) {
+		return nil, nil
+	}
+	keys := make([]int, len(columns))
+	for i, name := range columns {
+		keys[i] = -1
+		for j, candidate := range header {
+			if candidate == name {
+				keys[i] = j
+				break
+			}
+		}
+		if keys[i] < 0 {
+			return nil, errors.New(fmt.Sprintf("Key column %v not found in header", name))
+		}
+	}
+	return keys, nil
+}
+
+// alignHeader maps the merged header columns to columns of a source header, nil when they are identical
+func alignHeader(header []string, sourceHeader []string) ([]int, error) {
+	identical := len(header) == len(sourceHeader)
+	positions := map[string]int{}
+	for i, name := range sourceHeader {
+		positions[name] = i
+		if identical && header[i] != name {
+			identical = false
+		}
+	}
+	if identical {
+		return nil, nil
+	}
+
+	mapping := make([]int, len(header))
+	for i, name := range header {
+		index, ok := positions[name]
+		if !ok {
+			index = -1
+		}
+		mapping[i] = index
+		delete(positions, name)
+	}
+	for name := range positions {
+		return nil, errors.New(fmt.Sprintf("Column %v is not in the first header", name))
+	}
+	return mapping, nil
+}
+
+// compareKeys compares two rows by the key columns, or every column when keys is empty
+func compareKeys(a, b []string, keys []int) int {
+	if 0 == len(keys) {
+		return strings.Compare(strings.Join(a, "\x00"), strings.Join(b, "\x00"))
+	}
+	for _, key := range keys {
+		if c := strings.Compare(column(a, key), column(b, key)); 0 != c {
+			return c
+		}
+	}
+	return 0
+}
+
+// joinKey builds the dedupe key of a row
+func joinKey(row []string, keys []int) string {
+	if 0 == len(keys) {
+		return strings.Join(row, "\x00")
+	}
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = column(row, key)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// column returns the value at index, or empty when the row is short
+func column(row []string, index int) string {
+	if index < len(row) {
+		return row[index]
+	}
+	return ""
+}
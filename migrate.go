@@ -0,0 +1,302 @@
+package minioproto
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MigrateOptions configures Migrate
+type MigrateOptions struct {
+	// Prefix limits the migration to keys under a prefix
+	Prefix string
+	// Batch configures the concurrency and retries of the copies
+	Batch BatchOptions
+	// Force copies objects even when the destination already has the same size and ETag
+	Force bool
+}
+
+// SyncOptions configures Sync
+type SyncOptions struct {
+	// Batch configures the concurrency and retries of the copies and deletes
+	Batch BatchOptions
+	// Delete removes objects under the prefix that only exist in the destination
+	Delete bool
+}
+
+// Divergence reports how the destination of a migration differs from the source
+type Divergence struct {
+	// Missing keys exist in the source only
+	Missing []string `json:"missing"`
+	// Extra keys exist in the destination only
+	Extra []string `json:"extra"`
+	// Changed keys differ in size or content
+	Changed []string `json:"changed"`
+}
+
+// Converged reports whether the destination matches the source
+func (divergence *Divergence) Converged() bool {
+	return 0 == len(divergence.Missing) && 0 == len(divergence.Extra) && 0 == len(divergence.Changed)
+}
+
+// dualWriteState holds the destination writes are mirrored to during a migration
+type dualWriteState struct {
+	sync.RWMutex
+	dst *Cache
+}
+
+// StartDualWrite mirrors every subsequent WriteData and DeleteData to dst, so writers keep running
+// while Migrate copies the existing objects. Streaming writers such as ArchivePrefix are not mirrored,
+// they and any mirror failures, which are only logged, surface in CheckCutover.
+// Mirrored keys are relative to the tenant of the writing view and land under the tenant of dst.
+func (cache *Cache) StartDualWrite(dst *Cache) {
+	cache.dualWrite.Lock()
+	defer cache.dualWrite.Unlock()
+	cache.dualWrite.dst = dst
+	cache.logger.Info(fmt.Sprintf("Started dual writes to bucket=%v", dst.bucketName))
+}
+
+// StopDualWrite stops mirroring writes, e.g. after cutover
+func (cache *Cache) StopDualWrite() {
+	cache.dualWrite.Lock()
+	defer cache.dualWrite.Unlock()
+	cache.dualWrite.dst = nil
+}
+
+// mirrorTarget returns the dual write destination, nil when dual writes are off
+func (cache *Cache) mirrorTarget() *Cache {
+	cache.dualWrite.RLock()
+	defer cache.dualWrite.RUnlock()
+	return cache.dualWrite.dst
+}
+
+// mirrorWrite copies a stored payload at the object key path to the dual write destination,
+// keeping its key relative to the tenant of the view
+func (cache *Cache) mirrorWrite(path string, data []byte, opts minio.PutObjectOptions) {
+	dst := cache.mirrorTarget()
+	if nil == dst {
+		return
+	}
+	if _, err := dst.putRaw(dst.objectKey(cache.relativeKey(path)), data, opts); nil != err {
+		cache.logger.Warn(fmt.Sprintf("Failed to mirror path=%v: %v", path, err.Error()))
+	}
+}
+
+// mirrorDelete removes an object deleted at the object key path from the dual write destination
+func (cache *Cache) mirrorDelete(path string, opts minio.RemoveObjectOptions) {
+	dst := cache.mirrorTarget()
+	if nil == dst {
+		return
+	}
+	key := dst.objectKey(cache.relativeKey(path))
+	if err := dst.authorize(AuthDelete, key, nil); nil != err {
+		cache.logger.Warn(fmt.Sprintf("Failed to mirror delete of path=%v: %v", path, err.Error()))
+		return
+	}
+	if err := dst.backend.Delete(dst.ctx, key, opts); nil != err {
+		cache.logger.Warn(fmt.Sprintf("Failed to mirror delete of path=%v: %v", path, err.Error()))
+	}
+	dst.invalidateTiers(key)
+}
+
+// putRaw stores an already encoded payload as is at the object key, bypassing encryption and signing
+func (cache *Cache) putRaw(key string, data []byte, opts minio.PutObjectOptions) (*WriteResult, error) {
+	if err := cache.authorize(AuthPut, key, opts.UserMetadata); nil != err {
+		return nil, err
+	}
+	reader := bytes.NewReader(data)
+	uploadInfo, err := cache.backend.Put(cache.ctx, key, reader, reader.Size(), opts)
+	if nil != err {
+		return nil, err
+	}
+	cache.invalidateTiers(key)
+	return newWriteResult(uploadInfo), nil
+}
+
+// Migrate copies every object to dst with its content type, metadata, tags and storage class, verifying each copy.
+// Objects already in dst with the same size and ETag are skipped unless Force is set.
+// Combine with StartDualWrite to migrate without stopping writers, then confirm with CheckCutover.
+func (cache *Cache) Migrate(dst *Cache, migrateOpts MigrateOptions) (*BatchResult, error) {
+	cache.logger.Info(fmt.Sprintf("Migrating prefix=%v to bucket=%v", migrateOpts.Prefix, dst.bucketName))
+	objects, err := cache.List(migrateOpts.Prefix, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return nil, err
+	}
+	existing := map[string]minio.ObjectInfo{}
+	if !migrateOpts.Force {
+		dstObjects, err := dst.List(migrateOpts.Prefix, minio.ListObjectsOptions{Recursive: true})
+		if nil != err {
+			return nil, err
+		}
+		for _, info := range dstObjects {
+			existing[info.Key] = info
+		}
+	}
+
+	keys := []string{}
+	for _, info := range objects {
+		if current, ok := existing[info.Key]; ok && sameObject(info, current) {
+			continue
+		}
+		keys = append(keys, info.Key)
+	}
+	cache.logger.Info(fmt.Sprintf("Copying %v of %v objects", len(keys), len(objects)))
+
+	result := cache.runBatch(cache.ctx, keys, migrateOpts.Batch, func(key string) (int64, error) {
+		return cache.migrateObject(dst, key)
+	})
+	return result, cache.batchErr(result)
+}
+
+// Sync makes the objects under prefix in dst match this Cache, copying missing and changed objects like Migrate
+// and, with Delete set, removing objects only found in dst. The result holds one item per copied or deleted key,
+// the error is an *ErrBatchFailed when any key failed.
+func (cache *Cache) Sync(dst *Cache, prefix string, syncOpts SyncOptions) (*BatchResult, error) {
+	cache.logger.Info(fmt.Sprintf("Syncing prefix=%v to bucket=%v", prefix, dst.bucketName))
+	objects, err := cache.List(prefix, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return nil, err
+	}
+	dstObjects, err := dst.List(prefix, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return nil, err
+	}
+
+	remaining := map[string]minio.ObjectInfo{}
+	for _, info := range dstObjects {
+		remaining[info.Key] = info
+	}
+	keys := []string{}
+	for _, info := range objects {
+		current, ok := remaining[info.Key]
+		delete(remaining, info.Key)
+		if !ok || !sameObject(info, current) {
+			keys = append(keys, info.Key)
+		}
+	}
+	copies := len(keys)
+	if syncOpts.Delete {
+		extra := []string{}
+		for key := range remaining {
+			extra = append(extra, key)
+		}
+		sort.Strings(extra)
+		keys = append(keys, extra...)
+	}
+	cache.logger.Info(fmt.Sprintf("Copying %v and deleting %v objects", copies, len(keys)-copies))
+
+	result := cache.runBatch(cache.ctx, keys, syncOpts.Batch, func(key string) (int64, error) {
+		if info, ok := remaining[key]; ok {
+			if err := dst.DeleteData(key, minio.RemoveObjectOptions{}); nil != err {
+				return 0, err
+			}
+			return info.Size, nil
+		}
+		return cache.migrateObject(dst, key)
+	})
+	return result, cache.batchErr(result)
+}
+
+// migrateObject streams a single object to dst and verifies the copy
+func (cache *Cache) migrateObject(dst *Cache, key string) (int64, error) {
+	srcKey, dstKey := cache.objectKey(key), dst.objectKey(key)
+	if err := cache.authorize(AuthGet, srcKey, nil); nil != err {
+		return 0, err
+	}
+	obj, err := cache.backend.Get(cache.ctx, srcKey, minio.GetObjectOptions{})
+	if nil != err {
+		return 0, errors.Wrap(err, fmt.Sprintf("Failed to get %v", key))
+	}
+	defer obj.Close()
+	info, err := obj.Stat()
+	if nil != err {
+		return 0, errors.Wrap(err, fmt.Sprintf("Failed to stat %v", key))
+	}
+
+	tags, err := cache.objectTags(srcKey, info)
+	if nil != err {
+		return 0, err
+	}
+
+	hash := md5.New()
+	opts := HeadersOf(&info).Apply(minio.PutObjectOptions{
+		ContentType:  info.ContentType,
+		UserMetadata: info.UserMetadata,
+		UserTags:     tags,
+		StorageClass: storageClassOf(info),
+	})
+	if err := dst.authorize(AuthPut, dstKey, opts.UserMetadata); nil != err {
+		return 0, err
+	}
+	uploadInfo, err := dst.backend.Put(dst.ctx, dstKey, io.TeeReader(obj, hash), info.Size, opts)
+	if nil != err {
+		return 0, errors.Wrap(err, fmt.Sprintf("Failed to copy %v", key))
+	}
+	dst.invalidateTiers(dstKey)
+
+	if uploadInfo.Size != info.Size {
+		return 0, &ContentMismatchError{Path: key, Field: "size", Expected: fmt.Sprint(info.Size), Actual: fmt.Sprint(uploadInfo.Size)}
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	for _, etag := range []string{info.ETag, strings.Trim(uploadInfo.ETag, "\"")} {
+		if !strings.Contains(etag, "-") && etag != checksum {
+			return 0, &ContentMismatchError{Path: key, Field: "etag", Expected: checksum, Actual: etag}
+		}
+	}
+	return info.Size, nil
+}
+
+// CheckCutover compares the objects under prefix with dst, reporting any divergence that
+// must be resolved before readers and writers are switched over
+func (cache *Cache) CheckCutover(dst *Cache, prefix string) (*Divergence, error) {
+	objects, err := cache.List(prefix, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return nil, err
+	}
+	dstObjects, err := dst.List(prefix, minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return nil, err
+	}
+
+	remaining := map[string]minio.ObjectInfo{}
+	for _, info := range dstObjects {
+		remaining[info.Key] = info
+	}
+	output := &Divergence{Missing: []string{}, Extra: []string{}, Changed: []string{}}
+	for _, info := range objects {
+		current, ok := remaining[info.Key]
+		delete(remaining, info.Key)
+		if !ok {
+			output.Missing = append(output.Missing, info.Key)
+		} else if !sameObject(info, current) {
+			output.Changed = append(output.Changed, info.Key)
+		}
+	}
+	for key := range remaining {
+		output.Extra = append(output.Extra, key)
+	}
+	sort.Strings(output.Extra)
+
+	cache.logger.Info(fmt.Sprintf("Cutover check of prefix=%v: %v missing, %v extra, %v changed",
+		prefix, len(output.Missing), len(output.Extra), len(output.Changed)))
+	return output, nil
+}
+
+// sameObject compares two listings by size and, when both are plain MD5 ETags, by ETag.
+// Multipart ETags depend on the part size, so only the size is compared for them.
+func sameObject(a, b minio.ObjectInfo) bool {
+	if a.Size != b.Size {
+		return false
+	}
+	if strings.Contains(a.ETag, "-") || strings.Contains(b.ETag, "-") {
+		return true
+	}
+	return a.ETag == b.ETag
+}
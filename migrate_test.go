@@ -0,0 +1,112 @@
+package minioproto
+
+import (
+	"context"
+	"github.com/minio/minio-go/v7"
+	"reflect"
+	"testing"
+)
+
+func TestDualWriteTenants(t *testing.T) {
+	src, _ := newTestCache(t)
+	dst, dstBackend := newTestCache(t)
+	view := src.With(CallTenant("a"))
+	view.StartDualWrite(dst.With(CallTenant("b")))
+
+	if _, err := view.WriteData("key", []byte("hello"), minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if value := getString(t, dstBackend, "b/key", minio.GetObjectOptions{}); "hello" != value {
+		t.Fatalf("expected the write mirrored to b/key, got %v", value)
+	}
+
+	if err := view.DeleteData("key", minio.RemoveObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := dstBackend.Stat(context.Background(), "b/key", minio.StatObjectOptions{}); nil == err {
+		t.Fatal("expected the delete mirrored to b/key")
+	}
+
+	view.StopDualWrite()
+	if _, err := view.WriteData("other", []byte("hello"), minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := dstBackend.Stat(context.Background(), "b/other", minio.StatObjectOptions{}); nil == err {
+		t.Fatal("expected no mirroring after StopDualWrite")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	src, srcBackend := newTestCache(t)
+	dst, dstBackend := newTestCache(t)
+	putString(t, srcBackend, "data/a", "a", minio.PutObjectOptions{ContentType: "text/plain", UserMetadata: map[string]string{"Owner": "me"}})
+	putString(t, srcBackend, "data/b", "b", minio.PutObjectOptions{})
+	putString(t, srcBackend, "data/c", "c", minio.PutObjectOptions{})
+	putString(t, dstBackend, "data/b", "stale", minio.PutObjectOptions{})
+	putString(t, dstBackend, "data/c", "c", minio.PutObjectOptions{})
+	putString(t, dstBackend, "data/d", "d", minio.PutObjectOptions{})
+
+	divergence, err := src.CheckCutover(dst, "data/")
+	if nil != err {
+		t.Fatal(err)
+	}
+	expected := &Divergence{Missing: []string{"data/a"}, Extra: []string{"data/d"}, Changed: []string{"data/b"}}
+	if !reflect.DeepEqual(expected, divergence) {
+		t.Fatalf("expected %v, got %v", expected, divergence)
+	}
+
+	result, err := src.Migrate(dst, MigrateOptions{Prefix: "data/"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if succeeded := result.Succeeded(); !reflect.DeepEqual([]string{"data/a", "data/b"}, succeeded) {
+		t.Fatalf("expected the unchanged key to be skipped, got %v", succeeded)
+	}
+	if value := getString(t, dstBackend, "data/b", minio.GetObjectOptions{}); "b" != value {
+		t.Fatalf("expected data/b to be replaced, got %v", value)
+	}
+	info, err := dstBackend.Stat(context.Background(), "data/a", minio.StatObjectOptions{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "text/plain" != info.ContentType || "me" != metadataValue(info.UserMetadata, "Owner") {
+		t.Fatalf("expected the content type and metadata to be copied, got %v %v", info.ContentType, info.UserMetadata)
+	}
+
+	if divergence, err = src.CheckCutover(dst, "data/"); nil != err {
+		t.Fatal(err)
+	}
+	if divergence.Converged() || !reflect.DeepEqual([]string{"data/d"}, divergence.Extra) {
+		t.Fatalf("expected only the extra key to remain, got %v", divergence)
+	}
+
+	result, err = src.Migrate(dst, MigrateOptions{Prefix: "data/", Force: true})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 3 != len(result.Succeeded()) {
+		t.Fatalf("expected Force to copy every key, got %v", result.Succeeded())
+	}
+}
+
+func TestMigrateTenants(t *testing.T) {
+	src, _ := newTestCache(t)
+	dst, dstBackend := newTestCache(t)
+	srcView, dstView := src.With(CallTenant("a")), dst.With(CallTenant("b"))
+	if _, err := srcView.WriteData("key", []byte("hello"), minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := srcView.Migrate(dstView, MigrateOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if value := getString(t, dstBackend, "b/key", minio.GetObjectOptions{}); "hello" != value {
+		t.Fatalf("expected the object migrated to b/key, got %v", value)
+	}
+	divergence, err := srcView.CheckCutover(dstView, "")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !divergence.Converged() {
+		t.Fatalf("expected the tenants to have converged, got %v", divergence)
+	}
+}
@@ -0,0 +1,96 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// cleanupAttempts is how many times removing an incomplete upload is tried before giving up
+const cleanupAttempts = 3
+
+// cleanupConfig is the startup cleanup configured with WithIncompleteUploadCleanup
+type cleanupConfig struct {
+	prefix    string
+	olderThan time.Duration
+}
+
+// WithIncompleteUploadCleanup runs CleanupIncompleteUploads when the Cache is created,
+// failures are logged as warnings and do not prevent the Cache from being returned
+func WithIncompleteUploadCleanup(prefix string, olderThan time.Duration) Option {
+	return func(cache *Cache) {
+		cache.cleanup = &cleanupConfig{prefix: prefix, olderThan: olderThan}
+	}
+}
+
+// CleanupIncompleteUploads aborts multipart uploads under prefix that were initiated more than olderThan ago,
+// e.g. left behind by crashed jobs, and returns the number of objects cleaned up.
+// Aborting is per object, so objects that also have a more recent upload in progress are skipped.
+func (cache *Cache) CleanupIncompleteUploads(prefix string, olderThan time.Duration) (int, error) {
+	cache.logger.Info(fmt.Sprintf("Cleaning up incomplete uploads older than %v under prefix=%v", olderThan, prefix))
+	client, err := cache.minioClient()
+	if nil != err {
+		cache.logger.Error(err.Error())
+		return 0, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	listPrefix := cache.objectKey(prefix)
+	if err := cache.authorize(AuthList, listPrefix, nil); nil != err {
+		return 0, err
+	}
+
+	stale := map[string]bool{}
+	keys := []string{}
+	for upload := range client.ListIncompleteUploads(cache.ctx, cache.bucketName, listPrefix, true) {
+		if nil != upload.Err {
+			err := errors.Wrap(upload.Err, "Failed to list incomplete uploads")
+			cache.logger.Error(err.Error())
+			return 0, err
+		}
+		old, seen := stale[upload.Key]
+		if !seen {
+			keys = append(keys, upload.Key)
+			old = true
+		}
+		stale[upload.Key] = old && upload.Initiated.Before(cutoff)
+	}
+
+	count := 0
+	for _, key := range keys {
+		if !stale[key] {
+			cache.logger.Info(fmt.Sprintf("Skipping path=%v with a recent incomplete upload", key))
+			continue
+		}
+		if err := cache.removeIncompleteUpload(key); nil != err {
+			return count, err
+		}
+		count++
+	}
+
+	cache.logger.Info(fmt.Sprintf("Successfully cleaned up %v incomplete uploads", count))
+	return count, nil
+}
+
+// removeIncompleteUpload aborts the incomplete uploads of the object key path, retrying with backoff
+func (cache *Cache) removeIncompleteUpload(path string) error {
+	if err := cache.authorize(AuthDelete, path, nil); nil != err {
+		return err
+	}
+	var err error
+	for attempt := 0; attempt < cleanupAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-cache.ctx.Done():
+				return cache.ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+		if err = cache.client.RemoveIncompleteUpload(cache.ctx, cache.bucketName, path); nil == err {
+			return nil
+		}
+		cache.logger.Warn(fmt.Sprintf("Failed to remove incomplete upload path=%v attempt=%v: %v", path, attempt+1, err.Error()))
+	}
+	err = errors.Wrap(err, fmt.Sprintf("Failed to remove incomplete upload %v", path))
+	cache.logger.Error(err.Error())
+	return err
+}
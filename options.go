@@ -0,0 +1,4 @@
+package minioproto
+
+// Option configures optional behaviour on a Cache when it is created
+type Option func(*Cache)
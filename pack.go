@@ -0,0 +1,242 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"sort"
+	"sync"
+)
+
+// ErrPackedEncryption is returned by the packed helpers when encryption, signatures, compression or a profile
+// apply to the segments, since records are read with ranged reads of the stored bytes
+var ErrPackedEncryption = errors.New("Packed records are not supported with encryption, signatures, compression or profiles")
+
+// ErrPackedKeyNotFound is returned by GetPacked when no segment contains the logical key
+var ErrPackedKeyNotFound = errors.New("Packed key not found")
+
+const packSegmentContentType = "application/octet-stream"
+const defaultPackSegmentSize = 8 * 1024 * 1024
+
+// packEntry locates a record inside a segment
+type packEntry struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+// packIndex maps the logical keys of a single segment to their byte ranges
+type packIndex struct {
+	Segment string               `json:"segment"`
+	Entries map[string]packEntry `json:"entries"`
+}
+
+// packLocation is the resolved position of a logical key
+type packLocation struct {
+	segment string
+	entry   packEntry
+}
+
+// packRegistry holds the packState of every pack root read by a Cache
+type packRegistry struct {
+	sync.Mutex
+	packs map[string]*packState
+}
+
+// packState is the in-memory index of every segment under a pack root
+type packState struct {
+	sync.Mutex
+	loaded    bool
+	locations map[string]packLocation
+}
+
+// PackWriter buffers small records and flushes them to minio as segment objects with an index
+type PackWriter struct {
+	cache       *Cache
+	root        string
+	segmentSize int
+	id          string
+	buf         []byte
+	index       packIndex
+}
+
+// NewPackWriter creates a writer that packs records under root, flushing whenever a segment
+// reaches segmentSize bytes (0 uses an 8 MiB default)
+func (cache *Cache) NewPackWriter(root string, segmentSize int) *PackWriter {
+	if segmentSize <= 0 {
+		segmentSize = defaultPackSegmentSize
+	}
+	writer := &PackWriter{
+		cache:       cache,
+		root:        root,
+		segmentSize: segmentSize,
+	}
+	writer.reset()
+	return writer
+}
+
+// Append adds a record to the current segment, flushing it when full
+func (writer *PackWriter) Append(key string, data []byte) error {
+	writer.index.Entries[key] = packEntry{
+		Offset: int64(len(writer.buf)),
+		Length: int64(len(data)),
+	}
+	writer.buf = append(writer.buf, data...)
+
+	if len(writer.buf) >= writer.segmentSize {
+		return writer.Flush()
+	}
+	return nil
+}
+
+// Flush writes the buffered records as a segment followed by its index
+func (writer *PackWriter) Flush() error {
+	if 0 == len(writer.index.Entries) {
+		return nil
+	}
+	cache := writer.cache
+	if cache.sealsPayload(writer.index.Segment) {
+		return ErrPackedEncryption
+	}
+
+	opts := minio.PutObjectOptions{ContentType: packSegmentContentType}
+	if _, err := cache.WriteData(writer.index.Segment, writer.buf, opts); nil != err {
+		err = errors.Wrap(err, "Failed to write pack segment")
+		cache.logger.Error(err.Error())
+		return err
+	}
+
+	// The index is written last so readers never see entries for a missing segment
+	indexPath := fmt.Sprintf("%v/index/%v.json", writer.root, writer.id)
+	if _, err := cache.PutJSON(indexPath, writer.index, minio.PutObjectOptions{}); nil != err {
+		err = errors.Wrap(err, "Failed to write pack index")
+		cache.logger.Error(err.Error())
+		return err
+	}
+
+	cache.packState(writer.root).merge(writer.index)
+	writer.reset()
+	return nil
+}
+
+// Close flushes any buffered records
+func (writer *PackWriter) Close() error {
+	return writer.Flush()
+}
+
+func (writer *PackWriter) reset() {
+	writer.id = sortableID()
+	writer.buf = make([]byte, 0, writer.segmentSize)
+	writer.index = packIndex{
+		Segment: fmt.Sprintf("%v/segments/%v.seg", writer.root, writer.id),
+		Entries: map[string]packEntry{},
+	}
+}
+
+// PutPacked writes the records under root as a single packed segment
+func (cache *Cache) PutPacked(root string, records map[string][]byte) error {
+	size := 0
+	for _, data := range records {
+		size += len(data)
+	}
+	writer := cache.NewPackWriter(root, size+1)
+
+	// Sorted so that identical inputs produce identical segments
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := writer.Append(key, records[key]); nil != err {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// GetPacked reads a single record by logical key with a ranged read of its segment
+func (cache *Cache) GetPacked(root, key string) ([]byte, error) {
+	state := cache.packState(root)
+	location, ok, err := state.lookup(cache, root, key)
+	if nil != err {
+		return nil, err
+	}
+	if !ok {
+		err = errors.Wrap(ErrPackedKeyNotFound, fmt.Sprintf("Failed to find key=%v under root=%v", key, root))
+		cache.logger.Info(err.Error())
+		return nil, err
+	}
+	if cache.sealsPayload(location.segment) {
+		return nil, ErrPackedEncryption
+	}
+	if 0 == location.entry.Length {
+		return []byte{}, nil
+	}
+
+	opts := minio.GetObjectOptions{}
+	end := location.entry.Offset + location.entry.Length - 1
+	if err := opts.SetRange(location.entry.Offset, end); nil != err {
+		return nil, err
+	}
+	return cache.ReadData(location.segment, opts)
+}
+
+// RefreshPacked discards the in-memory index for root so it is reloaded on the next GetPacked
+func (cache *Cache) RefreshPacked(root string) {
+	state := cache.packState(root)
+	state.Lock()
+	defer state.Unlock()
+	state.loaded = false
+	state.locations = map[string]packLocation{}
+}
+
+// packState returns the index of root, keyed by object key so tenant views don't share indexes
+func (cache *Cache) packState(root string) *packState {
+	key := cache.objectKey(root)
+	cache.packs.Lock()
+	defer cache.packs.Unlock()
+	state, ok := cache.packs.packs[key]
+	if !ok {
+		state = &packState{locations: map[string]packLocation{}}
+		cache.packs.packs[key] = state
+	}
+	return state
+}
+
+// lookup resolves a key, loading every index object under root on first use
+func (state *packState) lookup(cache *Cache, root, key string) (packLocation, bool, error) {
+	state.Lock()
+	defer state.Unlock()
+
+	if !state.loaded {
+		objects, err := cache.List(root+"/index/", minio.ListObjectsOptions{Recursive: true})
+		if nil != err {
+			return packLocation{}, false, err
+		}
+		// Index names sort by creation time, so later segments override earlier ones
+		sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+		for _, info := range objects {
+			index := packIndex{}
+			if err := cache.GetJSON(info.Key, &index, minio.GetObjectOptions{}); nil != err {
+				return packLocation{}, false, err
+			}
+			state.add(index)
+		}
+		state.loaded = true
+	}
+
+	location, ok := state.locations[key]
+	return location, ok, nil
+}
+
+func (state *packState) merge(index packIndex) {
+	state.Lock()
+	defer state.Unlock()
+	state.add(index)
+}
+
+func (state *packState) add(index packIndex) {
+	for key, entry := range index.Entries {
+		state.locations[key] = packLocation{segment: index.Segment, entry: entry}
+	}
+}
@@ -0,0 +1,62 @@
+package minioproto
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestPacked(t *testing.T) {
+	cache, _ := newTestCache(t)
+	records := map[string][]byte{"a": []byte("alpha"), "b": []byte("bravo"), "empty": {}}
+	if err := cache.PutPacked("packs", records); nil != err {
+		t.Fatal(err)
+	}
+	writer := cache.NewPackWriter("packs", 4)
+	if err := writer.Append("a", []byte("replaced")); nil != err {
+		t.Fatal(err)
+	}
+	if err := writer.Append("c", []byte("charlie")); nil != err {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); nil != err {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"a": "replaced", "b": "bravo", "c": "charlie", "empty": ""}
+	// A fresh index is loaded from the index objects, later segments override earlier ones
+	cache.RefreshPacked("packs")
+	for key, value := range expected {
+		output, err := cache.GetPacked("packs", key)
+		if nil != err {
+			t.Fatalf("GetPacked of %v failed: %v", key, err)
+		}
+		if !bytes.Equal([]byte(value), output) {
+			t.Fatalf("expected %q for %v, got %q", value, key, output)
+		}
+	}
+	if _, err := cache.GetPacked("packs", "missing"); ErrPackedKeyNotFound != errors.Cause(err) {
+		t.Fatalf("expected ErrPackedKeyNotFound, got %v", err)
+	}
+}
+
+func TestPackedTenants(t *testing.T) {
+	cache, _ := newTestCache(t)
+	acme, other := cache.With(CallTenant("acme")), cache.With(CallTenant("other"))
+	if err := acme.PutPacked("packs", map[string][]byte{"a": []byte("acme")}); nil != err {
+		t.Fatal(err)
+	}
+	if output, err := acme.GetPacked("packs", "a"); nil != err || "acme" != string(output) {
+		t.Fatalf("expected acme, got %q %v", output, err)
+	}
+	if _, err := other.GetPacked("packs", "a"); ErrPackedKeyNotFound != errors.Cause(err) {
+		t.Fatalf("expected another tenant not to see the record, got %v", err)
+	}
+}
+
+func TestPackedSealed(t *testing.T) {
+	cache, _ := newTestCache(t, WithSignatures(HMACSigner{KeyID: "k", Secret: []byte("secret")}))
+	if err := cache.PutPacked("packs", map[string][]byte{"a": []byte("alpha")}); ErrPackedEncryption != err {
+		t.Fatalf("expected ErrPackedEncryption, got %v", err)
+	}
+}
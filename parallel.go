@@ -0,0 +1,30 @@
+package minioproto
+
+import (
+	"sync"
+)
+
+const defaultConcurrency = 4
+
+// parallel calls fn for every index in [0, count) using at most concurrency goroutines
+func parallel(concurrency, count int, fn func(index int)) {
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				fn(index)
+			}
+		}()
+	}
+	for index := 0; index < count; index++ {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,141 @@
+package minioproto
+
+import (
+	"context"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrPartialResult is returned alongside the results collected before the context of a
+// ListPartial, GetManyPartial or WalkPartial call was done
+type ErrPartialResult struct {
+	// Err is the context error, context.DeadlineExceeded or context.Canceled
+	Err error
+	// Outstanding lists the keys that were not read, for listings it holds the prefix being listed
+	Outstanding []string
+}
+
+// Error describes the partial result
+func (err *ErrPartialResult) Error() string {
+	return fmt.Sprintf("Partial result with %v outstanding: %v", len(err.Outstanding), err.Err)
+}
+
+// Unwrap returns the context error
+func (err *ErrPartialResult) Unwrap() error {
+	return err.Err
+}
+
+// ListPartial is List bounded by ctx, when ctx is done it returns the objects listed so far with an *ErrPartialResult
+func (cache *Cache) ListPartial(ctx context.Context, prefix string, opts minio.ListObjectsOptions) ([]minio.ObjectInfo, error) {
+	output := []minio.ObjectInfo{}
+	err := cache.walk(ctx, prefix, opts, func(info minio.ObjectInfo) error {
+		output = append(output, info)
+		return nil
+	})
+	return output, err
+}
+
+// Walk calls fn for every object under prefix in listing order, stopping at the first error
+func (cache *Cache) Walk(prefix string, fn func(info minio.ObjectInfo) error) error {
+	return cache.walk(cache.ctx, prefix, minio.ListObjectsOptions{Recursive: true}, fn)
+}
+
+// WalkPartial is Walk bounded by ctx, when ctx is done it stops with an *ErrPartialResult
+func (cache *Cache) WalkPartial(ctx context.Context, prefix string, fn func(info minio.ObjectInfo) error) error {
+	return cache.walk(ctx, prefix, minio.ListObjectsOptions{Recursive: true}, fn)
+}
+
+// walk lists prefix with ctx and calls fn for every object
+func (cache *Cache) walk(ctx context.Context, prefix string, opts minio.ListObjectsOptions, fn func(info minio.ObjectInfo) error) error {
+	prefix = cache.objectKey(prefix)
+	cache.logger.Info(fmt.Sprintf("Walking prefix=%v", prefix))
+	if err := cache.authorize(AuthList, prefix, nil); nil != err {
+		return err
+	}
+	opts.Prefix = prefix
+	if err := cache.injectFault(AuthList, prefix); nil != err {
+		err = errors.Wrap(err, "Failed to list objects")
+		cache.logger.Error(err.Error())
+		return err
+	}
+
+	listCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	count := 0
+	for info := range cache.backend.List(listCtx, opts) {
+		if nil != ctx.Err() {
+			break
+		}
+		if nil != info.Err {
+			err := errors.Wrap(info.Err, "Failed to list objects")
+			cache.logger.Error(err.Error())
+			return err
+		}
+		info.Key = cache.relativeKey(info.Key)
+		if err := fn(info); nil != err {
+			return err
+		}
+		count++
+	}
+
+	if err := ctx.Err(); nil != err {
+		cache.logger.Warn(fmt.Sprintf("Returning partial listing of prefix=%v after %v objects: %v", prefix, count, err.Error()))
+		return &ErrPartialResult{Err: err, Outstanding: []string{prefix}}
+	}
+	cache.logger.Info(fmt.Sprintf("Successfully walked objects: %v", count))
+	return nil
+}
+
+// GetMany reads the objects at paths concurrently with ReadData, keyed by path.
+// The result reports every path and the error is an *ErrBatchFailed when any read failed.
+func (cache *Cache) GetMany(paths []string, opts minio.GetObjectOptions) (map[string][]byte, *BatchResult, error) {
+	return cache.getMany(cache.ctx, paths, opts)
+}
+
+// GetManyPartial is GetMany bounded by ctx, when ctx is done it returns the objects read so far with an
+// *ErrPartialResult listing the outstanding paths. Reads in flight are cancelled with ctx.
+func (cache *Cache) GetManyPartial(ctx context.Context, paths []string, opts minio.GetObjectOptions) (map[string][]byte, *BatchResult, error) {
+	return cache.getMany(ctx, paths, opts)
+}
+
+// getMany reads paths until they are all read or ctx is done, the reads are bound to ctx
+func (cache *Cache) getMany(ctx context.Context, paths []string, opts minio.GetObjectOptions) (map[string][]byte, *BatchResult, error) {
+	cache.logger.Info(fmt.Sprintf("Reading %v objects", len(paths)))
+	reader := cache.With(CallContext(ctx))
+	mutex := sync.Mutex{}
+	output := make(map[string][]byte, len(paths))
+	result := cache.runBatch(ctx, paths, BatchOptions{}, func(path string) (int64, error) {
+		data, err := reader.ReadData(path, opts)
+		if nil != err {
+			return 0, err
+		}
+		mutex.Lock()
+		output[path] = data
+		mutex.Unlock()
+		return int64(len(data)), nil
+	})
+
+	// Copy the results so reads finishing in the background do not race with the caller
+	mutex.Lock()
+	defer mutex.Unlock()
+	partial := make(map[string][]byte, len(output))
+	outstanding := []string{}
+	for _, item := range result.Items {
+		switch item.Status {
+		case BatchOK:
+			partial[item.Key] = output[item.Key]
+		case BatchPending:
+			outstanding = append(outstanding, item.Key)
+		}
+	}
+	if 0 != len(outstanding) && nil != ctx.Err() {
+		sort.Strings(outstanding)
+		cache.logger.Warn(fmt.Sprintf("Returning partial read with outstanding paths: %v", strings.Join(outstanding, ",")))
+		return partial, result, &ErrPartialResult{Err: ctx.Err(), Outstanding: outstanding}
+	}
+	return partial, result, cache.batchErr(result)
+}
@@ -0,0 +1,55 @@
+package minioproto
+
+import (
+	"context"
+	"errors"
+	"github.com/minio/minio-go/v7"
+	"go.uber.org/zap"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// blockingBackend blocks Gets of one key until their context is done
+type blockingBackend struct {
+	*fileBackend
+	key       string
+	cancelled chan struct{}
+}
+
+func (backend *blockingBackend) Get(ctx context.Context, key string, opts minio.GetObjectOptions) (BackendObject, error) {
+	if backend.key == key {
+		<-ctx.Done()
+		close(backend.cancelled)
+		return nil, ctx.Err()
+	}
+	return backend.fileBackend.Get(ctx, key, opts)
+}
+
+func TestGetManyPartial(t *testing.T) {
+	files, _ := newTestFileBackend(t)
+	putString(t, files, "fast", "fast", minio.PutObjectOptions{})
+	putString(t, files, "slow", "slow", minio.PutObjectOptions{})
+	backend := &blockingBackend{fileBackend: files, key: "slow", cancelled: make(chan struct{})}
+	cache, err := NewWithBackend(context.Background(), zap.NewNop(), backend)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	output, _, err := cache.GetManyPartial(ctx, []string{"fast", "slow"}, minio.GetObjectOptions{})
+	partial := &ErrPartialResult{}
+	if !errors.As(err, &partial) || !reflect.DeepEqual([]string{"slow"}, partial.Outstanding) {
+		t.Fatalf("expected slow to be outstanding, got %v", err)
+	}
+	if !reflect.DeepEqual(map[string][]byte{"fast": []byte("fast")}, output) {
+		t.Fatalf("expected the fast object, got %v", output)
+	}
+
+	select {
+	case <-backend.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("expected the read in flight to be cancelled")
+	}
+}
@@ -0,0 +1,168 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"google.golang.org/protobuf/proto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpandTimeTemplate replaces the {yyyy}, {MM}, {dd}, {HH}, {mm} and {ss} placeholders in template
+// with the UTC components of t, e.g. "events/{yyyy}/{MM}/{dd}/{HH}/batch" -> "events/2024/01/31/09/batch"
+func ExpandTimeTemplate(t time.Time, template string) string {
+	t = t.UTC()
+	replacer := strings.NewReplacer(
+		"{yyyy}", fmt.Sprintf("%04d", t.Year()),
+		"{MM}", fmt.Sprintf("%02d", int(t.Month())),
+		"{dd}", fmt.Sprintf("%02d", t.Day()),
+		"{HH}", fmt.Sprintf("%02d", t.Hour()),
+		"{mm}", fmt.Sprintf("%02d", t.Minute()),
+		"{ss}", fmt.Sprintf("%02d", t.Second()),
+	)
+	return replacer.Replace(template)
+}
+
+// PutJSONAt writes a JSON file to the key produced by expanding template for t
+func (cache *Cache) PutJSONAt(t time.Time, template string, data interface{}, opts minio.PutObjectOptions) (*WriteResult, error) {
+	return cache.PutJSON(ExpandTimeTemplate(t, template), data, opts)
+}
+
+// PutPROTOAt writes a PROTO file to the key produced by expanding template for t
+func (cache *Cache) PutPROTOAt(t time.Time, template string, data proto.Message, marshalOpts *proto.MarshalOptions, opts minio.PutObjectOptions) (*WriteResult, error) {
+	return cache.PutPROTO(ExpandTimeTemplate(t, template), data, marshalOpts, opts)
+}
+
+// ListBetween returns the objects of a "<prefix>/yyyy/MM/dd[/HH]/..." layout whose partition
+// falls within [from, to). It walks the year, month and day directories, skipping those outside the range
+// and listing those entirely inside it in one call, so only the days at either end are listed one by one.
+func (cache *Cache) ListBetween(prefix string, from, to time.Time) ([]minio.ObjectInfo, error) {
+	from, to = from.UTC(), to.UTC()
+	output := []minio.ObjectInfo{}
+	if from.Before(to) {
+		if err := cache.listPartitions(prefix, strings.TrimSuffix(prefix, "/")+"/", []int{}, from, to, &output); nil != err {
+			return nil, err
+		}
+	}
+
+	cache.logger.Info(fmt.Sprintf("Found %v objects under prefix=%v between %v and %v", len(output), prefix, from, to))
+	return output, nil
+}
+
+// listPartitions appends the objects within [from, to) of the partition directories below dir,
+// whose year, month and day so far are given by values
+func (cache *Cache) listPartitions(prefix, dir string, values []int, from, to time.Time, output *[]minio.ObjectInfo) error {
+	entries, err := cache.List(dir, minio.ListObjectsOptions{})
+	if nil != err {
+		return err
+	}
+	widths := []int{4, 2, 2}
+	for _, entry := range entries {
+		segment := strings.TrimSuffix(strings.TrimPrefix(entry.Key, dir), "/")
+		if !strings.HasSuffix(entry.Key, "/") || len(segment) != widths[len(values)] {
+			continue
+		}
+		value, err := strconv.Atoi(segment)
+		if nil != err {
+			continue
+		}
+		current := append(append([]int{}, values...), value)
+		start, end := partitionRange(current)
+		if !start.Before(to) || !end.After(from) {
+			continue
+		}
+		if len(current) < len(widths) && (start.Before(from) || end.After(to)) {
+			// Only part of the directory is in range, descend into it
+			if err := cache.listPartitions(prefix, entry.Key, current, from, to, output); nil != err {
+				return err
+			}
+			continue
+		}
+
+		objects, err := cache.List(entry.Key, minio.ListObjectsOptions{Recursive: true})
+		if nil != err {
+			return err
+		}
+		for _, info := range objects {
+			start, end, ok := parsePartition(prefix, info.Key)
+			if ok && start.Before(to) && end.After(from) {
+				*output = append(*output, info)
+			}
+		}
+	}
+	return nil
+}
+
+// partitionRange is the time range of a year, month or day directory given its values
+func partitionRange(values []int) (time.Time, time.Time) {
+	switch len(values) {
+	case 1:
+		start := time.Date(values[0], time.January, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(1, 0, 0)
+	case 2:
+		start := time.Date(values[0], time.Month(values[1]), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		start := time.Date(values[0], time.Month(values[1]), values[2], 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1)
+	}
+}
+
+// DeletePartitionsBefore removes the objects of a "<prefix>/yyyy/MM/dd[/HH]/..." layout whose
+// partition ends before cutoff, returning the number of objects removed
+func (cache *Cache) DeletePartitionsBefore(prefix string, cutoff time.Time) (int, error) {
+	objects, err := cache.List(strings.TrimSuffix(prefix, "/")+"/", minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return 0, err
+	}
+
+	removed := 0
+	for _, info := range objects {
+		_, end, ok := parsePartition(prefix, info.Key)
+		if !ok || end.After(cutoff) {
+			continue
+		}
+		if err := cache.DeleteData(info.Key, minio.RemoveObjectOptions{}); nil != err {
+			return removed, err
+		}
+		removed++
+	}
+
+	cache.logger.Info(fmt.Sprintf("Removed %v partitioned objects under prefix=%v before %v", removed, prefix, cutoff))
+	return removed, nil
+}
+
+// parsePartition extracts the time range of the partition a key belongs to, from
+// "yyyy/MM/dd" segments with an optional "HH" segment following the prefix
+func parsePartition(prefix, key string) (time.Time, time.Time, bool) {
+	rest := strings.TrimPrefix(key, strings.TrimSuffix(prefix, "/")+"/")
+	if rest == key {
+		return time.Time{}, time.Time{}, false
+	}
+
+	segments := strings.Split(rest, "/")
+	values := []int{}
+	widths := []int{4, 2, 2, 2}
+	for i, width := range widths {
+		// The last segment is the object name, never a partition
+		if i >= len(segments)-1 || len(segments[i]) != width {
+			break
+		}
+		value, err := strconv.Atoi(segments[i])
+		if nil != err {
+			break
+		}
+		values = append(values, value)
+	}
+	if len(values) < 3 {
+		return time.Time{}, time.Time{}, false
+	}
+
+	start := time.Date(values[0], time.Month(values[1]), values[2], 0, 0, 0, 0, time.UTC)
+	if 4 == len(values) {
+		start = start.Add(time.Duration(values[3]) * time.Hour)
+		return start, start.Add(time.Hour), true
+	}
+	return start, start.AddDate(0, 0, 1), true
+}
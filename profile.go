@@ -0,0 +1,249 @@
+package minioproto
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// compressionMagic prefixes payloads compressed by a Profile, so they can be read whatever the current profile
+var compressionMagic = []byte("MPZ1")
+
+// compressedEnvelope marks payloads compressed by a Profile in the envelope metadata
+const compressedEnvelope = "compressed"
+
+const expiresAtMetadata = "Expires-At"
+
+// quotaRefresh bounds how long a quota counter is trusted before the prefix is listed again,
+// limiting the drift caused by writers in other processes
+const quotaRefresh = 5 * time.Minute
+
+// ErrExpired is returned when reading an object whose profile TTL has passed
+var ErrExpired = errors.New("Object has expired")
+
+// ErrQuotaExceeded is returned when a write would take a prefix over its profile quota
+var ErrQuotaExceeded = errors.New("Prefix quota exceeded")
+
+// Profile bundles the behavior applied to every operation on keys under Prefix,
+// so policy is configured once instead of at every call site
+type Profile struct {
+	Prefix string
+	// Compress gzips payloads before they are encrypted and written
+	Compress bool
+	// Keys encrypts payloads with a different KeyProvider than WithEncryption
+	Keys KeyProvider
+	// TTL makes reads fail with ErrExpired once the object is older than the TTL, reads of these
+	// objects always go to the bucket since local tiers do not keep the expiry
+	TTL time.Duration
+	// StorageClass is used for writes that do not set one
+	StorageClass string
+	// Validate checks payloads before they are written, e.g. against a JSON schema
+	Validate func(path string, data []byte) error
+	// Quota limits the total bytes stored under Prefix. The usage is counted by each Cache from a listing
+	// of the prefix that is refreshed every few minutes, so writers in other processes are only seen then.
+	// Usage is measured in stored bytes, after compression and encryption.
+	Quota int64
+}
+
+// WithProfiles applies profiles to operations on matching keys, the longest matching prefix wins
+func WithProfiles(profiles ...Profile) Option {
+	return func(cache *Cache) {
+		cache.profiles = append(cache.profiles, profiles...)
+	}
+}
+
+// profileFor returns the profile for path, nil when none matches
+func (cache *Cache) profileFor(path string) *Profile {
+	var match *Profile
+	for i, profile := range cache.profiles {
+		if strings.HasPrefix(path, profile.Prefix) && (nil == match || len(profile.Prefix) > len(match.Prefix)) {
+			match = &cache.profiles[i]
+		}
+	}
+	return match
+}
+
+// keysFor returns the KeyProvider encrypting path, nil when it is not encrypted
+func (cache *Cache) keysFor(path string) KeyProvider {
+	if profile := cache.profileFor(path); nil != profile && nil != profile.Keys {
+		return profile.Keys
+	}
+	return cache.keys
+}
+
+// applyProfile validates and compresses a payload about to be written, the quota is checked by WriteData on the stored bytes
+func (cache *Cache) applyProfile(path string, data []byte, opts minio.PutObjectOptions) ([]byte, minio.PutObjectOptions, error) {
+	profile := cache.profileFor(path)
+	if nil == profile {
+		return data, opts, nil
+	}
+
+	if nil != profile.Validate {
+		if err := profile.Validate(path, data); nil != err {
+			err = errors.Wrap(err, fmt.Sprintf("Failed to validate path=%v", path))
+			cache.logger.Error(err.Error())
+			return nil, opts, err
+		}
+	}
+	if "" == opts.StorageClass {
+		opts.StorageClass = profile.StorageClass
+	}
+	if profile.TTL > 0 {
+		expires := time.Now().Add(profile.TTL).UTC()
+		opts = withUserMetadata(opts, expiresAtMetadata, expires.Format(time.RFC3339))
+	}
+	if profile.Compress {
+		buf := &bytes.Buffer{}
+		buf.Write(compressionMagic)
+		writer := gzip.NewWriter(buf)
+		if _, err := writer.Write(data); nil != err {
+			return nil, opts, err
+		}
+		if err := writer.Close(); nil != err {
+			return nil, opts, err
+		}
+		data = buf.Bytes()
+		opts = withEnvelope(opts, compressedEnvelope)
+	}
+	return data, opts, nil
+}
+
+// quotaUsage holds the running byte counters of the quota prefixes, shared by the views of a Cache
+type quotaUsage struct {
+	sync.Mutex
+	prefixes map[string]*quotaCounter
+}
+
+// quotaCounter is the usage of a quota prefix as of the listing taken at seeded plus later writes
+type quotaCounter struct {
+	bytes  int64
+	seeded time.Time
+}
+
+// checkQuota fails when storing size bytes at the object key path would exceed the quota of its profile,
+// otherwise it returns the change in usage to pass to recordQuota once the write succeeded.
+// Both the profile prefix and path are object keys, and sizes are those of the stored, encoded payloads.
+func (cache *Cache) checkQuota(path string, size int64) (int64, error) {
+	profile := cache.profileFor(path)
+	if nil == profile || profile.Quota <= 0 {
+		return 0, nil
+	}
+
+	// The object being replaced does not count towards the quota
+	var replaced int64
+	if err := cache.authorize(AuthStat, path, nil); nil != err {
+		return 0, err
+	}
+	info, err := cache.backend.Stat(cache.ctx, path, minio.StatObjectOptions{})
+	if nil == err {
+		replaced = info.Size
+	} else if "NoSuchKey" != minio.ToErrorResponse(errors.Cause(err)).Code {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to stat %v", path))
+		cache.logger.Error(err.Error())
+		return 0, err
+	}
+
+	cache.quotas.Lock()
+	defer cache.quotas.Unlock()
+	counter, ok := cache.quotas.prefixes[profile.Prefix]
+	if !ok || time.Since(counter.seeded) > quotaRefresh {
+		used, err := cache.prefixSize(profile.Prefix)
+		if nil != err {
+			return 0, err
+		}
+		counter = &quotaCounter{bytes: used, seeded: time.Now()}
+		cache.quotas.prefixes[profile.Prefix] = counter
+	}
+	total := counter.bytes - replaced + size
+	if total > profile.Quota {
+		err := errors.Wrap(ErrQuotaExceeded, fmt.Sprintf("prefix=%v quota=%v size=%v", profile.Prefix, profile.Quota, total))
+		cache.logger.Error(err.Error())
+		return 0, err
+	}
+	return size - replaced, nil
+}
+
+// recordQuota counts a successful write of the object key path against the quota of its profile
+func (cache *Cache) recordQuota(path string, delta int64) {
+	profile := cache.profileFor(path)
+	if nil == profile || profile.Quota <= 0 {
+		return
+	}
+	cache.quotas.Lock()
+	defer cache.quotas.Unlock()
+	// A missing counter is seeded from a listing that already includes the write
+	if counter, ok := cache.quotas.prefixes[profile.Prefix]; ok {
+		counter.bytes += delta
+	}
+}
+
+// releaseQuota drops the counter of the quota prefix holding a deleted object, so it is listed again
+func (cache *Cache) releaseQuota(path string) {
+	profile := cache.profileFor(path)
+	if nil == profile || profile.Quota <= 0 {
+		return
+	}
+	cache.quotas.Lock()
+	defer cache.quotas.Unlock()
+	delete(cache.quotas.prefixes, profile.Prefix)
+}
+
+// prefixSize sums the sizes of the objects under the object key prefix
+func (cache *Cache) prefixSize(prefix string) (int64, error) {
+	if err := cache.authorize(AuthList, prefix, nil); nil != err {
+		return 0, err
+	}
+	var total int64
+	for info := range cache.backend.List(cache.ctx, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if nil != info.Err {
+			err := errors.Wrap(info.Err, fmt.Sprintf("Failed to list quota prefix=%v", prefix))
+			cache.logger.Error(err.Error())
+			return 0, err
+		}
+		total += info.Size
+	}
+	return total, nil
+}
+
+// checkExpired fails when the object metadata records an expiry that has passed
+func (cache *Cache) checkExpired(path string, metadata map[string]string) error {
+	value := metadata[expiresAtMetadata]
+	if "" == value {
+		return nil
+	}
+	expires, err := time.Parse(time.RFC3339, value)
+	if nil != err || time.Now().Before(expires) {
+		return nil
+	}
+	err = errors.Wrap(ErrExpired, fmt.Sprintf("path=%v expired at %v", path, value))
+	cache.logger.Info(err.Error())
+	return err
+}
+
+// decompressPayload inflates payloads whose envelopes record profile compression, returning other payloads untouched
+func (cache *Cache) decompressPayload(path string, data []byte, envelopes string) ([]byte, error) {
+	if !hasEnvelope(envelopes, compressedEnvelope) {
+		return data, nil
+	}
+	if !bytes.HasPrefix(data, compressionMagic) {
+		err := errors.New(fmt.Sprintf("Failed to decompress path=%v: missing header", path))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data[len(compressionMagic):]))
+	if nil == err {
+		data, err = ioutil.ReadAll(reader)
+	}
+	if nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to decompress path=%v", path))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	return data, nil
+}
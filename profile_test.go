@@ -0,0 +1,48 @@
+package minioproto
+
+import (
+	"bytes"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"math/rand"
+	"testing"
+)
+
+func TestProfileQuota(t *testing.T) {
+	cache, _ := newTestCache(t, WithProfiles(Profile{Prefix: "limited/", Compress: true, Quota: 200}))
+	// Quotas count the stored bytes, so a compressible payload larger than the quota fits
+	payload := bytes.Repeat([]byte("a"), 1000)
+	if _, err := cache.WriteData("limited/a", payload, minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if output, err := cache.ReadData("limited/a", minio.GetObjectOptions{}); nil != err || !bytes.Equal(payload, output) {
+		t.Fatalf("expected the payload back, got %v bytes %v", len(output), err)
+	}
+
+	// Failed writes don't use up the quota
+	failing := cache.With(CallFaults(1, FaultRule{Prefix: "limited/", Ops: []AuthOp{AuthPut}, ErrorRate: 1}))
+	for i := 0; i < 10; i++ {
+		if _, err := failing.WriteData("limited/b", payload, minio.PutObjectOptions{}); nil == err {
+			t.Fatal("expected the injected fault")
+		}
+	}
+	if _, err := cache.WriteData("limited/b", payload, minio.PutObjectOptions{}); nil != err {
+		t.Fatalf("expected failed writes not to count, got %v", err)
+	}
+
+	// Replacing an object only counts the difference
+	if _, err := cache.WriteData("limited/b", payload, minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	incompressible := make([]byte, 300)
+	rand.New(rand.NewSource(1)).Read(incompressible)
+	if _, err := cache.WriteData("limited/c", incompressible, minio.PutObjectOptions{}); ErrQuotaExceeded != errors.Cause(err) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if err := cache.DeleteData("limited/a", minio.RemoveObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := cache.WriteData("limited/d", payload, minio.PutObjectOptions{}); nil != err {
+		t.Fatalf("expected deletes to free the quota, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package minioproto
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrQueueEmpty is returned by Dequeue when no message is available
+var ErrQueueEmpty = errors.New("Queue has no available messages")
+
+// ErrLeaseLost is returned by Ack when the lease expired and was taken by another consumer
+var ErrLeaseLost = errors.New("Lease is no longer held")
+
+// Lease is a claim on a dequeued message, held until it is acked, nacked or its visibility timeout expires
+type Lease struct {
+	ID      string    `json:"id"`
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// Enqueue adds a message to the queue stored under the queue prefix, returning its id.
+// Messages are delivered in enqueue order with at-least-once semantics.
+func (cache *Cache) Enqueue(queue string, msg proto.Message) (string, error) {
+	id := sortableID()
+	if _, err := cache.PutPROTO(queueMessagePath(queue, id), msg, nil, minio.PutObjectOptions{}); nil != err {
+		return "", err
+	}
+	cache.logger.Info(fmt.Sprintf("Enqueued message=%v on queue=%v", id, queue))
+	return id, nil
+}
+
+// Dequeue claims the oldest message without a live lease, reading it into msg. The message becomes
+// visible to other consumers again if it isn't acked within visibilityTimeout.
+// Leases are claimed with conditional writes, If-None-Match for free messages and If-Match on the ETag of
+// an expired lease, so exactly one consumer wins each claim. The backend must implement ConditionalBackend.
+// Delivery is still at-least-once, a consumer that outlives its lease may process a message twice.
+func (cache *Cache) Dequeue(queue string, msg proto.Message, visibilityTimeout time.Duration) (*Lease, error) {
+	objects, err := cache.List(queue+"/messages/", minio.ListObjectsOptions{Recursive: true})
+	if nil != err {
+		return nil, err
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	for _, info := range objects {
+		id := strings.TrimSuffix(info.Key[strings.LastIndex(info.Key, "/")+1:], ".pb")
+		lease, err := cache.claimLease(queue, id, visibilityTimeout)
+		if nil != err {
+			return nil, err
+		}
+		if nil == lease {
+			continue
+		}
+
+		if err := cache.GetPROTO(info.Key, msg, nil, minio.GetObjectOptions{}); nil != err {
+			if "NoSuchKey" != minio.ToErrorResponse(errors.Cause(err)).Code {
+				return nil, err
+			}
+			// Another consumer acked the message after it was listed, release the lease and move on
+			if err := cache.DeleteData(queueLeasePath(queue, id), minio.RemoveObjectOptions{}); nil != err {
+				return nil, err
+			}
+			continue
+		}
+		cache.logger.Info(fmt.Sprintf("Dequeued message=%v from queue=%v", id, queue))
+		return lease, nil
+	}
+	return nil, ErrQueueEmpty
+}
+
+// Ack removes a processed message from the queue
+func (cache *Cache) Ack(queue string, lease *Lease) error {
+	current, _, err := cache.currentLease(queue, lease.ID)
+	if nil != err {
+		return err
+	}
+	if nil == current || current.Token != lease.Token {
+		err = errors.Wrap(ErrLeaseLost, fmt.Sprintf("Failed to ack message=%v", lease.ID))
+		cache.logger.Error(err.Error())
+		return err
+	}
+
+	if err := cache.DeleteData(queueMessagePath(queue, lease.ID), minio.RemoveObjectOptions{}); nil != err {
+		return err
+	}
+	return cache.DeleteData(queueLeasePath(queue, lease.ID), minio.RemoveObjectOptions{})
+}
+
+// Nack releases a lease so the message is immediately visible to other consumers
+func (cache *Cache) Nack(queue string, lease *Lease) error {
+	current, _, err := cache.currentLease(queue, lease.ID)
+	if nil != err || nil == current || current.Token != lease.Token {
+		return err
+	}
+	return cache.DeleteData(queueLeasePath(queue, lease.ID), minio.RemoveObjectOptions{})
+}
+
+// claimLease takes the lease of a message if it is free or expired, returning nil if another consumer holds it
+func (cache *Cache) claimLease(queue, id string, visibilityTimeout time.Duration) (*Lease, error) {
+	current, etag, err := cache.currentLease(queue, id)
+	if nil != err {
+		return nil, err
+	}
+	if nil != current && time.Now().Before(current.Expires) {
+		return nil, nil
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); nil != err {
+		return nil, err
+	}
+	lease := &Lease{
+		ID:      id,
+		Token:   hex.EncodeToString(token),
+		Expires: time.Now().Add(visibilityTimeout),
+	}
+	payload, err := json.Marshal(lease)
+	if nil != err {
+		return nil, err
+	}
+	// Only replace the lease that was read, a concurrent claim makes the condition fail
+	cond := PutCondition{IfNoneMatch: nil == current, IfMatch: etag}
+	claimed, err := cache.putRecord(queueLeasePath(queue, id), payload, cond)
+	if nil != err || !claimed {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// currentLease reads the lease of a message and its ETag from the bucket, bypassing local caches, or nil if there is none
+func (cache *Cache) currentLease(queue, id string) (*Lease, string, error) {
+	data, etag, err := cache.getRecord(queueLeasePath(queue, id))
+	if nil != err || nil == data {
+		return nil, "", err
+	}
+	lease := &Lease{}
+	if err := json.Unmarshal(data, lease); nil != err {
+		err = errors.Wrap(err, "Failed deserialize lease from json")
+		cache.logger.Error(err.Error())
+		return nil, "", err
+	}
+	return lease, etag, nil
+}
+
+func queueMessagePath(queue, id string) string {
+	return fmt.Sprintf("%v/messages/%v.pb", queue, id)
+}
+
+func queueLeasePath(queue, id string) string {
+	return fmt.Sprintf("%v/leases/%v.json", queue, id)
+}
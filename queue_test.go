@@ -0,0 +1,130 @@
+package minioproto
+
+import (
+	"context"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+	"time"
+)
+
+// staleListBackend lists extra objects that were already removed, like a listing racing a concurrent ack
+type staleListBackend struct {
+	*fileBackend
+	stale []minio.ObjectInfo
+}
+
+// List sends the stale objects before the current ones
+func (backend *staleListBackend) List(ctx context.Context, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+	output := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(output)
+		for _, info := range backend.stale {
+			output <- info
+		}
+		for info := range backend.fileBackend.List(ctx, opts) {
+			output <- info
+		}
+	}()
+	return output
+}
+
+func TestQueue(t *testing.T) {
+	cache, _ := newTestCache(t)
+	first, err := cache.Enqueue("jobs", wrapperspb.String("first"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := cache.Enqueue("jobs", wrapperspb.String("second")); nil != err {
+		t.Fatal(err)
+	}
+
+	msg := &wrapperspb.StringValue{}
+	lease, err := cache.Dequeue("jobs", msg, time.Minute)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if first != lease.ID || "first" != msg.Value {
+		t.Fatalf("expected the first message, got %v %q", lease.ID, msg.Value)
+	}
+
+	// The leased message is skipped until it is nacked
+	other, err := cache.Dequeue("jobs", msg, time.Minute)
+	if nil != err || "second" != msg.Value {
+		t.Fatalf("expected the second message, got %q %v", msg.Value, err)
+	}
+	if _, err := cache.Dequeue("jobs", msg, time.Minute); ErrQueueEmpty != err {
+		t.Fatalf("expected ErrQueueEmpty, got %v", err)
+	}
+	if err := cache.Nack("jobs", other); nil != err {
+		t.Fatal(err)
+	}
+	other, err = cache.Dequeue("jobs", msg, time.Minute)
+	if nil != err || "second" != msg.Value {
+		t.Fatalf("expected the nacked message again, got %q %v", msg.Value, err)
+	}
+
+	if err := cache.Ack("jobs", lease); nil != err {
+		t.Fatal(err)
+	}
+	if err := cache.Ack("jobs", other); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := cache.Dequeue("jobs", msg, time.Minute); ErrQueueEmpty != err {
+		t.Fatalf("expected ErrQueueEmpty after acking, got %v", err)
+	}
+}
+
+func TestQueueExpiredLease(t *testing.T) {
+	cache, _ := newTestCache(t)
+	if _, err := cache.Enqueue("jobs", wrapperspb.String("only")); nil != err {
+		t.Fatal(err)
+	}
+	msg := &wrapperspb.StringValue{}
+	expired, err := cache.Dequeue("jobs", msg, -time.Second)
+	if nil != err {
+		t.Fatal(err)
+	}
+	current, err := cache.Dequeue("jobs", msg, time.Minute)
+	if nil != err {
+		t.Fatalf("expected the expired lease to be reclaimed, got %v", err)
+	}
+	if err := cache.Ack("jobs", expired); ErrLeaseLost != errors.Cause(err) {
+		t.Fatalf("expected ErrLeaseLost, got %v", err)
+	}
+	if err := cache.Ack("jobs", current); nil != err {
+		t.Fatal(err)
+	}
+}
+
+func TestQueueSkipsAckedMessages(t *testing.T) {
+	files, _ := newTestFileBackend(t)
+	backend := &staleListBackend{fileBackend: files}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := NewWithBackend(ctx, zap.NewNop(), backend)
+	if nil != err {
+		t.Fatal(err)
+	}
+	acked, err := cache.Enqueue("jobs", wrapperspb.String("acked"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := cache.Enqueue("jobs", wrapperspb.String("pending")); nil != err {
+		t.Fatal(err)
+	}
+	backend.stale = []minio.ObjectInfo{{Key: queueMessagePath("jobs", acked)}}
+	if err := cache.DeleteData(queueMessagePath("jobs", acked), minio.RemoveObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+
+	msg := &wrapperspb.StringValue{}
+	if _, err := cache.Dequeue("jobs", msg, time.Minute); nil != err || "pending" != msg.Value {
+		t.Fatalf("expected the pending message, got %q %v", msg.Value, err)
+	}
+	if lease, _, err := cache.currentLease("jobs", acked); nil != err || nil != lease {
+		t.Fatalf("expected the lease of the acked message to be released, got %+v %v", lease, err)
+	}
+}
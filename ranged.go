@@ -0,0 +1,206 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// defaultPartSize is the byte range fetched by each request of a ranged download
+const defaultPartSize = 16 * 1024 * 1024
+
+// RangedGetOptions configures GetRanged, GetRangedTo and GetRangedFile
+type RangedGetOptions struct {
+	// PartSize is the byte range fetched by each request, defaults to 16 MiB
+	PartSize int64
+	// Concurrency is the number of parts fetched in parallel, defaults to 4
+	Concurrency int
+	// GetOptions is applied to every part, e.g. VersionID, and must not set a range
+	GetOptions minio.GetObjectOptions
+}
+
+// GetRanged reads an object into memory by fetching byte ranges concurrently
+func (cache *Cache) GetRanged(path string, rangedOpts RangedGetOptions) ([]byte, error) {
+	if cache.needsWholePayload(path) {
+		return cache.ReadData(path, rangedOpts.GetOptions)
+	}
+	key := cache.objectKey(path)
+	info, err := cache.statRanged(key, rangedOpts)
+	if nil != err {
+		return nil, err
+	}
+	buf := &bufferAt{data: make([]byte, info.Size)}
+	if err := cache.fetchRanges(key, info, buf, rangedOpts); nil != err {
+		return nil, err
+	}
+	return cache.openPayload(key, buf.data, info.UserMetadata[envelopeMetadata], rangedOpts.GetOptions)
+}
+
+// GetRangedFile downloads an object to filePath by fetching byte ranges concurrently, returning the size
+func (cache *Cache) GetRangedFile(path, filePath string, rangedOpts RangedGetOptions) (int64, error) {
+	file, err := os.Create(filePath)
+	if nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to create %v", filePath))
+		cache.logger.Error(err.Error())
+		return 0, err
+	}
+	size, err := cache.GetRangedTo(path, file, rangedOpts)
+	if closeErr := file.Close(); nil == err && nil != closeErr {
+		err = errors.Wrap(closeErr, fmt.Sprintf("Failed to close %v", filePath))
+	}
+	if nil != err {
+		os.Remove(filePath)
+		return 0, err
+	}
+	return size, nil
+}
+
+// GetRangedTo writes an object to dst by fetching byte ranges concurrently, returning the size.
+// Parts are pinned to the ETag seen when the download started, so a concurrent overwrite fails the download.
+func (cache *Cache) GetRangedTo(path string, dst io.WriterAt, rangedOpts RangedGetOptions) (int64, error) {
+	if cache.needsWholePayload(path) {
+		data, err := cache.ReadData(path, rangedOpts.GetOptions)
+		if nil != err {
+			return 0, err
+		}
+		if _, err := dst.WriteAt(data, 0); nil != err {
+			err = errors.Wrap(err, "Failed to write payload")
+			cache.logger.Error(err.Error())
+			return 0, err
+		}
+		return int64(len(data)), nil
+	}
+	key := cache.objectKey(path)
+	info, err := cache.statRanged(key, rangedOpts)
+	if nil != err {
+		return 0, err
+	}
+	if err := cache.fetchRanges(key, info, dst, rangedOpts); nil != err {
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+// needsWholePayload reports whether encryption, signatures, snapshots or profiles prevent reading an object
+// by ranges or writing it as a stream
+func (cache *Cache) needsWholePayload(path string) bool {
+	return cache.sealsPayload(path) || nil != cache.snapshot
+}
+
+// sealsPayload reports whether compression, encryption, signatures or profiles transform the stored payload of path
+func (cache *Cache) sealsPayload(path string) bool {
+	path = cache.objectKey(path)
+	_, compressed := compressorForPath(path)
+	return compressed || nil != cache.keysFor(path) || nil != cache.signer || nil != cache.profileFor(path)
+}
+
+// statRanged stats the object and checks it against the configured read limits
+func (cache *Cache) statRanged(path string, rangedOpts RangedGetOptions) (*minio.ObjectInfo, error) {
+	if err := cache.authorize(AuthGet, path, nil); nil != err {
+		return nil, err
+	}
+	info, err := cache.backend.Stat(cache.ctx, path, rangedOpts.GetOptions)
+	if nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to stat %v", path))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	if err := cache.checkGetSize(path, info.Size); nil != err {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// fetchRanges downloads the parts of the object concurrently into dst
+func (cache *Cache) fetchRanges(path string, info *minio.ObjectInfo, dst io.WriterAt, rangedOpts RangedGetOptions) error {
+	partSize := rangedOpts.PartSize
+	if partSize <= 0 {
+		partSize = defaultPartSize
+	}
+	parts := int((info.Size + partSize - 1) / partSize)
+	cache.logger.Info(fmt.Sprintf("Reading path=%v with %v bytes in %v parts", path, info.Size, parts))
+
+	mutex := sync.Mutex{}
+	var firstErr error
+	parallel(rangedOpts.Concurrency, parts, func(index int) {
+		mutex.Lock()
+		failed := nil != firstErr
+		mutex.Unlock()
+		if failed {
+			return
+		}
+
+		start := int64(index) * partSize
+		end := start + partSize - 1
+		if end >= info.Size {
+			end = info.Size - 1
+		}
+		if err := cache.fetchRange(path, info, start, end, dst, rangedOpts.GetOptions); nil != err {
+			mutex.Lock()
+			if nil == firstErr {
+				firstErr = err
+			}
+			mutex.Unlock()
+		}
+	})
+	if nil != firstErr {
+		cache.logger.Error(firstErr.Error())
+		return firstErr
+	}
+
+	cache.fire(Event{Op: EventGet, Path: path, Size: info.Size, ETag: info.ETag, VersionID: info.VersionID})
+	cache.logger.Info(fmt.Sprintf("Successfully read bytes: %v", info.Size))
+	return nil
+}
+
+// fetchRange downloads the inclusive byte range [start, end] into dst
+func (cache *Cache) fetchRange(path string, info *minio.ObjectInfo, start, end int64, dst io.WriterAt, base minio.GetObjectOptions) error {
+	// Build fresh options per part, the header map of GetObjectOptions is shared by copies
+	opts := minio.GetObjectOptions{
+		ServerSideEncryption: base.ServerSideEncryption,
+		VersionID:            info.VersionID,
+	}
+	for key := range base.Header() {
+		opts.Set(key, base.Header().Get(key))
+	}
+	if err := opts.SetMatchETag(info.ETag); nil != err {
+		return err
+	}
+	if err := opts.SetRange(start, end); nil != err {
+		return err
+	}
+
+	obj, err := cache.backend.Get(cache.ctx, path, opts)
+	if nil != err {
+		return errors.Wrap(err, fmt.Sprintf("Failed to get range %v-%v", start, end))
+	}
+	defer obj.Close()
+	data, err := ioutil.ReadAll(obj)
+	if nil != err {
+		return errors.Wrap(err, fmt.Sprintf("Failed to read range %v-%v", start, end))
+	}
+	if int64(len(data)) != end-start+1 {
+		return errors.New(fmt.Sprintf("Range %v-%v returned %v bytes", start, end, len(data)))
+	}
+	if _, err := dst.WriteAt(data, start); nil != err {
+		return errors.Wrap(err, fmt.Sprintf("Failed to write range %v-%v", start, end))
+	}
+	return nil
+}
+
+// bufferAt is an in-memory io.WriterAt over a preallocated slice
+type bufferAt struct {
+	data []byte
+}
+
+// WriteAt copies p into the buffer at off
+func (buf *bufferAt) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > int64(len(buf.data)) {
+		return 0, errors.New("Write outside of buffer")
+	}
+	return copy(buf.data[off:], p), nil
+}
@@ -0,0 +1,26 @@
+package minioproto
+
+import (
+	"github.com/minio/minio-go/v7"
+	"strings"
+)
+
+// WriteResult describes the object stored by a write, for conditional reads and lineage tracking
+type WriteResult struct {
+	Key       string `json:"key"`
+	ETag      string `json:"etag"`
+	VersionID string `json:"versionId,omitempty"`
+	Size      int64  `json:"size"`
+	// Spooled is set when minio was unreachable and the write was queued on local disk, see WithSpool
+	Spooled bool `json:"spooled,omitempty"`
+}
+
+// newWriteResult normalizes the minio UploadInfo, stripping any quotes from the ETag
+func newWriteResult(uploadInfo minio.UploadInfo) *WriteResult {
+	return &WriteResult{
+		Key:       uploadInfo.Key,
+		ETag:      strings.Trim(uploadInfo.ETag, "\""),
+		VersionID: uploadInfo.VersionID,
+		Size:      uploadInfo.Size,
+	}
+}
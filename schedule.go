@@ -0,0 +1,147 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when a maintenance task runs next
+type Schedule interface {
+	// Next returns the first run time after t, the zero time when there is none
+	Next(t time.Time) time.Time
+}
+
+// intervalSchedule runs at a fixed interval
+type intervalSchedule time.Duration
+
+// Next returns t plus the interval
+func (interval intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(interval))
+}
+
+// Every returns a Schedule running at a fixed interval
+func Every(interval time.Duration) Schedule {
+	return intervalSchedule(interval)
+}
+
+// cronSchedule is a parsed five field cron expression
+type cronSchedule struct {
+	minutes, hours, days, months, weekdays map[int]bool
+	anyDay, anyWeekday                     bool
+}
+
+// ParseCron parses a five field cron expression "minute hour day-of-month month day-of-week"
+// supporting *, lists, ranges and steps such as "*/15 2-4 * * 1,3". Times are evaluated in the
+// location of the time passed to Next. As in cron, when both day fields are restricted either may match.
+func ParseCron(spec string) (Schedule, error) {
+	fields := strings.Fields(spec)
+	if 5 != len(fields) {
+		return nil, errors.New(fmt.Sprintf("Cron expression %q must have 5 fields", spec))
+	}
+	bounds := [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+	sets := make([]map[int]bool, 5)
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if nil != err {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to parse cron expression %q", spec))
+		}
+		sets[i] = set
+	}
+	// Sunday may also be written as 7
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+	return &cronSchedule{
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   sets[4],
+		anyDay:     "*" == fields[2],
+		anyWeekday: "*" == fields[4],
+	}, nil
+}
+
+// parseCronField parses one comma separated field of a cron expression
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	output := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if index := strings.Index(part, "/"); index >= 0 {
+			value, err := strconv.Atoi(part[index+1:])
+			if nil != err || value <= 0 {
+				return nil, errors.New(fmt.Sprintf("Invalid step in %q", part))
+			}
+			step = value
+			part = part[:index]
+		}
+
+		low, high := min, max
+		if "*" != part {
+			bounds := strings.SplitN(part, "-", 2)
+			value, err := strconv.Atoi(bounds[0])
+			if nil != err {
+				return nil, errors.New(fmt.Sprintf("Invalid value in %q", part))
+			}
+			low, high = value, value
+			if 2 == len(bounds) {
+				if high, err = strconv.Atoi(bounds[1]); nil != err {
+					return nil, errors.New(fmt.Sprintf("Invalid range in %q", part))
+				}
+			} else if step > 1 {
+				high = max
+			}
+		}
+		// Day of week accepts 7 for Sunday
+		limit := max
+		if 6 == max {
+			limit = 7
+		}
+		if low < min || high > limit || low > high {
+			return nil, errors.New(fmt.Sprintf("Value out of range in %q", part))
+		}
+		for value := low; value <= high; value += step {
+			output[value] = true
+		}
+	}
+	return output, nil
+}
+
+// Next returns the first matching minute after t, searching up to five years ahead
+func (schedule *cronSchedule) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for next.Before(limit) {
+		switch {
+		case !schedule.months[int(next.Month())]:
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+		case !schedule.dayMatches(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+		case !schedule.hours[next.Hour()]:
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+		case !schedule.minutes[next.Minute()]:
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches applies the cron rule for combining day of month and day of week
+func (schedule *cronSchedule) dayMatches(t time.Time) bool {
+	day := schedule.days[t.Day()]
+	weekday := schedule.weekdays[int(t.Weekday())]
+	switch {
+	case schedule.anyDay && schedule.anyWeekday:
+		return true
+	case schedule.anyDay:
+		return weekday
+	case schedule.anyWeekday:
+		return day
+	}
+	return day || weekday
+}
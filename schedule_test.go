@@ -0,0 +1,70 @@
+package minioproto
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-b * * * *",
+	}
+	for _, spec := range tests {
+		t.Run(spec, func(t *testing.T) {
+			if _, err := ParseCron(spec); nil == err {
+				t.Fatalf("expected %q to be rejected", spec)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// 2024-01-31 is a Wednesday
+	from := time.Date(2024, 1, 31, 9, 30, 45, 0, time.UTC)
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 31, 9, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 31, 9, 45, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)},
+		{"30 9 * * *", time.Date(2024, 2, 1, 9, 30, 0, 0, time.UTC)},
+		{"0 2-4 * * *", time.Date(2024, 2, 1, 2, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1,3", time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 15 * 5", time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			schedule, err := ParseCron(test.spec)
+			if nil != err {
+				t.Fatalf("ParseCron failed: %v", err)
+			}
+			if next := schedule.Next(from); !next.Equal(test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, next)
+			}
+		})
+	}
+}
+
+func TestEveryNext(t *testing.T) {
+	from := time.Date(2024, 1, 31, 9, 30, 45, 0, time.UTC)
+	if next := Every(time.Hour).Next(from); !next.Equal(from.Add(time.Hour)) {
+		t.Fatalf("expected %v, got %v", from.Add(time.Hour), next)
+	}
+}
@@ -0,0 +1,96 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strconv"
+)
+
+const schemaVersionMetadata = "Schema-Version"
+
+// ErrSchemaTooNew is returned by GetPROTO when the stored schema version is newer than the registered one
+var ErrSchemaTooNew = errors.New("Stored schema version is newer than the registered version")
+
+// Migration upgrades a serialized message from one schema version to the next
+type Migration func(path string, payload []byte) ([]byte, error)
+
+// protoSchema is the current version and migrations registered for a message type
+type protoSchema struct {
+	version    int
+	migrations map[int]Migration
+}
+
+// WithSchemaVersion records version in the metadata of every PutPROTO of message's type.
+// GetPROTO upgrades payloads stored at an older version by calling migrations[v] for each version v
+// from the stored one up to version, objects without a recorded version are treated as version 0.
+func WithSchemaVersion(message proto.Message, version int, migrations map[int]Migration) Option {
+	return func(cache *Cache) {
+		if nil == cache.schemas {
+			cache.schemas = map[protoreflect.FullName]*protoSchema{}
+		}
+		cache.schemas[message.ProtoReflect().Descriptor().FullName()] = &protoSchema{
+			version:    version,
+			migrations: migrations,
+		}
+	}
+}
+
+// schemaFor returns the schema registered for the message type, nil when there is none
+func (cache *Cache) schemaFor(message proto.Message) *protoSchema {
+	if nil == cache.schemas {
+		return nil
+	}
+	return cache.schemas[message.ProtoReflect().Descriptor().FullName()]
+}
+
+// withSchemaVersion records the registered schema version of the message in opts
+func (cache *Cache) withSchemaVersion(message proto.Message, opts minio.PutObjectOptions) minio.PutObjectOptions {
+	schema := cache.schemaFor(message)
+	if nil == schema {
+		return opts
+	}
+	return withUserMetadata(opts, schemaVersionMetadata, strconv.Itoa(schema.version))
+}
+
+// migratePROTO upgrades a payload to the registered schema version of the message, reading the stored
+// version from the metadata of the object the payload was read from
+func (cache *Cache) migratePROTO(path string, message proto.Message, payload []byte, metadata map[string]string) ([]byte, error) {
+	schema := cache.schemaFor(message)
+	if nil == schema {
+		return payload, nil
+	}
+
+	var err error
+	stored := 0
+	if value := metadataValue(metadata, schemaVersionMetadata); "" != value {
+		if stored, err = strconv.Atoi(value); nil != err {
+			err = errors.Wrap(err, fmt.Sprintf("Failed to parse schema version of %v", path))
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
+	}
+	if stored > schema.version {
+		err = errors.Wrap(ErrSchemaTooNew, fmt.Sprintf("path=%v stored=%v registered=%v", path, stored, schema.version))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	for version := stored; version < schema.version; version++ {
+		migration, ok := schema.migrations[version]
+		if !ok {
+			err = errors.New(fmt.Sprintf("Missing migration from schema version %v for path=%v", version, path))
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
+		cache.logger.Info(fmt.Sprintf("Migrating path=%v from schema version %v", path, version))
+		if payload, err = migration(path, payload); nil != err {
+			err = errors.Wrap(err, fmt.Sprintf("Failed to migrate path=%v from schema version %v", path, version))
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
+	}
+	return payload, nil
+}
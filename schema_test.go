@@ -0,0 +1,60 @@
+package minioproto
+
+import (
+	"context"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"strings"
+	"testing"
+)
+
+func TestSchemaMigration(t *testing.T) {
+	writer, backend := newTestCache(t, WithSchemaVersion(&wrapperspb.StringValue{}, 1, nil))
+	if _, err := writer.PutPROTO("values/a", wrapperspb.String("stored"), nil, minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+
+	upper := func(path string, payload []byte) ([]byte, error) {
+		msg := &wrapperspb.StringValue{}
+		if err := proto.Unmarshal(payload, msg); nil != err {
+			return nil, err
+		}
+		return proto.Marshal(wrapperspb.String(strings.ToUpper(msg.Value)))
+	}
+	// Payloads served by a local tier are migrated from the version recorded with them
+	reader, err := NewWithBackend(context.Background(), zap.NewNop(), backend,
+		WithSchemaVersion(&wrapperspb.StringValue{}, 2, map[int]Migration{1: upper}),
+		WithTiers(TierPolicy{PromoteOnRead: true}, NewMemoryTier(1<<20)))
+	if nil != err {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		msg := &wrapperspb.StringValue{}
+		if err := reader.GetPROTO("values/a", msg, nil, minio.GetObjectOptions{}); nil != err || "STORED" != msg.Value {
+			t.Fatalf("read %v: expected the migrated value, got %q %v", i, msg.Value, err)
+		}
+	}
+
+	// Version 1 readers refuse payloads written at version 2
+	if _, err := reader.PutPROTO("values/b", wrapperspb.String("new"), nil, minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if err := writer.GetPROTO("values/b", &wrapperspb.StringValue{}, nil, minio.GetObjectOptions{}); ErrSchemaTooNew != errors.Cause(err) {
+		t.Fatalf("expected ErrSchemaTooNew, got %v", err)
+	}
+
+	// Objects without a recorded version are version 0 and need a migration from it
+	plain, err := NewWithBackend(context.Background(), zap.NewNop(), backend)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := plain.PutPROTO("values/c", wrapperspb.String("old"), nil, minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if err := writer.GetPROTO("values/c", &wrapperspb.StringValue{}, nil, minio.GetObjectOptions{}); nil == err {
+		t.Fatal("expected a missing migration from version 0 to fail")
+	}
+}
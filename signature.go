@@ -0,0 +1,169 @@
+package minioproto
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+)
+
+// ErrSignatureInvalid is returned when a payload is unsigned or its signature doesn't verify
+var ErrSignatureInvalid = errors.New("Payload signature is invalid")
+
+const signatureMetadata = "Signature"
+const signatureKeyMetadata = "Signature-Key-Id"
+
+// signatureVersion prefixes the signed message, bump it when the message layout changes
+const signatureVersion = "MPS1"
+
+// Signer signs payloads on write and verifies them on read
+type Signer interface {
+	// Sign returns the id of the signing key and the signature of data
+	Sign(data []byte) (string, []byte, error)
+	// Verify checks the signature of data made with the key id
+	Verify(keyID string, data, signature []byte) error
+}
+
+// HMACSigner signs payloads with HMAC-SHA256 using a shared secret, Keys holds additional secrets accepted on read
+type HMACSigner struct {
+	KeyID  string
+	Secret []byte
+	Keys   map[string][]byte
+}
+
+// Sign returns the HMAC-SHA256 of data
+func (signer HMACSigner) Sign(data []byte) (string, []byte, error) {
+	mac := hmac.New(sha256.New, signer.Secret)
+	mac.Write(data)
+	return signer.KeyID, mac.Sum(nil), nil
+}
+
+// Verify checks the HMAC-SHA256 of data
+func (signer HMACSigner) Verify(keyID string, data, signature []byte) error {
+	secret := signer.Keys[keyID]
+	if keyID == signer.KeyID {
+		secret = signer.Secret
+	}
+	if nil == secret {
+		return errors.New(fmt.Sprintf("Unknown signing key %v", keyID))
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		return errors.New("HMAC mismatch")
+	}
+	return nil
+}
+
+// Ed25519Signer signs payloads with an ed25519 private key and verifies them with the public keys by id.
+// Readers only need PublicKeys, a nil PrivateKey makes writes fail.
+type Ed25519Signer struct {
+	KeyID      string
+	PrivateKey ed25519.PrivateKey
+	PublicKeys map[string]ed25519.PublicKey
+}
+
+// Sign returns the ed25519 signature of data
+func (signer Ed25519Signer) Sign(data []byte) (string, []byte, error) {
+	if nil == signer.PrivateKey {
+		return "", nil, errors.New("Ed25519Signer has no private key")
+	}
+	return signer.KeyID, ed25519.Sign(signer.PrivateKey, data), nil
+}
+
+// Verify checks the ed25519 signature of data
+func (signer Ed25519Signer) Verify(keyID string, data, signature []byte) error {
+	publicKey, ok := signer.PublicKeys[keyID]
+	if !ok && keyID == signer.KeyID && nil != signer.PrivateKey {
+		publicKey, ok = signer.PrivateKey.Public().(ed25519.PublicKey)
+	}
+	if !ok {
+		return errors.New(fmt.Sprintf("Unknown signing key %v", keyID))
+	}
+	if !ed25519.Verify(publicKey, data, signature) {
+		return errors.New("ed25519 signature mismatch")
+	}
+	return nil
+}
+
+// WithSignatures signs every payload written by WriteData, storing the signature in the object metadata,
+// and rejects payloads read by ReadData with ErrSignatureInvalid unless they carry a valid signature.
+// The signature covers the object key, content type, schema version and envelopes along with the payload,
+// so a signed payload can't be replayed under another key or with metadata that changes how it is decoded.
+// Ranged reads and payloads served from the local tiers or disk cache are not verified.
+func WithSignatures(signer Signer) Option {
+	return func(cache *Cache) {
+		cache.signer = signer
+	}
+}
+
+// signPayload adds the signature of the stored payload and its metadata to the put options
+func (cache *Cache) signPayload(path string, data []byte, opts minio.PutObjectOptions) (minio.PutObjectOptions, error) {
+	if nil == cache.signer {
+		return opts, nil
+	}
+	message := signedMessage(path, opts.ContentType, opts.UserMetadata, data)
+	keyID, signature, err := cache.signer.Sign(message)
+	if nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to sign path=%v", path))
+		cache.logger.Error(err.Error())
+		return opts, err
+	}
+	opts = withUserMetadata(opts, signatureMetadata, base64.StdEncoding.EncodeToString(signature))
+	return withUserMetadata(opts, signatureKeyMetadata, keyID), nil
+}
+
+// verifyPayload checks the signature stored in the object metadata against the payload and its metadata
+func (cache *Cache) verifyPayload(path string, data []byte, info minio.ObjectInfo) error {
+	encoded := metadataValue(info.UserMetadata, signatureMetadata)
+	keyID := metadataValue(info.UserMetadata, signatureKeyMetadata)
+
+	err := errors.New("Payload is not signed")
+	if "" != encoded {
+		var signature []byte
+		signature, err = base64.StdEncoding.DecodeString(encoded)
+		if nil == err {
+			message := signedMessage(path, info.ContentType, info.UserMetadata, data)
+			err = cache.signer.Verify(keyID, message, signature)
+		}
+	}
+	if nil != err {
+		err = errors.Wrap(ErrSignatureInvalid, fmt.Sprintf("Failed to verify path=%v: %v", path, err.Error()))
+		cache.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+// signedMessage is the newline separated version, object key, content type, schema version, envelopes
+// and payload SHA-256 covered by a signature
+func signedMessage(path, contentType string, metadata map[string]string, data []byte) []byte {
+	if "" == contentType {
+		contentType = "application/octet-stream"
+	}
+	sum := sha256.Sum256(data)
+	return []byte(strings.Join([]string{
+		signatureVersion,
+		path,
+		contentType,
+		metadataValue(metadata, schemaVersionMetadata),
+		metadataValue(metadata, envelopeMetadata),
+		hex.EncodeToString(sum[:]),
+	}, "\n"))
+}
+
+// metadataValue returns the user metadata value of key, matching names case-insensitively
+func metadataValue(metadata map[string]string, key string) string {
+	for k, v := range metadata {
+		if http.CanonicalHeaderKey(k) == http.CanonicalHeaderKey(key) {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package minioproto
+
+import (
+	"context"
+	"crypto/ed25519"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"testing"
+)
+
+// restore writes data at key with the content type and metadata of the object at src
+func restore(t *testing.T, backend Backend, src, key, data string) {
+	info, err := backend.Stat(context.Background(), src, minio.StatObjectOptions{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	putString(t, backend, key, data, minio.PutObjectOptions{ContentType: info.ContentType, UserMetadata: info.UserMetadata})
+}
+
+func TestSignatures(t *testing.T) {
+	signer := HMACSigner{KeyID: "k1", Secret: []byte("secret")}
+	cache, backend := newTestCache(t, WithSignatures(signer))
+	if _, err := cache.WriteData("signed", []byte("hello"), minio.PutObjectOptions{ContentType: "text/plain"}); nil != err {
+		t.Fatal(err)
+	}
+	if data, err := cache.ReadData("signed", minio.GetObjectOptions{}); nil != err || "hello" != string(data) {
+		t.Fatalf("expected hello, got %v %v", string(data), err)
+	}
+
+	restore(t, backend, "signed", "tampered", "hullo")
+	restore(t, backend, "signed", "replayed", "hello")
+	putString(t, backend, "unsigned", "hello", minio.PutObjectOptions{})
+	for _, key := range []string{"tampered", "replayed", "unsigned"} {
+		if _, err := cache.ReadData(key, minio.GetObjectOptions{}); ErrSignatureInvalid != errors.Cause(err) {
+			t.Fatalf("expected ErrSignatureInvalid for %v, got %v", key, err)
+		}
+	}
+
+	wrongKey, err := NewWithBackend(context.Background(), zap.NewNop(), backend, WithSignatures(HMACSigner{KeyID: "k1", Secret: []byte("other")}))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := wrongKey.ReadData("signed", minio.GetObjectOptions{}); ErrSignatureInvalid != errors.Cause(err) {
+		t.Fatalf("expected ErrSignatureInvalid with the wrong key, got %v", err)
+	}
+
+	// Readers accept payloads signed with an additional key after the signing key changed
+	rotated, err := NewWithBackend(context.Background(), zap.NewNop(), backend,
+		WithSignatures(HMACSigner{KeyID: "k2", Secret: []byte("new"), Keys: map[string][]byte{"k1": []byte("secret")}}))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if data, err := rotated.ReadData("signed", minio.GetObjectOptions{}); nil != err || "hello" != string(data) {
+		t.Fatalf("expected hello with the retired key, got %v %v", string(data), err)
+	}
+}
+
+func TestEd25519Signatures(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	writer, backend := newTestCache(t, WithSignatures(Ed25519Signer{KeyID: "k1", PrivateKey: privateKey}))
+	if _, err := writer.WriteData("signed", []byte("hello"), minio.PutObjectOptions{}); nil != err {
+		t.Fatal(err)
+	}
+
+	reader, err := NewWithBackend(context.Background(), zap.NewNop(), backend,
+		WithSignatures(Ed25519Signer{PublicKeys: map[string]ed25519.PublicKey{"k1": publicKey}}))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if data, err := reader.ReadData("signed", minio.GetObjectOptions{}); nil != err || "hello" != string(data) {
+		t.Fatalf("expected hello, got %v %v", string(data), err)
+	}
+	if _, err := reader.WriteData("other", []byte("hello"), minio.PutObjectOptions{}); nil == err {
+		t.Fatal("expected writes without a private key to fail")
+	}
+}
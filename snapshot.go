@@ -0,0 +1,120 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// ErrReadOnly is returned by writes and deletes on a snapshot view
+var ErrReadOnly = errors.New("Cache view is read-only")
+
+// ErrNotInSnapshot is returned when a path has no version in a snapshot view
+var ErrNotInSnapshot = errors.New("Object is not in snapshot")
+
+// VersionManifest maps object keys to the version IDs of a consistent state of a versioned bucket
+type VersionManifest map[string]string
+
+// snapshot resolves paths to the version IDs of a read-only view
+type snapshot struct {
+	sync.Mutex
+	asOf     time.Time
+	manifest VersionManifest
+	resolved map[string]string
+}
+
+// AsOf returns a read-only view of a versioned bucket where every read resolves to the
+// version that was current at t, reads of objects that did not exist at t fail with ErrNotInSnapshot
+func (cache *Cache) AsOf(t time.Time) *Cache {
+	output := cache.view()
+	output.snapshot = &snapshot{asOf: t, resolved: map[string]string{}}
+	return output
+}
+
+// AtVersionManifest returns a read-only view where every read resolves to the version in manifest,
+// reads of objects missing from the manifest fail with ErrNotInSnapshot
+func (cache *Cache) AtVersionManifest(manifest VersionManifest) *Cache {
+	output := cache.view()
+	output.snapshot = &snapshot{manifest: manifest, resolved: map[string]string{}}
+	return output
+}
+
+// SnapshotManifest records the current version of every object under prefix, for use with AtVersionManifest
+func (cache *Cache) SnapshotManifest(prefix string) (VersionManifest, error) {
+	objects, err := cache.List(prefix, minio.ListObjectsOptions{Recursive: true, WithVersions: true})
+	if nil != err {
+		return nil, err
+	}
+	output := VersionManifest{}
+	for _, info := range objects {
+		if info.IsLatest && !info.IsDeleteMarker {
+			output[info.Key] = info.VersionID
+		}
+	}
+	return output, nil
+}
+
+// view returns a shallow copy of the Cache sharing its client, hooks and registries
+func (cache *Cache) view() *Cache {
+	output := *cache
+	return &output
+}
+
+// resolveVersion pins a read to the version of the view or snapshot view, if any
+func (cache *Cache) resolveVersion(path string, opts minio.GetObjectOptions) (minio.GetObjectOptions, error) {
+	opts = cache.pinVersion(opts)
+	if nil == cache.snapshot || "" != opts.VersionID {
+		return opts, nil
+	}
+	versionID, err := cache.snapshot.resolve(cache, path)
+	if nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to resolve snapshot version of path=%v", path))
+		cache.logger.Error(err.Error())
+		return opts, err
+	}
+	opts.VersionID = versionID
+	return opts, nil
+}
+
+// resolve returns the version ID of path in the snapshot
+func (snap *snapshot) resolve(cache *Cache, path string) (string, error) {
+	snap.Lock()
+	defer snap.Unlock()
+	if versionID, ok := snap.resolved[path]; ok {
+		return versionID, nil
+	}
+
+	if nil != snap.manifest {
+		versionID, ok := snap.manifest[path]
+		if !ok {
+			return "", ErrNotInSnapshot
+		}
+		snap.resolved[path] = versionID
+		return versionID, nil
+	}
+
+	if err := cache.authorize(AuthList, path, nil); nil != err {
+		return "", err
+	}
+	var latest *minio.ObjectInfo
+	opts := minio.ListObjectsOptions{Prefix: path, WithVersions: true}
+	for info := range cache.backend.List(cache.ctx, opts) {
+		if nil != info.Err {
+			return "", info.Err
+		}
+		if path != info.Key || info.LastModified.After(snap.asOf) {
+			continue
+		}
+		if nil == latest || info.LastModified.After(latest.LastModified) {
+			current := info
+			latest = &current
+		}
+	}
+	if nil == latest || latest.IsDeleteMarker {
+		return "", ErrNotInSnapshot
+	}
+	snap.resolved[path] = latest.VersionID
+	return latest.VersionID, nil
+}
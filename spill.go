@@ -0,0 +1,148 @@
+package minioproto
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// WithSpillThreshold copies objects larger than thresholdBytes to a temp file in dir (empty for the
+// system temp dir) instead of reading them into memory. OpenData then returns a reader over the file,
+// and GetJSON, GetCSV, GetTSV, GetGob and GetCSVColumns decode from it. Paths that need the whole
+// payload (encryption, signatures, snapshots or profiles) and GetPROTO, which can only decode a
+// []byte, are still read into memory, so combine it with WithMaxGetSize to bound them.
+func WithSpillThreshold(thresholdBytes int64, dir string) Option {
+	return func(cache *Cache) {
+		cache.spillThreshold = thresholdBytes
+		cache.spillDir = dir
+	}
+}
+
+// OpenData returns a reader over the payload at path, which must be closed.
+// Objects above the spill threshold are read from a temp file that is removed on Close,
+// smaller objects are read with ReadData.
+func (cache *Cache) OpenData(path string, opts minio.GetObjectOptions) (io.ReadCloser, error) {
+	reader, _, err := cache.openData(path, opts)
+	return reader, err
+}
+
+// openData returns a reader over the payload, along with the payload itself when it was read into memory
+func (cache *Cache) openData(path string, opts minio.GetObjectOptions) (io.ReadCloser, []byte, error) {
+	if cache.spillThreshold <= 0 || cache.needsWholePayload(path) {
+		return cache.openBuffered(path, opts)
+	}
+	path = cache.objectKey(path)
+	opts = cache.pinVersion(opts)
+	if err := cache.authorize(AuthGet, path, nil); nil != err {
+		return nil, nil, err
+	}
+	statOpts := minio.StatObjectOptions{}
+	statOpts.VersionID = opts.VersionID
+	info, err := cache.backend.Stat(cache.ctx, path, statOpts)
+	if nil != err {
+		err = errors.Wrap(err, "Failed to stat file")
+		cache.logger.Error(err.Error())
+		return nil, nil, err
+	}
+	if info.Size <= cache.spillThreshold || "" != opts.Header().Get("Range") {
+		return cache.openBuffered(path, opts)
+	}
+	if err := cache.checkGetSize(path, info.Size); nil != err {
+		return nil, nil, err
+	}
+
+	// Pin the ETag so the spilled copy matches the size that was checked, the headers map is
+	// copied since copies of GetObjectOptions share it
+	getOpts := minio.GetObjectOptions{VersionID: opts.VersionID}
+	for key := range opts.Header() {
+		getOpts.Set(key, opts.Header().Get(key))
+	}
+	if err := getOpts.SetMatchETag(info.ETag); nil != err {
+		return nil, nil, err
+	}
+	file, err := cache.spillObject(path, getOpts)
+	if nil != err {
+		return nil, nil, err
+	}
+	cache.recordRead(path, info)
+	return &spillReader{cache: cache, path: path, opts: opts, file: file, size: info.Size}, nil, nil
+}
+
+// openBuffered reads the payload into memory with ReadData
+func (cache *Cache) openBuffered(path string, opts minio.GetObjectOptions) (io.ReadCloser, []byte, error) {
+	data, err := cache.ReadData(path, opts)
+	if nil != err {
+		return nil, nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), data, nil
+}
+
+// spillObject downloads the object to a temp file positioned at its start
+func (cache *Cache) spillObject(path string, opts minio.GetObjectOptions) (*os.File, error) {
+	cache.logger.Info(fmt.Sprintf("Spilling path=%v to disk", path))
+	if err := cache.injectFault(AuthGet, path); nil != err {
+		err = errors.Wrap(err, "Failed to get file")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	obj, err := cache.backend.Get(cache.ctx, path, opts)
+	if nil != err {
+		err = errors.Wrap(err, "Failed to get file")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	defer obj.Close()
+
+	file, err := ioutil.TempFile(cache.spillDir, "minioproto-spill-")
+	if nil != err {
+		err = errors.Wrap(err, "Failed to create spill file")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	size, err := io.Copy(file, obj)
+	if nil == err {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if nil != err {
+		file.Close()
+		os.Remove(file.Name())
+		err = errors.Wrap(err, "Failed to spill file")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	cache.logger.Info(fmt.Sprintf("Successfully spilled bytes: %v", size))
+	return file, nil
+}
+
+// spillReader reads a spilled payload, removing the temp file and firing an EventGet on Close
+type spillReader struct {
+	cache *Cache
+	path  string
+	opts  minio.GetObjectOptions
+	file  *os.File
+	size  int64
+}
+
+// Read reads from the temp file
+func (reader *spillReader) Read(p []byte) (int, error) {
+	return reader.file.Read(p)
+}
+
+// Close closes and removes the temp file
+func (reader *spillReader) Close() error {
+	if nil == reader.file {
+		return nil
+	}
+	err := reader.file.Close()
+	if removeErr := os.Remove(reader.file.Name()); nil == err {
+		err = removeErr
+	}
+	reader.file = nil
+	reader.cache.fire(Event{Op: EventGet, Path: reader.path, Size: reader.size, VersionID: reader.opts.VersionID})
+	return err
+}
@@ -0,0 +1,324 @@
+package minioproto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSpoolFull is returned when minio is unreachable and the spool has no room for the write
+var ErrSpoolFull = errors.New("Spool is full")
+
+// SpoolConflict decides what happens when a spooled write is replayed over an object changed in the meantime
+type SpoolConflict int
+
+const (
+	// SpoolOverwrite always replays the spooled write
+	SpoolOverwrite SpoolConflict = iota
+	// SpoolSkipIfNewer drops the spooled write when the object was modified after it was spooled
+	SpoolSkipIfNewer
+)
+
+const defaultSpoolInterval = 30 * time.Second
+
+// spoolDeadLetterDir is the subdirectory of the spool holding writes that failed to replay for a reason other than the network
+const spoolDeadLetterDir = "dead"
+
+// SpoolOptions configures WithSpool
+type SpoolOptions struct {
+	// Dir holds the spooled writes, one file per write
+	Dir string
+	// MaxBytes limits the total size of spooled payloads, 0 is unlimited
+	MaxBytes int64
+	// Conflict is applied when replaying writes, defaults to SpoolOverwrite
+	Conflict SpoolConflict
+	// Interval between replay attempts of the background worker, defaults to 30s
+	Interval time.Duration
+}
+
+// spoolState guards the spool directory shared by a Cache and its views
+type spoolState struct {
+	sync.Mutex
+	SpoolOptions
+}
+
+// spoolEntry is a write spooled to disk, the payload is already encrypted and signed.
+// It holds every PutObjectOptions field except Progress and ServerSideEncryption, writes with
+// server-side encryption aren't spooled so their keys never touch the disk.
+type spoolEntry struct {
+	Path                    string                  `json:"path"`
+	Data                    []byte                  `json:"data"`
+	ContentType             string                  `json:"contentType,omitempty"`
+	UserMetadata            map[string]string       `json:"userMetadata,omitempty"`
+	UserTags                map[string]string       `json:"userTags,omitempty"`
+	CacheControl            string                  `json:"cacheControl,omitempty"`
+	ContentDisposition      string                  `json:"contentDisposition,omitempty"`
+	ContentLanguage         string                  `json:"contentLanguage,omitempty"`
+	ContentEncoding         string                  `json:"contentEncoding,omitempty"`
+	Mode                    minio.RetentionMode     `json:"mode,omitempty"`
+	RetainUntilDate         time.Time               `json:"retainUntilDate,omitempty"`
+	LegalHold               minio.LegalHoldStatus   `json:"legalHold,omitempty"`
+	StorageClass            string                  `json:"storageClass,omitempty"`
+	WebsiteRedirectLocation string                  `json:"websiteRedirectLocation,omitempty"`
+	NumThreads              uint                    `json:"numThreads,omitempty"`
+	PartSize                uint64                  `json:"partSize,omitempty"`
+	SendContentMd5          bool                    `json:"sendContentMd5,omitempty"`
+	DisableMultipart        bool                    `json:"disableMultipart,omitempty"`
+	ReplicationVersionID    string                  `json:"replicationVersionId,omitempty"`
+	ReplicationETag         string                  `json:"replicationETag,omitempty"`
+	ReplicationStatus       minio.ReplicationStatus `json:"replicationStatus,omitempty"`
+	ReplicationMTime        time.Time               `json:"replicationMTime,omitempty"`
+	SpooledAt               time.Time               `json:"spooledAt"`
+}
+
+// newSpoolEntry captures a write and its options
+func newSpoolEntry(path string, data []byte, opts minio.PutObjectOptions) spoolEntry {
+	return spoolEntry{
+		Path:                    path,
+		Data:                    data,
+		ContentType:             opts.ContentType,
+		UserMetadata:            opts.UserMetadata,
+		UserTags:                opts.UserTags,
+		CacheControl:            opts.CacheControl,
+		ContentDisposition:      opts.ContentDisposition,
+		ContentLanguage:         opts.ContentLanguage,
+		ContentEncoding:         opts.ContentEncoding,
+		Mode:                    opts.Mode,
+		RetainUntilDate:         opts.RetainUntilDate,
+		LegalHold:               opts.LegalHold,
+		StorageClass:            opts.StorageClass,
+		WebsiteRedirectLocation: opts.WebsiteRedirectLocation,
+		NumThreads:              opts.NumThreads,
+		PartSize:                opts.PartSize,
+		SendContentMd5:          opts.SendContentMd5,
+		DisableMultipart:        opts.DisableMultipart,
+		ReplicationVersionID:    opts.ReplicationVersionID,
+		ReplicationETag:         opts.ReplicationETag,
+		ReplicationStatus:       opts.ReplicationStatus,
+		ReplicationMTime:        opts.ReplicationMTime,
+		SpooledAt:               time.Now(),
+	}
+}
+
+// options rebuilds the PutObjectOptions of the spooled write
+func (entry spoolEntry) options() minio.PutObjectOptions {
+	return minio.PutObjectOptions{
+		ContentType:             entry.ContentType,
+		UserMetadata:            entry.UserMetadata,
+		UserTags:                entry.UserTags,
+		CacheControl:            entry.CacheControl,
+		ContentDisposition:      entry.ContentDisposition,
+		ContentLanguage:         entry.ContentLanguage,
+		ContentEncoding:         entry.ContentEncoding,
+		Mode:                    entry.Mode,
+		RetainUntilDate:         entry.RetainUntilDate,
+		LegalHold:               entry.LegalHold,
+		StorageClass:            entry.StorageClass,
+		WebsiteRedirectLocation: entry.WebsiteRedirectLocation,
+		NumThreads:              entry.NumThreads,
+		PartSize:                entry.PartSize,
+		SendContentMd5:          entry.SendContentMd5,
+		DisableMultipart:        entry.DisableMultipart,
+		ReplicationVersionID:    entry.ReplicationVersionID,
+		ReplicationETag:         entry.ReplicationETag,
+		ReplicationStatus:       entry.ReplicationStatus,
+		ReplicationMTime:        entry.ReplicationMTime,
+	}
+}
+
+// WithSpool makes WriteData spool writes to local disk when minio is unreachable, returning a WriteResult
+// with Spooled set. A background worker replays them in order once minio is reachable, until the Cache
+// context is done. Only network errors are spooled, errors returned by minio are not, and writes with
+// server-side encryption are never spooled. Spooled writes that fail to replay for any reason other than
+// the network are moved to the dead subdirectory of the spool for inspection.
+func WithSpool(spoolOpts SpoolOptions) Option {
+	return func(cache *Cache) {
+		if spoolOpts.Interval <= 0 {
+			spoolOpts.Interval = defaultSpoolInterval
+		}
+		cache.spool = &spoolState{SpoolOptions: spoolOpts}
+	}
+}
+
+// spoolable reports whether a failed write should be spooled
+func (cache *Cache) spoolable(err error, opts minio.PutObjectOptions) bool {
+	return nil != cache.spool && nil == opts.ServerSideEncryption && networkError(err)
+}
+
+// networkError reports whether err means minio was unreachable
+func networkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// spoolWrite queues a write on disk after minio was unreachable
+func (cache *Cache) spoolWrite(path string, data []byte, opts minio.PutObjectOptions, cause error) (*WriteResult, error) {
+	cache.spool.Lock()
+	defer cache.spool.Unlock()
+
+	if cache.spool.MaxBytes > 0 {
+		files, _ := cache.spoolFiles()
+		var total int64
+		for _, file := range files {
+			if info, err := os.Stat(file); nil == err {
+				total += info.Size()
+			}
+		}
+		if total+int64(len(data)) > cache.spool.MaxBytes {
+			err := errors.Wrap(ErrSpoolFull, cause.Error())
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
+	}
+
+	payload, err := json.Marshal(newSpoolEntry(path, data, opts))
+	if nil == err {
+		err = writeFileAtomic(filepath.Join(cache.spool.Dir, sortableID()+".json"), payload)
+	}
+	if nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to spool path=%v after %v", path, cause.Error()))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	cache.logger.Warn(fmt.Sprintf("Spooled path=%v with %v bytes: %v", path, len(data), cause.Error()))
+	return &WriteResult{Key: path, Size: int64(len(data)), Spooled: true}, nil
+}
+
+// spoolFiles returns the spooled writes in the order they were written
+func (cache *Cache) spoolFiles() ([]string, error) {
+	infos, err := ioutil.ReadDir(cache.spool.Dir)
+	if nil != err {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	files := []string{}
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".json") {
+			files = append(files, filepath.Join(cache.spool.Dir, info.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// runSpool replays the spool periodically until the Cache context is done
+func (cache *Cache) runSpool() {
+	ticker := time.NewTicker(cache.spool.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := cache.ReplaySpool(); nil != err {
+			cache.logger.Warn(fmt.Sprintf("Failed to replay spool: %v", err.Error()))
+		}
+		select {
+		case <-cache.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReplaySpool writes the spooled writes to minio in order, stopping at the first network failure, and returns
+// the number of writes replayed, dropped by the conflict policy or moved to the dead subdirectory of the spool
+// after failing for another reason, e.g. an authorization error or a corrupt spool file
+func (cache *Cache) ReplaySpool() (int, error) {
+	if nil == cache.spool {
+		return 0, nil
+	}
+	cache.spool.Lock()
+	defer cache.spool.Unlock()
+
+	files, err := cache.spoolFiles()
+	if nil != err {
+		err = errors.Wrap(err, "Failed to list spool")
+		cache.logger.Error(err.Error())
+		return 0, err
+	}
+
+	for count, file := range files {
+		if err := cache.replaySpoolFile(file); nil != err {
+			if networkError(err) {
+				return count, err
+			}
+			cache.logger.Error(err.Error())
+			if err := cache.deadLetter(file); nil != err {
+				return count, err
+			}
+			continue
+		}
+		if err := os.Remove(file); nil != err {
+			return count, errors.Wrap(err, fmt.Sprintf("Failed to remove spooled file %v", file))
+		}
+	}
+	if 0 != len(files) {
+		cache.logger.Info(fmt.Sprintf("Successfully replayed %v spooled writes", len(files)))
+	}
+	return len(files), nil
+}
+
+// deadLetter moves a spooled write that can't be replayed out of the spool
+func (cache *Cache) deadLetter(file string) error {
+	dir := filepath.Join(cache.spool.Dir, spoolDeadLetterDir)
+	err := os.MkdirAll(dir, 0755)
+	if nil == err {
+		err = os.Rename(file, filepath.Join(dir, filepath.Base(file)))
+	}
+	if nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to move spooled file %v to the dead letters", file))
+		cache.logger.Error(err.Error())
+		return err
+	}
+	cache.logger.Warn(fmt.Sprintf("Moved spooled file %v to the dead letters", file))
+	return nil
+}
+
+// replaySpoolFile writes a single spooled write to minio
+func (cache *Cache) replaySpoolFile(file string) error {
+	payload, err := ioutil.ReadFile(file)
+	if nil != err {
+		return errors.Wrap(err, fmt.Sprintf("Failed to read spooled file %v", file))
+	}
+	entry := spoolEntry{}
+	if err := json.Unmarshal(payload, &entry); nil != err {
+		return errors.Wrap(err, fmt.Sprintf("Failed to parse spooled file %v", file))
+	}
+
+	if SpoolSkipIfNewer == cache.spool.Conflict {
+		if err := cache.authorize(AuthStat, entry.Path, nil); nil != err {
+			return err
+		}
+		info, err := cache.backend.Stat(cache.ctx, entry.Path, minio.StatObjectOptions{})
+		if nil == err && info.LastModified.After(entry.SpooledAt) {
+			cache.logger.Warn(fmt.Sprintf("Dropping spooled write of path=%v, object was modified at %v", entry.Path, info.LastModified))
+			return nil
+		}
+		if nil != err && "NoSuchKey" != minio.ToErrorResponse(err).Code {
+			return errors.Wrap(err, fmt.Sprintf("Failed to stat %v", entry.Path))
+		}
+	}
+
+	opts := entry.options()
+	if err := cache.authorize(AuthPut, entry.Path, opts.UserMetadata); nil != err {
+		return err
+	}
+	reader := bytes.NewReader(entry.Data)
+	uploadInfo, err := cache.backend.Put(cache.ctx, entry.Path, reader, reader.Size(), opts)
+	if nil != err {
+		return errors.Wrap(err, fmt.Sprintf("Failed to replay path=%v", entry.Path))
+	}
+	cache.finishWrite(entry.Path, entry.Data, opts, newWriteResult(uploadInfo))
+	cache.logger.Info(fmt.Sprintf("Replayed spooled path=%v", entry.Path))
+	return nil
+}
@@ -0,0 +1,81 @@
+package minioproto
+
+import (
+	"context"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSpool(t *testing.T) {
+	dir := t.TempDir()
+	cache, backend := newTestCache(t, WithSpool(SpoolOptions{Dir: dir, Interval: time.Hour}))
+	offline := cache.With(CallFaults(1, FaultRule{Ops: []AuthOp{AuthPut}, ErrorRate: 1}))
+
+	result, err := offline.WriteData("spooled", []byte("hello"), minio.PutObjectOptions{ContentType: "text/plain"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !result.Spooled {
+		t.Fatal("expected the write to be spooled")
+	}
+	if _, err := backend.Stat(context.Background(), "spooled", minio.StatObjectOptions{}); nil == err {
+		t.Fatal("expected the spooled write to not be stored yet")
+	}
+
+	// A write failing for another reason than the network is returned, not spooled
+	denied := cache.With(CallFaults(1, FaultRule{Ops: []AuthOp{AuthPut}, ErrorRate: 1, Err: ErrUnauthorized}))
+	if _, err := denied.WriteData("denied", []byte("hello"), minio.PutObjectOptions{}); ErrUnauthorized != errors.Cause(err) {
+		t.Fatalf("expected the injected error, got %v", err)
+	}
+
+	if _, err := cache.ReplaySpool(); nil != err {
+		t.Fatal(err)
+	}
+	if value := getString(t, backend, "spooled", minio.GetObjectOptions{}); "hello" != value {
+		t.Fatalf("expected the spooled write to be replayed, got %v", value)
+	}
+	info, err := backend.Stat(context.Background(), "spooled", minio.StatObjectOptions{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "text/plain" != info.ContentType {
+		t.Fatalf("expected the options to be replayed, got %v", info.ContentType)
+	}
+	if files, err := cache.spoolFiles(); nil != err || 0 != len(files) {
+		t.Fatalf("expected an empty spool, got %v %v", files, err)
+	}
+}
+
+func TestSpoolDeadLetter(t *testing.T) {
+	dir := t.TempDir()
+	cache, _ := newTestCache(t, WithSpool(SpoolOptions{Dir: dir, Interval: time.Hour}))
+	if err := ioutil.WriteFile(filepath.Join(dir, sortableID()+".json"), []byte("{"), 0644); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := cache.ReplaySpool(); nil != err {
+		t.Fatal(err)
+	}
+	dead, err := ioutil.ReadDir(filepath.Join(dir, spoolDeadLetterDir))
+	if nil != err || 1 != len(dead) {
+		t.Fatalf("expected the corrupt write in the dead letters, got %v %v", dead, err)
+	}
+	if files, err := cache.spoolFiles(); nil != err || 0 != len(files) {
+		t.Fatalf("expected an empty spool, got %v %v", files, err)
+	}
+}
+
+func TestSpoolFull(t *testing.T) {
+	dir := t.TempDir()
+	cache, _ := newTestCache(t, WithSpool(SpoolOptions{Dir: dir, MaxBytes: 4, Interval: time.Hour}))
+	offline := cache.With(CallFaults(1, FaultRule{Ops: []AuthOp{AuthPut}, ErrorRate: 1}))
+	if _, err := offline.WriteData("large", []byte("hello"), minio.PutObjectOptions{}); ErrSpoolFull != errors.Cause(err) {
+		t.Fatalf("expected ErrSpoolFull, got %v", err)
+	}
+	if files, err := cache.spoolFiles(); nil != err || 0 != len(files) {
+		t.Fatalf("expected an empty spool, got %v %v", files, err)
+	}
+}
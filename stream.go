@@ -0,0 +1,88 @@
+package minioproto
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+)
+
+// openStream returns a reader over the object body. Objects are streamed from minio unless local tiers
+// or needsWholePayload require the whole payload, in which case ReadData is used.
+func (cache *Cache) openStream(path string, opts minio.GetObjectOptions) (io.ReadCloser, error) {
+	if cache.hasLocal() || cache.needsWholePayload(path) {
+		data, err := cache.ReadData(path, opts)
+		if nil != err {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	path = cache.objectKey(path)
+	opts = cache.pinVersion(opts)
+	cache.logger.Info(fmt.Sprintf("Streaming path=%v", path))
+	if err := cache.authorize(AuthGet, path, nil); nil != err {
+		return nil, err
+	}
+	if err := cache.injectFault(AuthGet, path); nil != err {
+		err = errors.Wrap(err, "Failed to get file")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	obj, err := cache.backend.Get(cache.ctx, path, opts)
+	if nil != err {
+		err = errors.Wrap(err, "Failed to get file")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+	if cache.maxGetSize > 0 || cache.warnSize > 0 {
+		info, err := obj.Stat()
+		if nil != err {
+			obj.Close()
+			err = errors.Wrap(err, "Failed to stat file")
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
+		if err := cache.checkGetSize(path, info.Size); nil != err {
+			obj.Close()
+			return nil, err
+		}
+	}
+	if nil != cache.readInfo {
+		if info, err := obj.Stat(); nil == err {
+			cache.recordRead(path, info)
+		}
+	}
+	return &streamReader{cache: cache, path: path, opts: opts, obj: obj}, nil
+}
+
+// streamReader fires an EventGet on Close once the object has been read to the end
+type streamReader struct {
+	cache *Cache
+	path  string
+	opts  minio.GetObjectOptions
+	obj   BackendObject
+	size  int64
+	done  bool
+}
+
+// Read reads from the object body
+func (reader *streamReader) Read(p []byte) (int, error) {
+	n, err := reader.obj.Read(p)
+	reader.size += int64(n)
+	if io.EOF == err {
+		reader.done = true
+	}
+	return n, err
+}
+
+// Close closes the object body
+func (reader *streamReader) Close() error {
+	if reader.done {
+		reader.done = false
+		reader.cache.fire(Event{Op: EventGet, Path: reader.path, Size: reader.size, VersionID: reader.opts.VersionID})
+	}
+	return reader.obj.Close()
+}
@@ -0,0 +1,95 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+// ErrUnknownFields is returned in strict mode when a payload has fields the reader doesn't know about
+var ErrUnknownFields = errors.New("Payload contains unknown fields")
+
+// ErrMissingRequiredFields is returned in strict mode when a proto payload is missing required fields
+var ErrMissingRequiredFields = errors.New("Payload is missing required fields")
+
+// WithStrictDecoding surfaces schema drift as errors instead of silently dropping data:
+// GetJSON rejects unknown object keys with ErrUnknownFields (when using StdJSONCodec),
+// GetPROTO rejects unknown fields with ErrUnknownFields and unset required fields with ErrMissingRequiredFields
+func WithStrictDecoding() Option {
+	return func(cache *Cache) {
+		cache.strictDecoding = true
+	}
+}
+
+// jsonDecoder returns the codec used by GetJSON, enabling DisallowUnknownFields in strict mode
+func (cache *Cache) jsonDecoder() JSONCodec {
+	if std, ok := cache.jsonCodec.(StdJSONCodec); ok && cache.strictDecoding {
+		std.DisallowUnknownFields = true
+		return std
+	}
+	return cache.jsonCodec
+}
+
+// strictJSONError maps unknown field failures from encoding/json to ErrUnknownFields
+func strictJSONError(err error) error {
+	if strings.HasPrefix(err.Error(), "json: unknown field") {
+		return errors.Wrap(ErrUnknownFields, err.Error())
+	}
+	return err
+}
+
+// strictUnmarshalOptions keeps unknown fields and defers required field checks to checkStrictPROTO
+func strictUnmarshalOptions(unmarshalOpts *proto.UnmarshalOptions) *proto.UnmarshalOptions {
+	output := proto.UnmarshalOptions{}
+	if nil != unmarshalOpts {
+		output = *unmarshalOpts
+	}
+	output.DiscardUnknown = false
+	output.AllowPartial = true
+	return &output
+}
+
+// checkStrictPROTO validates a decoded message has all required fields and no unknown fields
+func checkStrictPROTO(data proto.Message) error {
+	if err := proto.CheckInitialized(data); nil != err {
+		return errors.Wrap(ErrMissingRequiredFields, err.Error())
+	}
+	if name, ok := findUnknownFields(data.ProtoReflect()); ok {
+		return errors.Wrap(ErrUnknownFields, fmt.Sprintf("Unknown fields in %v", name))
+	}
+	return nil
+}
+
+// findUnknownFields walks the message tree returning the name of the first message with unknown fields
+func findUnknownFields(message protoreflect.Message) (protoreflect.FullName, bool) {
+	if len(message.GetUnknown()) > 0 {
+		return message.Descriptor().FullName(), true
+	}
+
+	var name protoreflect.FullName
+	found := false
+	message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		switch {
+		case field.IsList() && isMessageKind(field.Kind()):
+			list := value.List()
+			for i := 0; i < list.Len() && !found; i++ {
+				name, found = findUnknownFields(list.Get(i).Message())
+			}
+		case field.IsMap() && isMessageKind(field.MapValue().Kind()):
+			value.Map().Range(func(_ protoreflect.MapKey, entry protoreflect.Value) bool {
+				name, found = findUnknownFields(entry.Message())
+				return !found
+			})
+		case !field.IsList() && !field.IsMap() && isMessageKind(field.Kind()):
+			name, found = findUnknownFields(value.Message())
+		}
+		return !found
+	})
+	return name, found
+}
+
+func isMessageKind(kind protoreflect.Kind) bool {
+	return protoreflect.MessageKind == kind || protoreflect.GroupKind == kind
+}
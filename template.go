@@ -0,0 +1,74 @@
+package minioproto
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io"
+	"mime"
+	"path/filepath"
+)
+
+// Template is satisfied by both *html/template.Template and *text/template.Template
+type Template interface {
+	Execute(wr io.Writer, data interface{}) error
+}
+
+// defaultTemplateCacheControl is used by RenderTemplate when opts.CacheControl is empty
+const defaultTemplateCacheControl = "public, max-age=300"
+
+// RenderTemplate executes tmpl with data, streaming the output straight into an object at path.
+// An empty contentType is derived from the extension of path, defaulting to HTML, and when neither
+// opts nor WithHeaders set Cache-Control it defaults to a short public max-age suitable for published pages.
+// When encryption, signatures, compression or a profile apply to path the output is buffered and written
+// through WriteData instead, so it is sealed like any other payload. Streamed output bypasses WriteData
+// like ArchivePrefix, so it isn't indexed, mirrored by StartDualWrite or kept in the local tiers.
+func (cache *Cache) RenderTemplate(path string, tmpl Template, data interface{}, contentType string, opts minio.PutObjectOptions) (*WriteResult, error) {
+	if "" == contentType {
+		contentType = mime.TypeByExtension(filepath.Ext(path))
+	}
+	if "" == contentType {
+		contentType = "text/html; charset=utf-8"
+	}
+	key := cache.objectKey(path)
+	opts = cache.applyHeaders(key, opts)
+	if "" == opts.CacheControl {
+		opts.CacheControl = defaultTemplateCacheControl
+	}
+	opts.ContentType = contentType
+	cache.logger.Info(fmt.Sprintf("Rendering template to path=%v", key))
+	if cache.needsWholePayload(path) {
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, data); nil != err {
+			err = errors.Wrap(err, "Failed to render template")
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
+		return cache.WriteData(path, buf.Bytes(), opts)
+	}
+	path = key
+	if err := cache.authorize(AuthPut, path, opts.UserMetadata); nil != err {
+		return nil, err
+	}
+
+	// Stream the rendered output through a pipe so it never has to be held in memory
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(tmpl.Execute(writer, data))
+	}()
+
+	uploadInfo, err := cache.backend.Put(cache.ctx, path, reader, -1, opts)
+	reader.CloseWithError(err)
+	if nil != err {
+		err = errors.Wrap(err, "Failed to render template")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	result := newWriteResult(uploadInfo)
+	cache.invalidateTiers(path)
+	cache.firePut(path, result)
+	cache.logger.Info(fmt.Sprintf("Successfully rendered bytes: %v", uploadInfo.Size))
+	return result, nil
+}
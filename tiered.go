@@ -0,0 +1,322 @@
+package minioproto
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// TierEntry is a payload held by a Tier along with the ETag, envelopes and user metadata of the object it was read from
+type TierEntry struct {
+	Data     []byte
+	ETag     string
+	Envelope string
+	Metadata map[string]string
+}
+
+// dirTierMeta is stored alongside each payload of a DirTier
+type dirTierMeta struct {
+	ETag     string            `json:"etag"`
+	Envelope string            `json:"envelope,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Tier is a local layer in front of the minio bucket, such as memory or a local disk
+type Tier interface {
+	// Get returns the entry stored at path, or nil if the tier doesn't have it
+	Get(path string) (*TierEntry, error)
+	// Put stores an entry at path
+	Put(path string, entry *TierEntry) error
+	// Delete removes the entry at path if present
+	Delete(path string) error
+}
+
+// TierPolicy controls how the tiers are kept in sync with the bucket
+type TierPolicy struct {
+	// WriteThrough stores written payloads in every tier, otherwise writes invalidate the tiers
+	WriteThrough bool
+	// PromoteOnRead copies payloads found in a slower tier (or the bucket) into the faster tiers
+	PromoteOnRead bool
+}
+
+// WithTiers layers local tiers in front of the bucket, ordered from fastest to slowest,
+// e.g. WithTiers(policy, NewMemoryTier(64<<20), NewDirTier("/mnt/ssd/cache")).
+// Reads of specific versions, byte ranges or conditional reads always go to the bucket.
+func WithTiers(policy TierPolicy, tiers ...Tier) Option {
+	return func(cache *Cache) {
+		cache.tierPolicy = policy
+		cache.tiers = tiers
+	}
+}
+
+// tierable reports whether a read can be answered from a local tier
+func tierable(opts minio.GetObjectOptions) bool {
+	return "" == opts.VersionID && 0 == len(opts.Header())
+}
+
+// hasLocal reports whether any local tier or disk cache is configured
+func (cache *Cache) hasLocal() bool {
+	return 0 != len(cache.tiers) || nil != cache.diskCache
+}
+
+// readLocal answers a read from the tiers or the validated disk cache
+func (cache *Cache) readLocal(path string) (*TierEntry, bool) {
+	if entry := cache.readTiers(path); nil != entry {
+		return entry, true
+	}
+	if nil == cache.diskCache {
+		return nil, false
+	}
+	entry, ok := cache.readDiskCache(path)
+	if ok && cache.tierPolicy.PromoteOnRead {
+		cache.fillTiers(path, entry, len(cache.tiers))
+	}
+	return entry, ok
+}
+
+// storeLocal keeps a payload read from the bucket in the tiers and disk cache
+func (cache *Cache) storeLocal(path string, entry *TierEntry) {
+	if cache.tierPolicy.PromoteOnRead {
+		cache.fillTiers(path, entry, len(cache.tiers))
+	}
+	if nil != cache.diskCache {
+		cache.writeDiskCache(path, entry)
+	}
+}
+
+// writeLocal updates the tiers and disk cache after a payload has been written to the bucket
+func (cache *Cache) writeLocal(path string, entry *TierEntry) {
+	cache.writeTiers(path, entry)
+	if nil != cache.diskCache {
+		cache.writeDiskCache(path, entry)
+	}
+}
+
+// readTiers returns the first entry found in the tiers, promoting it to the faster tiers
+func (cache *Cache) readTiers(path string) *TierEntry {
+	for i, tier := range cache.tiers {
+		entry, err := tier.Get(path)
+		if nil != err {
+			cache.logger.Warn(fmt.Sprintf("Failed to read tier %v for path=%v: %v", i, path, err.Error()))
+			continue
+		}
+		if nil == entry {
+			continue
+		}
+
+		cache.logger.Info(fmt.Sprintf("Tier %v hit for path=%v", i, path))
+		if cache.tierPolicy.PromoteOnRead {
+			cache.fillTiers(path, entry, i)
+		}
+		return entry
+	}
+	return nil
+}
+
+// fillTiers stores the entry in the first count tiers
+func (cache *Cache) fillTiers(path string, entry *TierEntry, count int) {
+	for i, tier := range cache.tiers[:count] {
+		if err := tier.Put(path, entry); nil != err {
+			cache.logger.Warn(fmt.Sprintf("Failed to write tier %v for path=%v: %v", i, path, err.Error()))
+		}
+	}
+}
+
+// writeTiers updates the tiers after a payload has been written to the bucket
+func (cache *Cache) writeTiers(path string, entry *TierEntry) {
+	if cache.tierPolicy.WriteThrough {
+		cache.fillTiers(path, entry, len(cache.tiers))
+		return
+	}
+	cache.invalidateTiers(path)
+}
+
+// invalidateTiers removes path from every tier and the disk cache after it was changed in the bucket
+func (cache *Cache) invalidateTiers(path string) {
+	for i, tier := range cache.tiers {
+		if err := tier.Delete(path); nil != err {
+			cache.logger.Warn(fmt.Sprintf("Failed to invalidate tier %v for path=%v: %v", i, path, err.Error()))
+		}
+	}
+	if nil != cache.diskCache {
+		if err := cache.diskCache.Delete(path); nil != err {
+			cache.logger.Warn(fmt.Sprintf("Failed to invalidate disk cache for path=%v: %v", path, err.Error()))
+		}
+	}
+}
+
+//
+// Memory tier
+//
+
+// MemoryTier is an in-memory Tier bounded by size, evicting the least recently used entries
+type MemoryTier struct {
+	mutex    sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type memoryItem struct {
+	path  string
+	entry *TierEntry
+}
+
+// NewMemoryTier creates a MemoryTier holding at most maxBytes of payloads
+func NewMemoryTier(maxBytes int64) *MemoryTier {
+	return &MemoryTier{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+// Get returns the entry stored at path, or nil if absent
+func (tier *MemoryTier) Get(path string) (*TierEntry, error) {
+	tier.mutex.Lock()
+	defer tier.mutex.Unlock()
+	element, ok := tier.entries[path]
+	if !ok {
+		return nil, nil
+	}
+	tier.order.MoveToFront(element)
+	return element.Value.(*memoryItem).entry, nil
+}
+
+// Put stores an entry at path, evicting older entries to stay within the size limit
+func (tier *MemoryTier) Put(path string, entry *TierEntry) error {
+	size := int64(len(entry.Data))
+	if size > tier.maxBytes {
+		return tier.Delete(path)
+	}
+
+	tier.mutex.Lock()
+	defer tier.mutex.Unlock()
+	tier.remove(path)
+	tier.entries[path] = tier.order.PushFront(&memoryItem{path: path, entry: entry})
+	tier.size += size
+	for tier.size > tier.maxBytes {
+		tier.remove(tier.order.Back().Value.(*memoryItem).path)
+	}
+	return nil
+}
+
+// Delete removes the entry at path if present
+func (tier *MemoryTier) Delete(path string) error {
+	tier.mutex.Lock()
+	defer tier.mutex.Unlock()
+	tier.remove(path)
+	return nil
+}
+
+func (tier *MemoryTier) remove(path string) {
+	element, ok := tier.entries[path]
+	if !ok {
+		return
+	}
+	tier.order.Remove(element)
+	delete(tier.entries, path)
+	tier.size -= int64(len(element.Value.(*memoryItem).entry.Data))
+}
+
+//
+// Directory tier
+//
+
+// DirTier is a Tier storing payloads as files under a local directory
+type DirTier struct {
+	dir string
+}
+
+// NewDirTier creates a DirTier rooted at dir
+func NewDirTier(dir string) *DirTier {
+	return &DirTier{dir: dir}
+}
+
+// Get returns the entry stored at path, or nil if absent
+func (tier *DirTier) Get(path string) (*TierEntry, error) {
+	dataPath, metaPath := tier.files(path)
+	data, err := ioutil.ReadFile(dataPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if nil != err {
+		return nil, err
+	}
+
+	meta, err := ioutil.ReadFile(metaPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if nil != err {
+		return nil, err
+	}
+	decoded := dirTierMeta{}
+	if err := json.Unmarshal(meta, &decoded); nil != err {
+		// Written by an older version, the envelopes of the payload are unknown
+		return nil, tier.Delete(path)
+	}
+	return &TierEntry{Data: data, ETag: decoded.ETag, Envelope: decoded.Envelope, Metadata: decoded.Metadata}, nil
+}
+
+// Put stores an entry at path, writing through a temp file so readers never see partial payloads
+func (tier *DirTier) Put(path string, entry *TierEntry) error {
+	dataPath, metaPath := tier.files(path)
+	meta, err := json.Marshal(dirTierMeta{ETag: entry.ETag, Envelope: entry.Envelope, Metadata: entry.Metadata})
+	if nil != err {
+		return err
+	}
+	if err := writeFileAtomic(dataPath, entry.Data); nil != err {
+		return err
+	}
+	return writeFileAtomic(metaPath, meta)
+}
+
+// Delete removes the entry at path if present
+func (tier *DirTier) Delete(path string) error {
+	dataPath, metaPath := tier.files(path)
+	for _, file := range []string{dataPath, metaPath} {
+		if err := os.Remove(file); nil != err && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// files returns the payload and metadata files for path, which can't escape the directory
+func (tier *DirTier) files(path string) (string, string) {
+	clean := filepath.FromSlash(strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+path)), "/"))
+	return filepath.Join(tier.dir, "data", clean), filepath.Join(tier.dir, "meta", clean+".json")
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); nil != err {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if nil != err {
+		return err
+	}
+	if _, err := tmp.Write(data); nil != err {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); nil != err {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); nil != err {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "Failed to move cache file into place")
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package minioproto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+// TransformStage selects whether a Transform runs before writing or after reading
+type TransformStage int
+
+const (
+	// TransformOnPut rewrites values before they are written, so the stored object is transformed
+	TransformOnPut TransformStage = iota
+	// TransformOnGet rewrites values after they are read, leaving the stored object untouched
+	TransformOnGet
+)
+
+// Transform rewrites decoded values of objects under a prefix, e.g. to scrub PII
+type Transform struct {
+	Prefix string
+	Stage  TransformStage
+	// JSON receives the generic decoded JSON document (maps, slices and json.Number) and returns the replacement
+	JSON func(path string, document interface{}) (interface{}, error)
+	// PROTO modifies the message in place, on Put it receives a copy of the caller's message
+	PROTO func(path string, message proto.Message) error
+}
+
+// WithTransforms registers transforms applied by the JSON and PROTO helpers to matching paths, in order
+func WithTransforms(transforms ...Transform) Option {
+	return func(cache *Cache) {
+		cache.transforms = append(cache.transforms, transforms...)
+	}
+}
+
+// matchingTransforms returns the transforms for path and stage
+func (cache *Cache) matchingTransforms(path string, stage TransformStage) []Transform {
+	output := []Transform{}
+	for _, transform := range cache.transforms {
+		if transform.Stage == stage && strings.HasPrefix(path, transform.Prefix) {
+			output = append(output, transform)
+		}
+	}
+	return output
+}
+
+// transformJSON applies the JSON transforms to a serialized payload
+func (cache *Cache) transformJSON(path string, stage TransformStage, payload []byte) ([]byte, error) {
+	transforms := []Transform{}
+	for _, transform := range cache.matchingTransforms(path, stage) {
+		if nil != transform.JSON {
+			transforms = append(transforms, transform)
+		}
+	}
+	if 0 == len(transforms) {
+		return payload, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); nil != err {
+		return nil, errors.Wrap(err, "Failed to decode JSON for transform")
+	}
+	for _, transform := range transforms {
+		var err error
+		if document, err = transform.JSON(path, document); nil != err {
+			err = errors.Wrap(err, fmt.Sprintf("Failed to transform path=%v", path))
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
+	}
+	return cache.marshalJSON(document)
+}
+
+// transformPROTO applies the PROTO transforms to a message, cloning it first on Put
+func (cache *Cache) transformPROTO(path string, stage TransformStage, message proto.Message) (proto.Message, error) {
+	cloned := false
+	for _, transform := range cache.matchingTransforms(path, stage) {
+		if nil == transform.PROTO {
+			continue
+		}
+		if TransformOnPut == stage && !cloned {
+			message = proto.Clone(message)
+			cloned = true
+		}
+		if err := transform.PROTO(path, message); nil != err {
+			err = errors.Wrap(err, fmt.Sprintf("Failed to transform path=%v", path))
+			cache.logger.Error(err.Error())
+			return nil, err
+		}
+	}
+	return message, nil
+}
+
+// RedactJSONFields returns a Transform.JSON function replacing the values at dotted field paths
+// such as "user.email" with replacement, descending into arrays along the way
+func RedactJSONFields(replacement interface{}, fields ...string) func(string, interface{}) (interface{}, error) {
+	return func(path string, document interface{}) (interface{}, error) {
+		for _, field := range fields {
+			redactJSON(document, strings.Split(field, "."), replacement)
+		}
+		return document, nil
+	}
+}
+
+func redactJSON(value interface{}, field []string, replacement interface{}) {
+	switch node := value.(type) {
+	case []interface{}:
+		for _, element := range node {
+			redactJSON(element, field, replacement)
+		}
+	case map[string]interface{}:
+		child, ok := node[field[0]]
+		if !ok {
+			return
+		}
+		if 1 == len(field) {
+			node[field[0]] = replacement
+			return
+		}
+		redactJSON(child, field[1:], replacement)
+	}
+}
+
+// ClearPROTOFields returns a Transform.PROTO function clearing the fields at dotted field name paths
+// such as "user.email", descending into repeated and map message fields along the way
+func ClearPROTOFields(fields ...string) func(string, proto.Message) error {
+	return func(path string, message proto.Message) error {
+		for _, field := range fields {
+			clearPROTO(message.ProtoReflect(), strings.Split(field, "."))
+		}
+		return nil
+	}
+}
+
+func clearPROTO(message protoreflect.Message, field []string) {
+	descriptor := message.Descriptor().Fields().ByName(protoreflect.Name(field[0]))
+	if nil == descriptor || !message.Has(descriptor) {
+		return
+	}
+	if 1 == len(field) {
+		message.Clear(descriptor)
+		return
+	}
+
+	value := message.Get(descriptor)
+	switch {
+	case descriptor.IsList() && isMessageKind(descriptor.Kind()):
+		list := value.List()
+		for i := 0; i < list.Len(); i++ {
+			clearPROTO(list.Get(i).Message(), field[1:])
+		}
+	case descriptor.IsMap() && isMessageKind(descriptor.MapValue().Kind()):
+		value.Map().Range(func(_ protoreflect.MapKey, entry protoreflect.Value) bool {
+			clearPROTO(entry.Message(), field[1:])
+			return true
+		})
+	case !descriptor.IsList() && !descriptor.IsMap() && isMessageKind(descriptor.Kind()):
+		clearPROTO(message.Mutable(descriptor).Message(), field[1:])
+	}
+}
@@ -0,0 +1,154 @@
+package minioproto
+
+import (
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// UploadOptions configures UploadDir
+type UploadOptions struct {
+	// Include only uploads files matching one of the globs, all files are uploaded when empty
+	Include []string
+	// Exclude skips files matching any of the globs
+	Exclude []string
+	// Concurrency is the number of parallel uploads, defaults to 4
+	Concurrency int
+	// ManifestKey writes a JSON manifest of the uploaded files to this key once all uploads succeed
+	ManifestKey string
+	// PutOptions are applied to every upload, the content type is detected per file unless set
+	PutOptions minio.PutObjectOptions
+}
+
+// UploadResult reports the outcome of a single file uploaded by UploadDir
+type UploadResult struct {
+	Key         string `json:"key"`
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	ETag        string `json:"etag"`
+	ContentType string `json:"contentType"`
+	Err         error  `json:"-"`
+}
+
+// UploadDir uploads every file under localDir to prefix, keeping the directory layout.
+// Globs are matched with filepath.Match against both the slash separated path relative to localDir and the file name.
+func (cache *Cache) UploadDir(localDir, prefix string, opts UploadOptions) ([]UploadResult, error) {
+	cache.logger.Info(fmt.Sprintf("Uploading dir=%v to prefix=%v", localDir, prefix))
+
+	results := []UploadResult{}
+	err := filepath.Walk(localDir, func(file string, info os.FileInfo, err error) error {
+		if nil != err {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(localDir, file)
+		if nil != err {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !matchesGlobs(rel, opts.Include, true) || matchesGlobs(rel, opts.Exclude, false) {
+			return nil
+		}
+
+		contentType := opts.PutOptions.ContentType
+		if "" == contentType {
+			contentType = detectContentType(rel)
+		}
+		results = append(results, UploadResult{
+			Key:         path.Join(prefix, rel),
+			Path:        file,
+			Size:        info.Size(),
+			ContentType: contentType,
+		})
+		return nil
+	})
+	if nil != err {
+		err = errors.Wrap(err, "Failed to walk upload directory")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	parallel(opts.Concurrency, len(results), func(index int) {
+		cache.uploadFile(&results[index], opts.PutOptions)
+	})
+
+	failed := 0
+	for _, result := range results {
+		if nil != result.Err {
+			failed++
+		}
+	}
+	if failed > 0 {
+		err = errors.New(fmt.Sprintf("Failed to upload %v of %v files", failed, len(results)))
+		cache.logger.Error(err.Error())
+		return results, err
+	}
+
+	if "" != opts.ManifestKey {
+		if _, err := cache.PutJSON(opts.ManifestKey, results, minio.PutObjectOptions{}); nil != err {
+			return results, err
+		}
+	}
+
+	cache.logger.Info(fmt.Sprintf("Successfully uploaded files: %v", len(results)))
+	return results, nil
+}
+
+// uploadFile uploads a single file, recording the outcome on the result
+func (cache *Cache) uploadFile(result *UploadResult, opts minio.PutObjectOptions) {
+	key := cache.objectKey(result.Key)
+	if err := cache.checkPutSize(key, result.Size); nil != err {
+		result.Err = err
+		return
+	}
+	if err := cache.authorize(AuthPut, key, opts.UserMetadata); nil != err {
+		result.Err = err
+		return
+	}
+
+	opts.ContentType = result.ContentType
+	uploadInfo, err := cache.putFile(key, result.Path, opts)
+	if nil != err {
+		result.Err = errors.Wrap(err, fmt.Sprintf("Failed to upload %v", result.Path))
+		cache.logger.Error(result.Err.Error())
+		return
+	}
+	written := newWriteResult(uploadInfo)
+	result.ETag = written.ETag
+	cache.invalidateTiers(key)
+	cache.firePut(key, written)
+	cache.logger.Info(fmt.Sprintf("Uploaded file=%v to path=%v", result.Path, result.Key))
+}
+
+// matchesGlobs reports whether the relative path or its file name matches any glob, or empty when there are none
+func matchesGlobs(rel string, globs []string, empty bool) bool {
+	if 0 == len(globs) {
+		return empty
+	}
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(glob, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// detectContentType picks the content type from the file extension, preferring the types used by this package
+func detectContentType(file string) string {
+	if contentType := contentTypeForPath(file); "" != contentType {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension(path.Ext(file)); "" != contentType {
+		return contentType
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,54 @@
+package minioproto
+
+import (
+	"github.com/minio/minio-go/v7"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReadInfo describes the object returned by the last read of a view created with CallReadInfo
+type ReadInfo struct {
+	sync.Mutex
+	Key          string
+	ETag         string
+	VersionID    string
+	LastModified time.Time
+}
+
+// CallVersionID pins every read and stat of the view to versionID, for use with GetJSON, GetPROTO,
+// GetCSV and the other format helpers on a versioned bucket. A VersionID set on the options of a call takes precedence.
+func CallVersionID(versionID string) CallOption {
+	return func(cache *Cache) {
+		cache.versionID = versionID
+	}
+}
+
+// CallReadInfo records the key, ETag and version of every object read through the view into info,
+// so callers can learn which version a format helper returned. Reads served by local tiers only record the Key.
+func CallReadInfo(info *ReadInfo) CallOption {
+	return func(cache *Cache) {
+		cache.readInfo = info
+	}
+}
+
+// pinVersion applies the version of the view to opts
+func (cache *Cache) pinVersion(opts minio.GetObjectOptions) minio.GetObjectOptions {
+	if "" != cache.versionID && "" == opts.VersionID {
+		opts.VersionID = cache.versionID
+	}
+	return opts
+}
+
+// recordRead stores the object returned by a read into the ReadInfo of the view
+func (cache *Cache) recordRead(path string, info minio.ObjectInfo) {
+	if nil == cache.readInfo {
+		return
+	}
+	cache.readInfo.Lock()
+	defer cache.readInfo.Unlock()
+	cache.readInfo.Key = path
+	cache.readInfo.ETag = strings.Trim(info.ETag, "\"")
+	cache.readInfo.VersionID = info.VersionID
+	cache.readInfo.LastModified = info.LastModified
+}
@@ -0,0 +1,105 @@
+package minioproto
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"sort"
+)
+
+// XLSXExists checks if XLSX file exists in minio
+func (cache *Cache) XLSXExists(path string, opts minio.StatObjectOptions) (*minio.ObjectInfo, error) {
+	path = pathFix(path, xlsxContentType)
+	return cache.DataExists(path, opts)
+}
+
+// GetXLSX reads the rows of a sheet from an XLSX file in minio, an empty sheet name reads the first sheet
+func (cache *Cache) GetXLSX(path, sheet string, opts minio.GetObjectOptions) ([][]string, error) {
+	path = pathFix(path, xlsxContentType)
+	cache.logger.Info(fmt.Sprintf("Reading XLSX file, path=%v sheet=%v", path, sheet))
+	data, err := cache.ReadData(path, opts)
+	if nil != err {
+		err = errors.Wrap(err, "Failed to fetch XLSX")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	workbook, err := excelize.OpenReader(bytes.NewReader(data))
+	if nil != err {
+		err = errors.Wrap(err, "Failed deserialize data from XLSX")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	if "" == sheet {
+		sheet = workbook.GetSheetName(0)
+	}
+	if workbook.GetSheetIndex(sheet) < 0 {
+		err = errors.New(fmt.Sprintf("Sheet %v not found in XLSX", sheet))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	output, err := workbook.GetRows(sheet)
+	if nil != err {
+		err = errors.Wrap(err, fmt.Sprintf("Failed to read rows from sheet %v", sheet))
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	cache.logger.Info(fmt.Sprintf("Success reading path=%v", path))
+	return output, nil
+}
+
+// PutXLSX writes an XLSX file to minio with one worksheet per map entry, sheets are ordered by name
+func (cache *Cache) PutXLSX(path string, sheets map[string][][]string, opts minio.PutObjectOptions) (*WriteResult, error) {
+	if 0 == len(sheets) {
+		err := errors.New("XLSX requires at least one sheet")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	names := make([]string, 0, len(sheets))
+	for name := range sheets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Serialize the sheets to a workbook, reusing the default sheet for the first name
+	workbook := excelize.NewFile()
+	workbook.SetSheetName(workbook.GetSheetName(0), names[0])
+	for _, name := range names {
+		if workbook.GetSheetIndex(name) < 0 {
+			workbook.NewSheet(name)
+		}
+		for i, record := range sheets[name] {
+			cell, err := excelize.CoordinatesToCellName(1, i+1)
+			if nil != err {
+				return nil, err
+			}
+			row := make([]interface{}, len(record))
+			for j, value := range record {
+				row[j] = value
+			}
+			if err := workbook.SetSheetRow(name, cell, &row); nil != err {
+				err = errors.Wrap(err, fmt.Sprintf("Failed serialize sheet %v as XLSX", name))
+				cache.logger.Error(err.Error())
+				return nil, err
+			}
+		}
+	}
+
+	buf, err := workbook.WriteToBuffer()
+	if nil != err {
+		err = errors.Wrap(err, "Failed serialize data as XLSX")
+		cache.logger.Error(err.Error())
+		return nil, err
+	}
+
+	// Write the data
+	opts.ContentType = xlsxContentType
+	path = pathFix(path, opts.ContentType)
+	return cache.WriteData(path, buf.Bytes(), opts)
+}